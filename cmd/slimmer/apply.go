@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+// runApply extracts the filesystem of the image a plan was built for,
+// skipping layers the plan marks for removal
+func runApply(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("apply", stderr)
+
+	var planFile, outDir string
+	fs.StringVar(&planFile, "plan", "", "plan file produced by \"slimmer plan -o json\"")
+	fs.StringVar(&outDir, "out", "", "target directory for the extracted filesystem")
+
+	if !parse(fs, lf, args, 0, stderr) {
+		return exitUsage
+	}
+	if planFile == "" || outDir == "" {
+		fmt.Fprintln(stderr, "apply: -plan and -out are required")
+		return exitUsage
+	}
+
+	plan, err := loadPlan(planFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "apply: %v\n", err)
+		return exitUsage
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	src, err := slimmer.Open(ctx, plan.Reference, opts...)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer src.Close()
+
+	// Refuse plans built for another image, such as an older push of the tag
+	if err := plan.CheckDrift(src.Digest); err != nil {
+		return fail(stderr, err)
+	}
+
+	if src.Len() != len(plan.Layers) {
+		return fail(stderr, fmt.Errorf("plan has %d layers but image has %d", len(plan.Layers), src.Len()))
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return fail(stderr, err)
+	}
+
+	layers := append([]slimmer.LayerPlan(nil), plan.Layers...)
+	sort.Slice(layers, func(i, j int) bool { return layers[i].Index < layers[j].Index })
+
+	for _, lp := range layers {
+		if lp.Action == slimmer.ActionRemove {
+			fmt.Fprintf(stdout, "skip layer %d %s\n", lp.Index, lp.Digest)
+			continue
+		}
+
+		rc, err := src.Uncompressed(lp.Index)
+		if err != nil {
+			return fail(stderr, err)
+		}
+
+		layer := slimmer.Layer{Index: lp.Index, Digest: lp.Digest}
+		if err := slimmer.ExtractLayerToFS(layer, rc, outDir); err != nil {
+			return fail(stderr, err)
+		}
+		fmt.Fprintf(stdout, "applied layer %d %s\n", lp.Index, lp.Digest)
+	}
+
+	return exitOK
+}
+
+// loadPlan reads a JSON plan file
+func loadPlan(path string) (*slimmer.ImagePlan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	plan, err := slimmer.DecodePlan(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %w", path, err)
+	}
+	if plan.Reference == "" {
+		return nil, fmt.Errorf("plan file %s has no reference", path)
+	}
+	return plan, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+// runAnalyze prints the full analysis result for an image
+func runAnalyze(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("analyze", stderr)
+
+	var timings, files bool
+	var previous string
+	fs.BoolVar(&timings, "timings", false, "include wall-clock fields in json and yaml output")
+	fs.BoolVar(&files, "files", false, "include per-layer file listings in json and yaml output")
+	fs.StringVar(&previous, "previous", "", "JSON result of an earlier run with -files; unchanged images are not fetched again")
+
+	if !parse(fs, lf, args, 1, stderr) {
+		return exitUsage
+	}
+
+	var extra []slimmer.Option
+	if previous != "" {
+		prev, err := loadResult(previous)
+		if err != nil {
+			fmt.Fprintf(stderr, "analyze: %v\n", err)
+			return exitUsage
+		}
+		if prev.Image != nil && prev.Image.ListingsOmitted {
+			fmt.Fprintf(stderr, "analyze: %s was written without -files and cannot stand in for the image\n", previous)
+			return exitUsage
+		}
+		extra = append(extra, slimmer.WithPrevious(prev))
+	}
+
+	result, err := lf.load(ctx, fs.Arg(0), extra...)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	var encodeOpts []slimmer.EncodeOption
+	if timings {
+		encodeOpts = append(encodeOpts, slimmer.WithTimings())
+	}
+	if files {
+		encodeOpts = append(encodeOpts, slimmer.WithFiles())
+	}
+
+	doc := result.Document(encodeOpts...)
+	if err := writeOutput(stdout, lf.output, doc, func() string { return resultText(result) }); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// runPlan prints the slimming plan for an image
+func runPlan(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("plan", stderr)
+
+	var history stringList
+	fs.Var(&history, "history", "earlier tag of the image, oldest first, used to recommend layer reorders (repeatable)")
+
+	if !parse(fs, lf, args, 1, stderr) {
+		return exitUsage
+	}
+	if lf.metadataOnly {
+		fmt.Fprintln(stderr, "plan: -metadata-only cannot produce a plan")
+		return exitUsage
+	}
+
+	results := make([]*slimmer.Result, 0, len(history)+1)
+	for _, ref := range append(history, fs.Arg(0)) {
+		r, err := lf.load(ctx, ref)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		results = append(results, r)
+	}
+	result := results[len(results)-1]
+
+	if len(history) > 0 {
+		layout, err := slimmer.RecommendLayout(results...)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		result.Plan.Layout = layout
+	}
+
+	doc := slimmer.NewPlanDocument(result.Plan)
+	if err := writeOutput(stdout, lf.output, doc, result.Plan.Summary); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// runReport prints a condensed size and content report for an image
+func runReport(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("report", stderr)
+	if !parse(fs, lf, args, 1, stderr) {
+		return exitUsage
+	}
+
+	result, err := lf.load(ctx, fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	report, err := slimmer.NewReport(result)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	if err := writeOutput(stdout, lf.output, report, report.Summary); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// runCheck evaluates an image against budgets and forbidden content
+// It exits with exitViolation when the policy fails
+func runCheck(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("check", stderr)
+
+	var (
+		policy     slimmer.Policy
+		policyFile string
+	)
+
+	fs.StringVar(&policyFile, "policy", "", "JSON policy file; flags override its values")
+	fs.Int64Var(&policy.MaxImageSize, "max-size", 0, "maximum uncompressed image size in bytes")
+	fs.IntVar(&policy.MaxLayers, "max-layers", 0, "maximum number of layers")
+	fs.Int64Var(&policy.MaxWastedBytes, "max-wasted", 0, "maximum bytes of overwritten or deleted files")
+	fs.Var((*stringList)(&policy.ForbiddenPaths), "forbid-path", "forbidden path pattern (repeatable)")
+	fs.Var((*stringList)(&policy.ForbiddenPackages), "forbid-package", "forbidden package name pattern (repeatable)")
+
+	if !parse(fs, lf, args, 1, stderr) {
+		return exitUsage
+	}
+	if lf.metadataOnly {
+		fmt.Fprintln(stderr, "check: -metadata-only has no files to check")
+		return exitUsage
+	}
+
+	if policyFile != "" {
+		base, err := loadPolicy(policyFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "check: %v\n", err)
+			return exitUsage
+		}
+		policy = mergePolicy(*base, policy)
+	}
+
+	result, err := lf.load(ctx, fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	verdict, err := policy.Evaluate(result)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	if err := writeOutput(stdout, lf.output, verdict, verdict.Summary); err != nil {
+		return fail(stderr, err)
+	}
+
+	if !verdict.Passed {
+		return exitViolation
+	}
+	return exitOK
+}
+
+// loadResult reads a JSON result document written by analyze
+func loadResult(path string) (*slimmer.Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r slimmer.Result
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid result %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// loadPolicy reads a JSON policy file; keys match Policy field names
+func loadPolicy(path string) (*slimmer.Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p slimmer.Policy
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// mergePolicy overlays non-zero flag values onto a file-based policy
+func mergePolicy(base, flags slimmer.Policy) slimmer.Policy {
+	if flags.MaxImageSize > 0 {
+		base.MaxImageSize = flags.MaxImageSize
+	}
+	if flags.MaxLayers > 0 {
+		base.MaxLayers = flags.MaxLayers
+	}
+	if flags.MaxWastedBytes > 0 {
+		base.MaxWastedBytes = flags.MaxWastedBytes
+	}
+	base.ForbiddenPaths = append(base.ForbiddenPaths, flags.ForbiddenPaths...)
+	base.ForbiddenPackages = append(base.ForbiddenPackages, flags.ForbiddenPackages...)
+	return base
+}
+
+// resultText renders the text form of a full analysis result
+func resultText(r *slimmer.Result) string {
+	var sb strings.Builder
+
+	sb.WriteString("==== IMAGE ====\n")
+	sb.WriteString(fmt.Sprintf("%s\n", r.Image.Reference))
+	sb.WriteString(fmt.Sprintf("Digest: %s\n", r.Image.Digest))
+	sb.WriteString(fmt.Sprintf("Media type: %s\n", r.Image.MediaType))
+	sb.WriteString(fmt.Sprintf("Status: %s\n", r.Status))
+
+	sb.WriteString("\n==== METRICS ====\n")
+	sb.WriteString(fmt.Sprintf("Fetch: %s (%d attempts)\n", r.Metrics.FetchDuration, r.Metrics.FetchAttempts))
+	if r.Metrics.Endpoint != "" {
+		sb.WriteString(fmt.Sprintf("Endpoint: %s\n", r.Metrics.Endpoint))
+	}
+	sb.WriteString(fmt.Sprintf("Build: %s\n", r.Metrics.BuildDuration))
+	sb.WriteString(fmt.Sprintf("Transferred: %d bytes compressed, %d bytes uncompressed\n", r.Metrics.CompressedBytes, r.Metrics.UncompressedBytes))
+	sb.WriteString(fmt.Sprintf("Total: %s\n", r.Metrics.TotalDuration))
+
+	if r.Plan != nil {
+		sb.WriteString("\n==== PLAN ====\n")
+		sb.WriteString(r.Plan.Summary())
+	}
+
+	if r.Deterministic != nil {
+		sb.WriteString("\n==== DETERMINISTIC ====\n")
+		sb.WriteString(r.Deterministic.Summary())
+	}
+
+	return sb.String()
+}
+
+// runSchema prints the JSON Schema of the result document
+func runSchema(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var out string
+	fs.StringVar(&out, "out", "", "write the schema to a file instead of stdout")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	schema, err := slimmer.JSONSchema()
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, schema, "", "  "); err != nil {
+		return fail(stderr, err)
+	}
+	indented.WriteByte('\n')
+
+	if out == "" {
+		_, err = stdout.Write(indented.Bytes())
+	} else {
+		err = os.WriteFile(out, indented.Bytes(), 0o644)
+	}
+	if err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+// runDiff compares the layers, files and packages of two images, or two
+// plan files with -plans
+func runDiff(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("diff", stderr)
+
+	var plans bool
+	fs.BoolVar(&plans, "plans", false, "compare two plan files produced by \"slimmer plan -o json\"")
+
+	if !parse(fs, lf, args, 2, stderr) {
+		return exitUsage
+	}
+	if plans {
+		return diffPlanFiles(fs.Arg(0), fs.Arg(1), lf.output, stdout, stderr)
+	}
+	if lf.metadataOnly {
+		fmt.Fprintln(stderr, "diff: -metadata-only has no files to compare")
+		return exitUsage
+	}
+
+	from, err := lf.load(ctx, fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	to, err := lf.load(ctx, fs.Arg(1))
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	d, err := slimmer.DiffResults(from, to)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	if err := writeOutput(stdout, lf.output, d, d.Summary); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// diffPlanFiles compares the actions, risks and savings of two plan files
+func diffPlanFiles(fromFile, toFile, output string, stdout, stderr io.Writer) int {
+	from, err := loadPlan(fromFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitUsage
+	}
+
+	to, err := loadPlan(toFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return exitUsage
+	}
+
+	d, err := slimmer.DiffPlans(from, to)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	if err := writeOutput(stdout, output, d, d.Summary); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+const (
+	exitOK        = 0
+	exitViolation = 1
+	exitUsage     = 2
+	exitFailure   = 3
+)
+
+// exitCodes maps analyzer error classifications to stable process exit codes
+// Codes are grouped: 1x for registry and fetch, 2x for image structure,
+// 3x for TLS, 4x for plans
+var exitCodes = map[slimmer.ErrorCode]int{
+	slimmer.CodeInvalidReference:   10,
+	slimmer.CodeImageNotFound:      11,
+	slimmer.CodeUnauthorized:       12,
+	slimmer.CodeTimeout:            13,
+	slimmer.CodeFetchFailed:        14,
+	slimmer.CodeRateLimited:        15,
+	slimmer.CodeRepositoryNotFound: 16,
+	slimmer.CodeBlobNotFound:       17,
+	slimmer.CodeAccessDenied:       18,
+	slimmer.CodeCircuitOpen:        19,
+
+	slimmer.CodeNoLayers:         20,
+	slimmer.CodeBuildFailed:      21,
+	slimmer.CodeDigestFailed:     22,
+	slimmer.CodeMediaTypeFailed:  23,
+	slimmer.CodeSizeFailed:       24,
+	slimmer.CodeLayerExtract:     25,
+	slimmer.CodeValidationFailed: 26,
+
+	slimmer.CodeTLSUnknownAuthority:   30,
+	slimmer.CodeTLSHostnameMismatch:   31,
+	slimmer.CodeTLSCertificateInvalid: 32,
+	slimmer.CodeTLSHandshakeRejected:  33,
+	slimmer.CodeTLSNotSupported:       34,
+	slimmer.CodeTLSConfig:             35,
+
+	slimmer.CodePlanDrift: 40,
+}
+
+// exitCode derives the process exit code for an error
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if ae, ok := slimmer.AsAnalyzerError(err); ok {
+		if code, ok := exitCodes[ae.Code()]; ok {
+			return code
+		}
+	}
+	return exitFailure
+}
+
+// fail reports err on stderr and returns its exit code
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintln(stderr, "error:", err)
+	return exitCode(err)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+	"github.com/pnkcaht/image-slimmer-core/pkg/telemetry"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// loadFlags holds the flags shared by every command that loads an image
+// Each flag maps to one slimmer.Option
+type loadFlags struct {
+	timeout      time.Duration
+	retries      int
+	backoff      time.Duration
+	metadataOnly bool
+	platform     string
+	parallelism  int
+	memoryBudget int64
+	compression  bool
+	cacheDir     string
+	cacheSize    int64
+	registries   string
+	certsDir     string
+	insecure     stringList
+	plainHTTP    stringList
+	proxy        string
+	dockerConfig string
+	tokenFiles   stringList
+	credHelpers  stringList
+	baseCatalog  string
+	metricsFile  string
+	output       string
+
+	// recorder aggregates metrics of every load when set
+	recorder *telemetry.Recorder
+
+	// breaker shares registry health across loads when set; it must be
+	// set before the first call to options
+	breaker *slimmer.CircuitBreaker
+
+	// opts is built once so the cache, catalog, mirrors and registry
+	// connections are shared across loads
+	optsOnce sync.Once
+	opts     []slimmer.Option
+	optsErr  error
+}
+
+// newFlagSet creates a command flag set with the shared load flags registered
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *loadFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	lf := &loadFlags{}
+	fs.DurationVar(&lf.timeout, "timeout", 30*time.Second, "registry communication timeout")
+	fs.IntVar(&lf.retries, "retries", 2, "retries for transient registry failures")
+	fs.DurationVar(&lf.backoff, "backoff", 500*time.Millisecond, "base delay between retries")
+	fs.BoolVar(&lf.metadataOnly, "metadata-only", false, "skip layer download and analysis")
+	fs.StringVar(&lf.platform, "platform", "", "platform to resolve from multi-platform images (os/arch[/variant])")
+	fs.IntVar(&lf.parallelism, "parallelism", 4, "layers analyzed concurrently")
+	fs.Int64Var(&lf.memoryBudget, "memory-budget", 0, "maximum compressed bytes of layers analyzed at once (0 for unbounded)")
+	fs.BoolVar(&lf.compression, "compression", false, "estimate layer sizes under gzip, zstd and eStargz and recommend a media type (costs CPU)")
+	fs.StringVar(&lf.cacheDir, "cache-dir", "", "directory caching layers and results across runs")
+	fs.Int64Var(&lf.cacheSize, "cache-size", 0, "maximum cache size in bytes (0 for unbounded)")
+	fs.StringVar(&lf.registries, "registries-conf", "", "registry mirror configuration in the registries.conf format")
+	fs.StringVar(&lf.certsDir, "certs-dir", "", "docker-style certs.d directory with per-registry CA and client certificates")
+	fs.Var(&lf.insecure, "insecure-registry", "registry host to reach without certificate verification or over plain HTTP (repeatable)")
+	fs.Var(&lf.plainHTTP, "plain-http", "registry host to reach over plain HTTP (repeatable)")
+	fs.StringVar(&lf.proxy, "proxy", "", "proxy URL for registry requests (default from HTTP_PROXY/HTTPS_PROXY)")
+	fs.StringVar(&lf.dockerConfig, "docker-config", "", "docker config.json to read registry credentials from")
+	fs.Var(&lf.tokenFiles, "token-file", "[host=]path of a bearer token file for a registry, or all registries (repeatable)")
+	fs.Var(&lf.credHelpers, "credential-helper", "[host=]name of a docker-credential-<name> helper for a registry, or all registries (repeatable)")
+	fs.StringVar(&lf.baseCatalog, "base-catalog", "", "JSON catalog of known base images used to attribute layers")
+	fs.StringVar(&lf.metricsFile, "metrics-file", "", "write Prometheus metrics to this file after each load (textfile collector)")
+	fs.StringVar(&lf.output, "o", formatText, "output format: text, json or yaml")
+
+	return fs, lf
+}
+
+// validate checks flag values that the flag package cannot
+// Files named by flags are read when the options are built
+func (lf *loadFlags) validate() error {
+	switch lf.output {
+	case formatText, formatJSON, formatYAML:
+	default:
+		return fmt.Errorf("unsupported output format %q", lf.output)
+	}
+	if lf.parallelism < 1 {
+		return fmt.Errorf("parallelism must be at least 1, got %d", lf.parallelism)
+	}
+	if lf.memoryBudget < 0 {
+		return fmt.Errorf("memory budget cannot be negative, got %d", lf.memoryBudget)
+	}
+	if lf.platform != "" {
+		if _, err := v1.ParsePlatform(lf.platform); err != nil {
+			return fmt.Errorf("invalid platform %q: %w", lf.platform, err)
+		}
+	}
+	if lf.proxy != "" {
+		if u, err := url.Parse(lf.proxy); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q", lf.proxy)
+		}
+	}
+	for _, v := range append(append(stringList{}, lf.tokenFiles...), lf.credHelpers...) {
+		if _, value := splitHost(v); value == "" {
+			return fmt.Errorf("invalid credential flag value %q, want [host=]value", v)
+		}
+	}
+	return nil
+}
+
+// options converts the flags into engine options
+// They are built on first use and shared by later calls; callers may
+// append to the returned slice
+func (lf *loadFlags) options() ([]slimmer.Option, error) {
+	lf.optsOnce.Do(func() {
+		lf.opts, lf.optsErr = lf.buildOptions()
+	})
+	return slices.Clip(lf.opts), lf.optsErr
+}
+
+// buildOptions implements options
+func (lf *loadFlags) buildOptions() ([]slimmer.Option, error) {
+	opts := []slimmer.Option{
+		slimmer.WithTimeout(lf.timeout),
+		slimmer.WithRetries(lf.retries),
+		slimmer.WithBackoff(lf.backoff),
+		slimmer.WithMetadataOnly(lf.metadataOnly),
+		slimmer.WithParallelism(lf.parallelism),
+		slimmer.WithMemoryBudget(lf.memoryBudget),
+		slimmer.WithCompressionEstimates(lf.compression),
+	}
+
+	if lf.breaker != nil {
+		opts = append(opts, slimmer.WithCircuitBreaker(lf.breaker))
+	}
+
+	if lf.platform != "" {
+		// Validated with the other flags
+		p, _ := v1.ParsePlatform(lf.platform)
+		opts = append(opts, slimmer.WithPlatform(*p))
+	}
+
+	if sources := lf.credentialSources(); len(sources) > 0 {
+		opts = append(opts, slimmer.WithCredentials(sources...))
+	}
+
+	if lf.baseCatalog != "" {
+		cat, err := slimmer.LoadBaseCatalog(lf.baseCatalog)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, slimmer.WithBaseCatalog(cat))
+	}
+
+	if lf.registries != "" {
+		c, err := slimmer.LoadRegistriesConfig(lf.registries)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, slimmer.WithMirrors(c))
+	}
+
+	if lf.cacheDir != "" {
+		c, err := slimmer.NewFSCache(lf.cacheDir, lf.cacheSize)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, slimmer.WithCache(c))
+	}
+
+	hosts, err := lf.registryHosts()
+	if err != nil {
+		return nil, err
+	}
+	if hosts != nil {
+		opts = append(opts, slimmer.WithRegistryHosts(hosts))
+	}
+
+	return opts, nil
+}
+
+// credentialSources converts the credential flags into sources, most
+// specific first and the default keychain last; nil when none is set
+func (lf *loadFlags) credentialSources() []slimmer.CredentialSource {
+	if lf.dockerConfig == "" && len(lf.tokenFiles) == 0 && len(lf.credHelpers) == 0 {
+		return nil
+	}
+
+	var sources []slimmer.CredentialSource
+	for _, v := range lf.tokenFiles {
+		host, path := splitHost(v)
+		sources = append(sources, slimmer.TokenFileCredentials(host, path))
+	}
+	for _, v := range lf.credHelpers {
+		host, helper := splitHost(v)
+		sources = append(sources, slimmer.HelperCredentials(host, helper))
+	}
+	if lf.dockerConfig != "" {
+		sources = append(sources, slimmer.DockerConfigCredentials(lf.dockerConfig))
+	}
+
+	return append(sources, slimmer.DefaultCredentials())
+}
+
+// splitHost splits a "[host=]value" flag; host is empty when absent
+func splitHost(v string) (string, string) {
+	if host, value, ok := strings.Cut(v, "="); ok {
+		return host, value
+	}
+	return "", v
+}
+
+// registryHosts builds the TLS, plain-HTTP and proxy settings, or nil
+// when none are configured
+func (lf *loadFlags) registryHosts() (*slimmer.RegistryHosts, error) {
+	if lf.certsDir == "" && len(lf.insecure) == 0 && len(lf.plainHTTP) == 0 && lf.proxy == "" {
+		return nil, nil
+	}
+
+	hosts := slimmer.NewRegistryHosts()
+	if lf.certsDir != "" {
+		var err error
+		if hosts, err = slimmer.LoadCertsDir(lf.certsDir); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range lf.insecure {
+		hosts.Host(h).Insecure = true
+	}
+	for _, h := range lf.plainHTTP {
+		hosts.Host(h).PlainHTTP = true
+	}
+	if lf.proxy != "" {
+		// Validated with the other flags
+		hosts.Proxy, _ = url.Parse(lf.proxy)
+	}
+	return hosts, nil
+}
+
+// load resolves and analyzes ref using the configured options
+// extra options apply on top of the flags
+// In metadata-only mode the engine skips the planning stages
+func (lf *loadFlags) load(ctx context.Context, ref string, extra ...slimmer.Option) (*slimmer.Result, error) {
+	opts, err := lf.options()
+	if err != nil {
+		return nil, err
+	}
+
+	if lf.recorder == nil && lf.metricsFile != "" {
+		lf.recorder = telemetry.NewRecorder()
+	}
+	if lf.recorder == nil {
+		return slimmer.New(opts...).Slim(ctx, ref, extra...)
+	}
+
+	opts = append(opts, slimmer.WithMetricsHook(lf.recorder.ObserveFetch))
+	result, err := slimmer.New(opts...).Slim(ctx, ref, extra...)
+	lf.recorder.ObserveResult(result, err)
+
+	if lf.metricsFile != "" {
+		if werr := lf.recorder.WriteTextfile(lf.metricsFile); werr != nil && err == nil {
+			return nil, fmt.Errorf("write metrics: %w", werr)
+		}
+	}
+
+	return result, err
+}
+
+// parse parses args and enforces the expected number of positional arguments
+func parse(fs *flag.FlagSet, lf *loadFlags, args []string, nargs int, stderr io.Writer) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	if nargs >= 0 && fs.NArg() != nargs {
+		fmt.Fprintf(stderr, "%s: expected %d argument(s), got %d\n", fs.Name(), nargs, fs.NArg())
+		return false
+	}
+	if err := lf.validate(); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", fs.Name(), err)
+		return false
+	}
+	return true
+}
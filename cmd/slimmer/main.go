@@ -0,0 +1,124 @@
+// Command slimmer analyzes container images and produces slimming plans
+//
+// Usage:
+//
+//	slimmer <command> [flags] <args>
+//
+// Commands:
+//
+//	analyze <ref>          load an image and print the full analysis result
+//	plan <ref>             print the slimming plan for an image
+//	plan -history <old> <ref>
+//	                       also recommend layer reorders from earlier tags
+//	diff <ref-a> <ref-b>   compare the layers, files and packages of two images
+//	diff -plans <a> <b>    compare the actions, risks and savings of two plans
+//	report <ref>           print a condensed size and content report
+//	check <ref>            evaluate an image against a policy (CI gate)
+//	apply -plan <file>     extract an image filesystem honoring a plan
+//	serve                  expose analysis over HTTP
+//	schema                 print the JSON Schema of the result document
+//
+// JSON output of analyze follows the versioned document described by
+// "slimmer schema"; it is canonical and free of wall-clock fields unless
+// -timings is given, so outputs can be compared byte for byte.
+//
+// Every image command accepts -timeout, -retries, -backoff, -metadata-only,
+// -platform, -parallelism, -memory-budget, -cache-dir, -cache-size,
+// -registries-conf, -certs-dir, -insecure-registry, -plain-http, -proxy,
+// -docker-config, -token-file, -credential-helper, -base-catalog,
+// -metrics-file and -o (text, json or yaml).
+//
+// Exit codes:
+//
+//	0   success
+//	1   policy violated
+//	2   invalid usage
+//	3   unclassified failure
+//	10  INVALID_REFERENCE
+//	11  IMAGE_NOT_FOUND
+//	12  UNAUTHORIZED
+//	13  TIMEOUT
+//	14  FETCH_FAILED
+//	15  RATE_LIMITED
+//	16  REPOSITORY_NOT_FOUND
+//	17  BLOB_NOT_FOUND
+//	18  ACCESS_DENIED
+//	19  CIRCUIT_OPEN
+//	20  NO_LAYERS
+//	21  BUILD_FAILED
+//	22  DIGEST_FAILED
+//	23  MEDIA_TYPE_FAILED
+//	24  SIZE_FAILED
+//	25  LAYER_EXTRACT_FAILED
+//	26  VALIDATION_FAILED
+//	30  TLS_UNKNOWN_AUTHORITY
+//	31  TLS_HOSTNAME_MISMATCH
+//	32  TLS_CERTIFICATE_INVALID
+//	33  TLS_HANDSHAKE_REJECTED
+//	34  TLS_NOT_SUPPORTED
+//	35  TLS_CONFIG_INVALID
+//	40  PLAN_DRIFT
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: slimmer <command> [flags] <args>
+
+commands:
+  analyze <ref>          load an image and print the full analysis result
+  plan <ref>             print the slimming plan for an image
+  plan -history <old> <ref>
+                         also recommend layer reorders from earlier tags
+  diff <ref-a> <ref-b>   compare the layers, files and packages of two images
+  diff -plans <a> <b>    compare the actions, risks and savings of two plans
+  report <ref>           print a condensed size and content report
+  check <ref>            evaluate an image against a policy (CI gate)
+  apply -plan <file>     extract an image filesystem honoring a plan
+  serve                  expose analysis over HTTP
+  schema                 print the JSON Schema of the result document
+
+run "slimmer <command> -h" for command flags`
+
+// command is the signature shared by all subcommands
+type command func(ctx context.Context, args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"analyze": runAnalyze,
+	"plan":    runPlan,
+	"diff":    runDiff,
+	"report":  runReport,
+	"check":   runCheck,
+	"apply":   runApply,
+	"serve":   runServe,
+	"schema":  runSchema,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run dispatches a subcommand and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprintln(stderr, usage)
+		return exitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s\n", args[0], usage)
+		return exitUsage
+	}
+
+	return cmd(ctx, args[1:], stdout, stderr)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// writeOutput renders v in the requested format
+// text is only invoked for the text format
+func writeOutput(w io.Writer, format string, v any, text func() string) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		return encodeYAML(w, v)
+	default:
+		_, err := io.WriteString(w, text())
+		return err
+	}
+}
+
+// encodeYAML writes v as YAML
+//
+// Values are first encoded as JSON so that YAML output always mirrors the
+// JSON field names; map keys are emitted in sorted order
+func encodeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	writeYAMLNode(&sb, generic, 0)
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// writeYAMLNode emits a decoded JSON value at the given indentation level
+func writeYAMLNode(sb *strings.Builder, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			sb.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeYAMLEntry(sb, pad+yamlScalar(k)+":", t[k], indent)
+		}
+	case []any:
+		if len(t) == 0 {
+			sb.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range t {
+			writeYAMLEntry(sb, pad+"-", item, indent)
+		}
+	default:
+		sb.WriteString(pad + yamlScalar(t) + "\n")
+	}
+}
+
+// writeYAMLEntry emits a key or list marker followed by its value
+func writeYAMLEntry(sb *strings.Builder, prefix string, v any, indent int) {
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			sb.WriteString(prefix + " {}\n")
+			return
+		}
+		sb.WriteString(prefix + "\n")
+		writeYAMLNode(sb, t, indent+1)
+	case []any:
+		if len(t) == 0 {
+			sb.WriteString(prefix + " []\n")
+			return
+		}
+		sb.WriteString(prefix + "\n")
+		writeYAMLNode(sb, t, indent+1)
+	default:
+		sb.WriteString(prefix + " " + yamlScalar(t) + "\n")
+	}
+}
+
+// yamlScalar renders a scalar, quoting strings that YAML would otherwise
+// interpret as another type or that contain special characters
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		if yamlNeedsQuote(t) {
+			return strconv.Quote(t)
+		}
+		return t
+	default:
+		return strconv.Quote(fmt.Sprint(t))
+	}
+}
+
+// yamlNeedsQuote reports whether a plain string is ambiguous in YAML
+func yamlNeedsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return true
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") {
+		return true
+	}
+
+	return strings.ContainsAny(s, "\n\t\"\\") ||
+		strings.Contains(s, ": ") ||
+		strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") ||
+		strings.HasSuffix(s, " ")
+}
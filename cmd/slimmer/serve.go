@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+	"github.com/pnkcaht/image-slimmer-core/pkg/telemetry"
+)
+
+// httpStatus maps analyzer error classifications to HTTP status codes
+var httpStatus = map[slimmer.ErrorCode]int{
+	slimmer.CodeInvalidReference:   http.StatusBadRequest,
+	slimmer.CodeImageNotFound:      http.StatusNotFound,
+	slimmer.CodeUnauthorized:       http.StatusForbidden,
+	slimmer.CodeTimeout:            http.StatusGatewayTimeout,
+	slimmer.CodeFetchFailed:        http.StatusBadGateway,
+	slimmer.CodeRateLimited:        http.StatusTooManyRequests,
+	slimmer.CodeRepositoryNotFound: http.StatusNotFound,
+	slimmer.CodeBlobNotFound:       http.StatusBadGateway,
+	slimmer.CodeAccessDenied:       http.StatusForbidden,
+	slimmer.CodeCircuitOpen:        http.StatusServiceUnavailable,
+
+	slimmer.CodeTLSUnknownAuthority:   http.StatusBadGateway,
+	slimmer.CodeTLSHostnameMismatch:   http.StatusBadGateway,
+	slimmer.CodeTLSCertificateInvalid: http.StatusBadGateway,
+	slimmer.CodeTLSHandshakeRejected:  http.StatusBadGateway,
+	slimmer.CodeTLSNotSupported:       http.StatusBadGateway,
+	slimmer.CodeTLSConfig:             http.StatusBadGateway,
+
+	slimmer.CodePlanDrift: http.StatusConflict,
+}
+
+// runServe exposes analyze, plan and report over HTTP
+//
+// Endpoints (all GET, JSON responses):
+//
+//	/v1/analyze?ref=<ref>
+//	/v1/plan?ref=<ref>
+//	/v1/report?ref=<ref>
+//	/healthz
+//	/metrics               Prometheus text format
+func runServe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs, lf := newFlagSet("serve", stderr)
+
+	var addr string
+	fs.StringVar(&addr, "addr", ":8080", "listen address")
+
+	if !parse(fs, lf, args, 0, stderr) {
+		return exitUsage
+	}
+
+	lf.recorder = telemetry.NewRecorder()
+
+	// A registry failing for one request fails fast for the next ones
+	lf.breaker = slimmer.NewCircuitBreaker()
+
+	// Read the files named by flags before accepting requests
+	if _, err := lf.options(); err != nil {
+		return fail(stderr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", lf.recorder.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /v1/analyze", serveResult(lf, func(r *slimmer.Result) (any, error) {
+		return r.Document(), nil
+	}))
+	mux.HandleFunc("GET /v1/plan", serveResult(lf, func(r *slimmer.Result) (any, error) {
+		if r.Plan == nil {
+			return nil, errors.New("metadata-only mode cannot produce a plan")
+		}
+		return slimmer.NewPlanDocument(r.Plan), nil
+	}))
+	mux.HandleFunc("GET /v1/report", serveResult(lf, func(r *slimmer.Result) (any, error) {
+		return slimmer.NewReport(r)
+	}))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(stdout, "listening on %s\n", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fail(stderr, err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fail(stderr, err)
+		}
+		return exitOK
+	}
+}
+
+// serveResult builds a handler that loads the "ref" query parameter and
+// renders the value selected by view as JSON
+func serveResult(lf *loadFlags, view func(*slimmer.Result) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := r.URL.Query().Get("ref")
+		if ref == "" {
+			writeJSONError(w, http.StatusBadRequest, string(slimmer.CodeInvalidReference), "missing ref query parameter")
+			return
+		}
+
+		result, err := lf.load(r.Context(), ref)
+		if err != nil {
+			writeAnalyzerError(w, err)
+			return
+		}
+
+		v, err := view(result)
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, string(slimmer.CodeUnknown), err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+// writeAnalyzerError renders err with a status derived from its ErrorCode
+func writeAnalyzerError(w http.ResponseWriter, err error) {
+	code := slimmer.CodeUnknown
+	if ae, ok := slimmer.AsAnalyzerError(err); ok {
+		code = ae.Code()
+
+		// Pass the upstream hint on, rounded up to whole seconds
+		if d := ae.RetryAfter(); d > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((d+time.Second-1)/time.Second)))
+		}
+	}
+
+	status, ok := httpStatus[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeJSONError(w, status, string(code), err.Error())
+}
+
+// writeJSONError writes a structured JSON error body
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
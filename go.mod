@@ -2,19 +2,22 @@ module github.com/pnkcaht/image-slimmer-core
 
 go 1.26
 
-require github.com/google/go-containerregistry v0.20.7
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/containerd/stargz-snapshotter/estargz v0.18.1
+	github.com/docker/cli v29.0.3+incompatible
+	github.com/docker/docker-credential-helpers v0.9.3
+	github.com/google/go-containerregistry v0.20.7
+	github.com/klauspost/compress v1.18.1
+	golang.org/x/sync v0.18.0
+)
 
 require (
-	github.com/containerd/stargz-snapshotter/estargz v0.18.1 // indirect
-	github.com/docker/cli v29.0.3+incompatible // indirect
 	github.com/docker/distribution v2.8.3+incompatible // indirect
-	github.com/docker/docker-credential-helpers v0.9.3 // indirect
-	github.com/klauspost/compress v1.18.1 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/opencontainers/go-digest v1.0.0 // indirect
 	github.com/opencontainers/image-spec v1.1.1 // indirect
 	github.com/sirupsen/logrus v1.9.3 // indirect
 	github.com/vbatts/tar-split v0.12.2 // indirect
-	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
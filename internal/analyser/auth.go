@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/pnkcaht/image-slimmer-core/internal/credentials"
+)
+
+// sourceResolver is a keychain that tells which credential source answered
+type sourceResolver interface {
+	ResolveSource(ctx context.Context, target authn.Resource) (authn.Authenticator, string, error)
+}
+
+// authTrace records the credential source used during one fetch or resolve
+// so authentication errors can name it
+type authTrace struct {
+	kc authn.Keychain
+
+	mu     sync.Mutex
+	source string
+}
+
+func newAuthTrace(kc authn.Keychain) *authTrace {
+	return &authTrace{kc: kc}
+}
+
+// Resolve implements authn.Keychain
+func (a *authTrace) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return a.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements authn.ContextKeychain
+func (a *authTrace) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	var (
+		auth   authn.Authenticator
+		source string
+		err    error
+	)
+
+	switch kc := a.kc.(type) {
+	case sourceResolver:
+		auth, source, err = kc.ResolveSource(ctx, target)
+	case credentials.Source:
+		auth, err = authn.Resolve(ctx, kc, target)
+		if auth != authn.Anonymous {
+			source = kc.Name()
+		}
+	default:
+		auth, err = authn.Resolve(ctx, kc, target)
+		if auth != authn.Anonymous {
+			source = "keychain"
+		}
+	}
+
+	a.mu.Lock()
+	a.source = source
+	a.mu.Unlock()
+
+	return auth, err
+}
+
+// annotate names the credential source in authentication errors
+func (a *authTrace) annotate(err error) error {
+	ae, ok := AsAnalyzerError(err)
+	if !ok || (ae.code != CodeUnauthorized && ae.code != CodeAccessDenied) {
+		return err
+	}
+
+	a.mu.Lock()
+	source := a.source
+	a.mu.Unlock()
+
+	if source == "" {
+		source = "anonymous"
+	}
+
+	out := *ae
+	out.message += " (credentials: " + source + ")"
+	return &out
+}
@@ -0,0 +1,157 @@
+package analyzer
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pnkcaht/image-slimmer-core/internal/catalog"
+)
+
+// OCI annotations naming the base image of an image
+const (
+	AnnotationBaseName   = "org.opencontainers.image.base.name"
+	AnnotationBaseDigest = "org.opencontainers.image.base.digest"
+)
+
+// How a base image was detected
+const (
+	// BaseFromAnnotation means the image manifest names its base
+	BaseFromAnnotation = "annotation"
+
+	// BaseFromCatalog means the image layers start with the layers of a
+	// base listed in the local catalog
+	BaseFromCatalog = "catalog"
+)
+
+// BaseImage identifies the base image an image was built from
+type BaseImage struct {
+	Name   string
+	Digest string
+
+	// Layers is the number of leading image layers that come from the base;
+	// zero when the base is named but its layers could not be matched
+	Layers int
+
+	// Source is BaseFromAnnotation or BaseFromCatalog
+	Source string
+}
+
+// IsBaseLayer reports whether the layer at index comes from the base image
+func (i *Image) IsBaseLayer(index int) bool {
+	return i.Base != nil && index >= 0 && index < i.Base.Layers
+}
+
+// diffIDs returns the diff IDs of the image layers in index order
+func (i *Image) diffIDs() []string {
+	layers := i.orderedLayers()
+	ids := make([]string, len(layers))
+	for n, l := range layers {
+		ids[n] = l.DiffID
+	}
+	return ids
+}
+
+// DetectBase attributes the leading layers of img to a base image of cat
+//
+// A base named by the image annotations is looked up in the catalog first;
+// otherwise the base whose layers are the longest prefix of the image
+// layers wins. An annotated base that cannot be matched is kept without
+// layers. It returns nil when no base is known
+func DetectBase(img *Image, cat *catalog.Catalog) *BaseImage {
+	if img == nil {
+		return nil
+	}
+
+	ids := img.diffIDs()
+
+	if annotated := img.Base; annotated != nil && annotated.Source == BaseFromAnnotation {
+		if annotated.Layers > 0 {
+			return annotated
+		}
+		if b, ok := cat.Lookup(annotated.Name, annotated.Digest); ok && catalog.IsPrefix(b.DiffIDs, ids) {
+			out := *annotated
+			out.Layers = len(b.DiffIDs)
+			return &out
+		}
+	}
+
+	if b, ok := cat.Match(ids); ok {
+		return &BaseImage{
+			Name:   b.Name,
+			Digest: b.Digest,
+			Layers: len(b.DiffIDs),
+			Source: BaseFromCatalog,
+		}
+	}
+
+	return img.Base
+}
+
+// annotatedBase reads the base image annotations of the manifest of img
+// When layers were extracted, the base is fetched to count how many of
+// them it contributes. Attribution is best effort: failures leave the base
+// named without layers and do not fail the load
+func annotatedBase(ctx context.Context, img v1.Image, layers []Layer, opts *options) *BaseImage {
+	manifest, err := img.Manifest()
+	if err != nil || manifest == nil {
+		return nil
+	}
+
+	baseName := manifest.Annotations[AnnotationBaseName]
+	baseDigest := manifest.Annotations[AnnotationBaseDigest]
+	if baseName == "" && baseDigest == "" {
+		return nil
+	}
+
+	base := &BaseImage{Name: baseName, Digest: baseDigest, Source: BaseFromAnnotation}
+	if len(layers) == 0 || baseName == "" {
+		return base
+	}
+
+	ids, err := baseDiffIDs(ctx, img, base, opts)
+	if err != nil {
+		return base
+	}
+
+	own := (&Image{Layers: layers}).diffIDs()
+	if len(ids) > 0 && catalog.IsPrefix(ids, own) {
+		base.Layers = len(ids)
+	}
+	return base
+}
+
+// baseDiffIDs fetches the layer diff IDs of base for the platform of img
+func baseDiffIDs(ctx context.Context, img v1.Image, base *BaseImage, opts *options) ([]string, error) {
+	ref := base.Name
+	if base.Digest != "" {
+		if r, err := name.ParseReference(base.Name); err == nil {
+			ref = r.Context().Name() + "@" + base.Digest
+		}
+	}
+
+	// The base fetch is not part of the image load: it reports no events
+	// or metrics, and resolves the platform of the image
+	o := *opts
+	o.observer = nil
+	o.metricsHook = nil
+	if cf, err := img.ConfigFile(); err == nil && cf != nil && cf.OS != "" && cf.Architecture != "" {
+		o.platform = cf.Platform()
+	}
+
+	f, err := fetchImage(ctx, ref, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	cf, err := f.image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(cf.RootFS.DiffIDs))
+	for i, id := range cf.RootFS.DiffIDs {
+		ids[i] = id.String()
+	}
+	return ids, nil
+}
@@ -0,0 +1,211 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker tracks the health of registries across calls
+//
+// After a number of consecutive temporary failures against a registry its
+// circuit opens: calls fail fast with CodeCircuitOpen until a cool-down has
+// passed, then a single probe is let through. A successful probe closes the
+// circuit, a failed one opens it again.
+//
+// Each registry also has a retry budget shared by all calls: every temporary
+// failure spends a token, every success earns back a fraction of one, and
+// retries are only allowed while more than half of the budget is left.
+// This keeps many concurrent calls from multiplying retries against a
+// registry that is already struggling.
+//
+// A CircuitBreaker is safe for concurrent use
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	budget    float64
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuitState is the state of one registry circuit
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuit is the breaker state of a single registry
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	tokens   float64
+}
+
+// tokenRefill is the share of a retry token earned back by a success
+const tokenRefill = 0.1
+
+// BreakerOption configures a CircuitBreaker
+type BreakerOption func(*CircuitBreaker)
+
+// WithFailureThreshold sets the consecutive temporary failures that open
+// a circuit; defaults to 5
+func WithFailureThreshold(n int) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if n > 0 {
+			b.threshold = n
+		}
+	}
+}
+
+// WithCooldown sets how long an open circuit fails fast before probing;
+// defaults to 30s
+func WithCooldown(d time.Duration) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if d > 0 {
+			b.cooldown = d
+		}
+	}
+}
+
+// WithRetryBudget sets the retry tokens of each registry; defaults to 10
+func WithRetryBudget(tokens int) BreakerOption {
+	return func(b *CircuitBreaker) {
+		if tokens > 0 {
+			b.budget = float64(tokens)
+		}
+	}
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with every circuit closed
+func NewCircuitBreaker(opts ...BreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{
+		threshold: 5,
+		cooldown:  30 * time.Second,
+		budget:    10,
+		circuits:  make(map[string]*circuit),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// circuit returns the state of registry, creating it closed
+// The caller must hold b.mu
+func (b *CircuitBreaker) circuit(registry string) *circuit {
+	c, ok := b.circuits[registry]
+	if !ok {
+		c = &circuit{tokens: b.budget}
+		b.circuits[registry] = c
+	}
+	return c
+}
+
+// do runs fn unless the circuit of registry is open, and records its outcome
+// A nil CircuitBreaker always runs fn
+func (b *CircuitBreaker) do(ctx context.Context, op, ref, registry string, fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+
+	if wait, ok := b.enter(registry, time.Now()); !ok {
+		err := NewError(CodeCircuitOpen, op, ref, fmt.Sprintf("circuit open for registry %s", registry), nil)
+		err.retryAfter = wait
+		return err
+	}
+
+	err := fn()
+
+	// Calls canceled by the caller say nothing about the registry
+	b.leave(registry, err, ctx.Err() != nil, time.Now())
+	return err
+}
+
+// enter reports whether a call may proceed and, if not, how long the
+// circuit stays open
+func (b *CircuitBreaker) enter(registry string, now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(registry)
+
+	switch c.state {
+	case circuitOpen:
+		reopen := c.openedAt.Add(b.cooldown)
+		if now.Before(reopen) {
+			return reopen.Sub(now), false
+		}
+		c.state = circuitHalfOpen
+		c.probing = true
+		return 0, true
+
+	case circuitHalfOpen:
+		// One probe at a time; others wait for its verdict
+		if c.probing {
+			return b.cooldown, false
+		}
+		c.probing = true
+		return 0, true
+	}
+
+	return 0, true
+}
+
+// leave records the outcome of a call admitted by enter
+func (b *CircuitBreaker) leave(registry string, err error, canceled bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(registry)
+	probe := c.probing
+	c.probing = false
+
+	if canceled {
+		return
+	}
+
+	// Only temporary failures tell the registry is unhealthy; a missing
+	// image or a denied request is a healthy answer
+	if err != nil && isRetryable(err) {
+		c.tokens = max(c.tokens-1, 0)
+		c.failures++
+
+		if probe || c.failures >= b.threshold {
+			c.state = circuitOpen
+			c.openedAt = now
+		}
+		return
+	}
+
+	c.tokens = min(c.tokens+tokenRefill, b.budget)
+	c.failures = 0
+	c.state = circuitClosed
+}
+
+// retryGate returns the retry budget check of a call for retry
+// registries are the endpoints the call tries, the keys do records outcomes
+// under; retries are allowed while each of them has budget left
+// A nil CircuitBreaker has no budget
+func (b *CircuitBreaker) retryGate(registries ...string) func() bool {
+	if b == nil || len(registries) == 0 {
+		return nil
+	}
+
+	return func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, r := range registries {
+			if b.circuit(r).tokens <= b.budget/2 {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,217 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pnkcaht/image-slimmer-core/internal/registries"
+)
+
+var (
+	errTransient = NewError(CodeFetchFailed, "fetch", "app", "registry unavailable", nil)
+	errMissing   = NewError(CodeImageNotFound, "fetch", "app", "manifest unknown", nil)
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	const reg = "registry.example.com"
+	start := time.Unix(1_700_000_000, 0)
+
+	type step struct {
+		at       time.Duration
+		err      error
+		canceled bool
+
+		// admitted is whether enter lets the call through; outcomes of
+		// rejected calls are not recorded
+		admitted bool
+		state    circuitState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after consecutive failures",
+			steps: []step{
+				{err: errTransient, admitted: true, state: circuitClosed},
+				{err: errTransient, admitted: true, state: circuitClosed},
+				{err: errTransient, admitted: true, state: circuitOpen},
+				{at: time.Second, admitted: false, state: circuitOpen},
+			},
+		},
+		{
+			name: "success resets the count",
+			steps: []step{
+				{err: errTransient, admitted: true, state: circuitClosed},
+				{err: errTransient, admitted: true, state: circuitClosed},
+				{admitted: true, state: circuitClosed},
+				{err: errTransient, admitted: true, state: circuitClosed},
+				{err: errTransient, admitted: true, state: circuitClosed},
+			},
+		},
+		{
+			name: "healthy answers do not count",
+			steps: []step{
+				{err: errMissing, admitted: true, state: circuitClosed},
+				{err: errMissing, admitted: true, state: circuitClosed},
+				{err: errMissing, admitted: true, state: circuitClosed},
+			},
+		},
+		{
+			name: "canceled calls do not count",
+			steps: []step{
+				{err: context.Canceled, canceled: true, admitted: true, state: circuitClosed},
+				{err: errTransient, canceled: true, admitted: true, state: circuitClosed},
+				{err: errTransient, canceled: true, admitted: true, state: circuitClosed},
+				{err: errTransient, canceled: true, admitted: true, state: circuitClosed},
+			},
+		},
+		{
+			name: "successful probe closes",
+			steps: []step{
+				{err: errTransient, admitted: true},
+				{err: errTransient, admitted: true},
+				{err: errTransient, admitted: true, state: circuitOpen},
+				{at: time.Minute, admitted: true, state: circuitClosed},
+				{at: time.Minute, admitted: true, state: circuitClosed},
+			},
+		},
+		{
+			name: "failed probe opens again",
+			steps: []step{
+				{err: errTransient, admitted: true},
+				{err: errTransient, admitted: true},
+				{err: errTransient, admitted: true, state: circuitOpen},
+				{at: time.Minute, err: errTransient, admitted: true, state: circuitOpen},
+				{at: time.Minute + time.Second, admitted: false, state: circuitOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(WithFailureThreshold(3), WithCooldown(30*time.Second))
+
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				_, ok := b.enter(reg, now)
+				if ok != s.admitted {
+					t.Fatalf("step %d: admitted = %t, want %t", i, ok, s.admitted)
+				}
+				if ok {
+					b.leave(reg, s.err, s.canceled, now)
+				}
+				if got := b.circuits[reg].state; got != s.state {
+					t.Fatalf("step %d: state = %d, want %d", i, got, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	const reg = "registry.example.com"
+	now := time.Unix(1_700_000_000, 0)
+
+	b := NewCircuitBreaker(WithFailureThreshold(1), WithCooldown(time.Second))
+	b.enter(reg, now)
+	b.leave(reg, errTransient, false, now)
+
+	later := now.Add(2 * time.Second)
+	if _, ok := b.enter(reg, later); !ok {
+		t.Fatal("probe not admitted after the cool-down")
+	}
+	if wait, ok := b.enter(reg, later); ok || wait <= 0 {
+		t.Fatalf("second call during the probe: admitted = %t, wait = %s", ok, wait)
+	}
+}
+
+func TestCircuitBreakerDoFailsFast(t *testing.T) {
+	b := NewCircuitBreaker(WithFailureThreshold(1), WithCooldown(time.Hour))
+
+	calls := 0
+	fn := func() error {
+		calls++
+		return errTransient
+	}
+
+	if err := b.do(context.Background(), "fetch", "app", "registry.example.com", fn); !errors.Is(err, errTransient) {
+		t.Fatalf("first call = %v, want the registry error", err)
+	}
+	err := b.do(context.Background(), "fetch", "app", "registry.example.com", fn)
+	ae := assertCode(t, err, CodeCircuitOpen)
+	if calls != 1 {
+		t.Errorf("fn ran %d times, want 1", calls)
+	}
+	if ae.RetryAfter() <= 0 || ae.RetryAfter() > time.Hour {
+		t.Errorf("RetryAfter = %s, want the remaining cool-down", ae.RetryAfter())
+	}
+
+	// Other registries are unaffected
+	if err := b.do(context.Background(), "fetch", "app", "other.example.com", func() error { return nil }); err != nil {
+		t.Errorf("other registry = %v", err)
+	}
+}
+
+func TestRetryGate(t *testing.T) {
+	if gate := (*CircuitBreaker)(nil).retryGate("registry.example.com"); gate != nil {
+		t.Error("nil breaker has a retry gate")
+	}
+
+	b := NewCircuitBreaker(WithFailureThreshold(100), WithRetryBudget(4))
+	gate := b.retryGate("mirror.internal", "registry.example.com")
+	now := time.Now()
+
+	if !gate() {
+		t.Fatal("retries refused with a full budget")
+	}
+
+	// Two failures leave half of the budget, which is not enough
+	b.leave("mirror.internal", errTransient, false, now)
+	if !gate() {
+		t.Fatal("retries refused with 3 of 4 tokens")
+	}
+	b.leave("mirror.internal", errTransient, false, now)
+	if gate() {
+		t.Fatal("retries allowed with the mirror budget spent")
+	}
+
+	// Successes earn tokens back
+	for range 11 {
+		b.leave("mirror.internal", nil, false, now)
+	}
+	if !gate() {
+		t.Fatal("retries refused after successes refilled the budget")
+	}
+}
+
+func TestEndpointRegistriesKeyTheRetryGate(t *testing.T) {
+	opts := defaultOptions()
+	opts.mirrors = &registries.Config{Registries: []registries.Registry{{
+		Prefix:  "registry.example.com",
+		Mirrors: []registries.Mirror{{Location: "mirror.internal/example"}},
+	}}}
+	opts.breaker = NewCircuitBreaker(WithFailureThreshold(100), WithRetryBudget(2))
+
+	const ref = "registry.example.com/app:1"
+	regs := endpointRegistries(ref, opts)
+	if want := []string{"mirror.internal", "registry.example.com"}; !reflect.DeepEqual(regs, want) {
+		t.Fatalf("endpointRegistries = %v, want %v", regs, want)
+	}
+
+	// Failures fetchImage records against the mirror spend the budget
+	// that gates retries of the whole fetch
+	gate := opts.breaker.retryGate(regs...)
+	_ = opts.breaker.do(context.Background(), "fetch", ref, regs[0], func() error { return errTransient })
+	if gate() {
+		t.Error("retries allowed after the mirror spent its budget")
+	}
+
+	if regs := endpointRegistries("not a reference", opts); regs != nil {
+		t.Errorf("endpointRegistries of an invalid reference = %v, want nil", regs)
+	}
+}
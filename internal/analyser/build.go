@@ -1,12 +1,15 @@
 package analyzer
 
 import (
+	"context"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // buildImage constructs a structured Image from a resolved v1.Image
+// It also returns the metrics of every extracted layer; blobs, when set,
+// lets eStargz layers be listed without downloading them
 //
 // This function represents the internal build boundary of the analyzer
 // All external errors are normalized into AnalyzerError
@@ -15,12 +18,12 @@ import (
 //   - No raw registry errors leak outside
 //   - Strict structural validation
 //   - Deterministic metadata extraction
-func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
+func buildImage(ctx context.Context, ref string, img v1.Image, blobs *blobReader, opts *options) (*Image, []LayerMetrics, error) {
 	const op = "build"
 
 	// ---- NIL IMAGE CHECK ----
 	if img == nil {
-		return nil, NewError(
+		return nil, nil, NewError(
 			CodeBuildFailed,
 			op,
 			ref,
@@ -32,7 +35,7 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 	// ---- DIGEST ----
 	digest, err := img.Digest()
 	if err != nil {
-		return nil, NewError(
+		return nil, nil, NewError(
 			CodeDigestFailed,
 			op,
 			ref,
@@ -44,7 +47,7 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 	// ---- MEDIA TYPE ----
 	mediaType, err := img.MediaType()
 	if err != nil {
-		return nil, NewError(
+		return nil, nil, NewError(
 			CodeMediaTypeFailed,
 			op,
 			ref,
@@ -56,7 +59,7 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 	// ---- SIZE ----
 	size, err := img.Size()
 	if err != nil {
-		return nil, NewError(
+		return nil, nil, NewError(
 			CodeSizeFailed,
 			op,
 			ref,
@@ -67,11 +70,32 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 
 	// ---- LAYERS (optional) ----
 	var structuredLayers []Layer
+	var layerMetrics []LayerMetrics
+	var entrypoint, cmd []string
+	var platform string
 
 	if !opts.metadataOnly {
+		// ---- CONFIG ----
+		cf, err := img.ConfigFile()
+		if err != nil {
+			return nil, nil, NewError(
+				CodeBuildFailed,
+				op,
+				ref,
+				"failed to retrieve image config",
+				err,
+			)
+		}
+		if cf != nil {
+			entrypoint, cmd = cf.Config.Entrypoint, cf.Config.Cmd
+			if cf.OS != "" && cf.Architecture != "" {
+				platform = cf.Platform().String()
+			}
+		}
+
 		rawLayers, err := img.Layers()
 		if err != nil {
-			return nil, NewError(
+			return nil, nil, NewError(
 				CodeBuildFailed,
 				op,
 				ref,
@@ -81,7 +105,7 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 		}
 
 		if len(rawLayers) == 0 {
-			return nil, NewError(
+			return nil, nil, NewError(
 				CodeNoLayers,
 				op,
 				ref,
@@ -91,9 +115,10 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 		}
 
 		// ---- EXTRACT LAYERS ----
-		structuredLayers, err = ExtractLayers(rawLayers, ref)
+		src := layerSource{tocs: estargzTOCs(img), blobs: blobs}
+		structuredLayers, layerMetrics, err = extractLayers(ctx, rawLayers, ref, src, opts)
 		if err != nil {
-			return nil, NewError(
+			return nil, nil, NewError(
 				CodeLayerExtract,
 				op,
 				ref,
@@ -111,5 +136,11 @@ func buildImage(ref string, img v1.Image, opts *options) (*Image, error) {
 		Size:      size,
 		Layers:    structuredLayers,
 		LoadedAt:  time.Now(),
-	}, nil
+
+		Entrypoint: entrypoint,
+		Cmd:        cmd,
+		Platform:   platform,
+
+		MetadataOnly: opts.metadataOnly,
+	}, layerMetrics, nil
 }
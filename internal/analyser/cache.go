@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/pnkcaht/image-slimmer-core/internal/cache"
+)
+
+// layerAnalysisVersion invalidates cached layer analysis when indexing changes
+const layerAnalysisVersion = "v5"
+
+// cachedAnalysis is the cached form of a layer index
+type cachedAnalysis struct {
+	UncompressedSize    int64
+	Files               []FileEntry
+	Packages            []Package
+	Estimates           []CompressionEstimate `json:",omitempty"`
+	Compression         string                `json:",omitempty"`
+	InterpretersUnknown bool                  `json:",omitempty"`
+}
+
+// loadLayerAnalysis returns the cached index of the layer with digest
+// Any cache failure is treated as a miss
+func loadLayerAnalysis(store cache.Cache, digest string) (*layerIndex, bool) {
+	if store == nil {
+		return nil, false
+	}
+
+	rc, err := store.Get(cache.Key(cache.KindLayer, layerAnalysisVersion+"/"+digest))
+	if err != nil {
+		return nil, false
+	}
+	defer rc.Close()
+
+	var a cachedAnalysis
+	if err := json.NewDecoder(rc).Decode(&a); err != nil {
+		return nil, false
+	}
+
+	return &layerIndex{
+		size:                a.UncompressedSize,
+		files:               a.Files,
+		packages:            a.Packages,
+		estimates:           a.Estimates,
+		compression:         a.Compression,
+		interpretersUnknown: a.InterpretersUnknown,
+	}, true
+}
+
+// storeLayerAnalysis records the index of the layer with digest
+// The cache is an optimization, so failures are ignored
+func storeLayerAnalysis(store cache.Cache, digest string, idx *layerIndex) {
+	if store == nil {
+		return
+	}
+
+	data, err := json.Marshal(cachedAnalysis{
+		UncompressedSize:    idx.size,
+		Files:               idx.files,
+		Packages:            idx.packages,
+		Estimates:           idx.estimates,
+		Compression:         idx.compression,
+		InterpretersUnknown: idx.interpretersUnknown,
+	})
+	if err != nil {
+		return
+	}
+
+	_ = store.Put(cache.Key(cache.KindLayer, layerAnalysisVersion+"/"+digest), bytes.NewReader(data))
+}
+
+// cacheLayers wraps layers so their blobs are read through store
+func cacheLayers(layers []v1.Layer, store cache.Cache) []v1.Layer {
+	if store == nil {
+		return layers
+	}
+
+	out := make([]v1.Layer, len(layers))
+	for i, l := range layers {
+		out[i] = &cachedLayer{Layer: l, store: store}
+	}
+	return out
+}
+
+// cachedLayer serves the compressed blob from the cache and fills
+// the cache on the first complete download
+type cachedLayer struct {
+	v1.Layer
+	store cache.Cache
+}
+
+// Compressed implements v1.Layer
+func (l *cachedLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := l.Layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	key := cache.Key(cache.KindBlob, digest.String())
+
+	if rc, err := l.store.Get(key); err == nil {
+		return rc, nil
+	}
+
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+
+	return newTeeBlob(rc, l.store, key, digest), nil
+}
+
+// Uncompressed implements v1.Layer by decompressing the cached blob
+func (l *cachedLayer) Uncompressed() (io.ReadCloser, error) {
+	ul, err := partial.CompressedToLayer(l)
+	if err != nil {
+		return nil, err
+	}
+	return ul.Uncompressed()
+}
+
+// errIncompleteBlob aborts a cache write when the blob was not fully read
+var errIncompleteBlob = errors.New("blob not fully read")
+
+// teeBlob copies a downloaded blob into the cache while it is read
+// The entry is only committed if the blob was read to the end and
+// its content matches the expected digest
+type teeBlob struct {
+	rc     io.ReadCloser
+	pw     *io.PipeWriter
+	hash   io.Writer
+	sum    func() string
+	want   v1.Hash
+	eof    bool
+	failed bool
+	done   chan struct{}
+}
+
+func newTeeBlob(rc io.ReadCloser, store cache.Cache, key string, want v1.Hash) *teeBlob {
+	pr, pw := io.Pipe()
+	h := sha256.New()
+
+	t := &teeBlob{
+		rc:   rc,
+		pw:   pw,
+		hash: h,
+		sum:  func() string { return hex.EncodeToString(h.Sum(nil)) },
+		want: want,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(t.done)
+		// Put fails when the pipe is closed with an error, discarding the entry
+		_ = store.Put(key, pr)
+		pr.Close()
+	}()
+
+	return t
+}
+
+// Read implements io.Reader
+func (t *teeBlob) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	if n > 0 && !t.failed {
+		t.hash.Write(p[:n])
+		if _, werr := t.pw.Write(p[:n]); werr != nil {
+			// The cache gave up; keep serving the download
+			t.failed = true
+		}
+	}
+	if err == io.EOF {
+		t.eof = true
+	}
+	return n, err
+}
+
+// Close implements io.Closer and commits or discards the cache entry
+func (t *teeBlob) Close() error {
+	switch {
+	case !t.eof:
+		t.pw.CloseWithError(errIncompleteBlob)
+	case t.want.Algorithm != "sha256" || t.sum() != t.want.Hex:
+		t.pw.CloseWithError(errors.New("blob digest mismatch"))
+	default:
+		t.pw.Close()
+	}
+	<-t.done
+
+	return t.rc.Close()
+}
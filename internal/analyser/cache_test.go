@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pnkcaht/image-slimmer-core/internal/cache"
+)
+
+func TestTeeBlobCommitsVerifiedBlobs(t *testing.T) {
+	blob := []byte("layer blob content")
+	digest, _, err := v1.SHA256(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := v1.SHA256(bytes.NewReader([]byte("other content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want v1.Hash
+
+		// read is how many bytes are read before closing; -1 reads to EOF
+		read   int
+		stored bool
+	}{
+		{name: "complete and matching", want: digest, read: -1, stored: true},
+		{name: "digest mismatch", want: other, read: -1},
+		{name: "unsupported algorithm", want: v1.Hash{Algorithm: "sha512", Hex: digest.Hex}, read: -1},
+		{name: "partial read", want: digest, read: 5},
+		{name: "not read", want: digest, read: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := cache.NewFS(t.TempDir(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := cache.Key(cache.KindBlob, digest.String())
+
+			tb := newTeeBlob(io.NopCloser(bytes.NewReader(blob)), store, key, tt.want)
+			if tt.read < 0 {
+				got, err := io.ReadAll(tb)
+				if err != nil || !bytes.Equal(got, blob) {
+					t.Fatalf("read %q, %v; want the whole blob", got, err)
+				}
+			} else if _, err := io.ReadFull(tb, make([]byte, tt.read)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tb.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rc, err := store.Get(key)
+			if !tt.stored {
+				if !errors.Is(err, cache.ErrMiss) {
+					t.Fatalf("Get = %v, want a miss", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get = %v, want the blob", err)
+			}
+			defer rc.Close()
+			if got, _ := io.ReadAll(rc); !bytes.Equal(got, blob) {
+				t.Errorf("cached %q, want %q", got, blob)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats of CompressionEstimate and Layer.Compression
+const (
+	CompressionGzip    = "gzip"
+	CompressionZstd    = "zstd"
+	CompressionEstargz = "estargz"
+
+	// CompressionNone is the compression of uncompressed layers
+	CompressionNone = "none"
+)
+
+// ZstdLevels are the zstd levels layers are estimated at
+var ZstdLevels = []int{1, 3, 7}
+
+// gzipLevel is the level gzip and eStargz are estimated at, the default
+// of most image builders
+const gzipLevel = 6
+
+const (
+	// estargzEntryOverhead approximates the bytes eStargz adds per entry:
+	// a gzip member header and trailer per file and a compressed TOC entry
+	estargzEntryOverhead = 128
+
+	// estargzFooterSize is the size of the eStargz footer
+	estargzFooterSize = 51
+)
+
+// incompressibleExts are extensions of files whose content is already
+// compressed, so recompressing the layer gains little on them
+var incompressibleExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true,
+	".mp3": true, ".mp4": true, ".mkv": true, ".webm": true, ".ogg": true,
+	".zip": true, ".jar": true, ".war": true, ".ear": true, ".whl": true, ".apk": true,
+	".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true, ".lz4": true,
+	".7z": true, ".rar": true, ".br": true, ".woff": true, ".woff2": true,
+}
+
+// CompressionEstimate is the estimated compressed size of a layer
+type CompressionEstimate struct {
+	Format string
+
+	// Level is the compression level; eStargz is estimated at the gzip level
+	Level int
+
+	Size int64
+}
+
+// IsIncompressible reports whether the file at p holds already compressed
+// content, judged by its extension
+func IsIncompressible(p string) bool {
+	return incompressibleExts[strings.ToLower(path.Ext(p))]
+}
+
+// IncompressibleBytes is the size of the regular files of the layer whose
+// content is already compressed, such as images, archives and jars
+func (l Layer) IncompressibleBytes() int64 {
+	var n int64
+	for _, f := range l.Files {
+		if f.Type == FileTypeRegular && !f.Whiteout && IsIncompressible(f.Path) {
+			n += f.Size
+		}
+	}
+	return n
+}
+
+// IsIncompressible reports whether most of the layer is already compressed
+func (l Layer) IsIncompressible() bool {
+	return l.UncompressedSize > 0 && l.IncompressibleBytes()*2 >= l.UncompressedSize
+}
+
+// Estimate returns the estimate of the layer for format and level
+func (l Layer) Estimate(format string, level int) (CompressionEstimate, bool) {
+	for _, e := range l.Estimates {
+		if e.Format == format && e.Level == level {
+			return e, true
+		}
+	}
+	return CompressionEstimate{}, false
+}
+
+// countingWriter counts bytes written through it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// compressionEstimator recompresses a layer stream as it is indexed,
+// counting the output of every format without keeping it
+type compressionEstimator struct {
+	gzip      *gzip.Writer
+	gzipSize  *countingWriter
+	zstd      []*zstd.Encoder
+	zstdSizes []*countingWriter
+}
+
+// newCompressionEstimator creates encoders for gzip and every ZstdLevels level
+func newCompressionEstimator() (*compressionEstimator, error) {
+	e := &compressionEstimator{gzipSize: &countingWriter{}}
+
+	gz, err := gzip.NewWriterLevel(e.gzipSize, gzipLevel)
+	if err != nil {
+		return nil, err
+	}
+	e.gzip = gz
+
+	for _, level := range ZstdLevels {
+		w := &countingWriter{}
+		enc, err := zstd.NewWriter(w,
+			zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+			zstd.WithEncoderConcurrency(1),
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.zstd = append(e.zstd, enc)
+		e.zstdSizes = append(e.zstdSizes, w)
+	}
+	return e, nil
+}
+
+// Write implements io.Writer, feeding p to every encoder
+func (e *compressionEstimator) Write(p []byte) (int, error) {
+	if _, err := e.gzip.Write(p); err != nil {
+		return 0, err
+	}
+	for _, enc := range e.zstd {
+		if _, err := enc.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// finish flushes the encoders and returns the estimates; entries is the
+// number of regular files, which eStargz compresses separately
+func (e *compressionEstimator) finish(entries int) ([]CompressionEstimate, error) {
+	if err := e.gzip.Close(); err != nil {
+		return nil, err
+	}
+
+	estimates := []CompressionEstimate{
+		{Format: CompressionGzip, Level: gzipLevel, Size: e.gzipSize.n},
+		{Format: CompressionEstargz, Level: gzipLevel, Size: e.gzipSize.n + int64(entries)*estargzEntryOverhead + estargzFooterSize},
+	}
+
+	for i, enc := range e.zstd {
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, CompressionEstimate{
+			Format: CompressionZstd,
+			Level:  ZstdLevels[i],
+			Size:   e.zstdSizes[i].n,
+		})
+	}
+	e.zstd = nil
+	return estimates, nil
+}
+
+// close releases the encoders left open by a failed pass
+func (e *compressionEstimator) close() {
+	for _, enc := range e.zstd {
+		enc.Close()
+	}
+}
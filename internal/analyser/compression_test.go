@@ -0,0 +1,79 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"strings"
+	"testing"
+)
+
+func TestIndexLayerEstimates(t *testing.T) {
+	text := strings.Repeat("the same line of configuration\n", 2000)
+	layer := layerTar(t,
+		tarEntry{name: "etc", typ: tar.TypeDir},
+		tarEntry{name: "etc/a.conf", typ: tar.TypeReg, content: text},
+		tarEntry{name: "etc/b.conf", typ: tar.TypeReg, content: text},
+		tarEntry{name: "etc/link", typ: tar.TypeSymlink, linkname: "a.conf"},
+	)
+
+	idx, err := indexLayer(layer, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz, ok := (Layer{Estimates: idx.estimates}).Estimate(CompressionGzip, gzipLevel)
+	if !ok || gz.Size <= 0 || gz.Size >= idx.size {
+		t.Fatalf("gzip estimate = %+v (found %t), want a size below %d", gz, ok, idx.size)
+	}
+
+	// eStargz adds per-file overhead for the two regular files and a footer
+	stargz, ok := (Layer{Estimates: idx.estimates}).Estimate(CompressionEstargz, gzipLevel)
+	if want := gz.Size + 2*estargzEntryOverhead + estargzFooterSize; !ok || stargz.Size != want {
+		t.Errorf("eStargz estimate = %+v (found %t), want size %d", stargz, ok, want)
+	}
+
+	for _, level := range ZstdLevels {
+		zst, ok := (Layer{Estimates: idx.estimates}).Estimate(CompressionZstd, level)
+		if !ok || zst.Size <= 0 || zst.Size >= idx.size {
+			t.Errorf("zstd level %d estimate = %+v (found %t), want a size below %d", level, zst, ok, idx.size)
+		}
+	}
+
+	if want := 2 + len(ZstdLevels); len(idx.estimates) != want {
+		t.Errorf("%d estimates, want %d", len(idx.estimates), want)
+	}
+
+	idx, err = indexLayer(layerTar(t, tarEntry{name: "a", typ: tar.TypeReg, content: text}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.estimates != nil {
+		t.Errorf("estimates without asking: %+v", idx.estimates)
+	}
+}
+
+func TestLayerIsIncompressible(t *testing.T) {
+	file := func(p string, size int64) FileEntry {
+		return FileEntry{Path: p, Type: FileTypeRegular, Size: size}
+	}
+
+	tests := []struct {
+		name  string
+		layer Layer
+		want  bool
+	}{
+		{name: "empty layer", layer: Layer{}},
+		{name: "text", layer: Layer{UncompressedSize: 1000, Files: []FileEntry{file("app/main.js", 900)}}},
+		{name: "mostly archives", layer: Layer{UncompressedSize: 1000, Files: []FileEntry{file("app/lib.jar", 600), file("app/main.js", 300)}}, want: true},
+		{name: "extension case", layer: Layer{UncompressedSize: 1000, Files: []FileEntry{file("img/LOGO.PNG", 500)}}, want: true},
+		{name: "whiteouts do not count", layer: Layer{UncompressedSize: 1000, Files: []FileEntry{{Path: "app/lib.jar", Type: FileTypeRegular, Size: 600, Whiteout: true}}}},
+		{name: "links do not count", layer: Layer{UncompressedSize: 1000, Files: []FileEntry{{Path: "app/lib.jar", Type: FileTypeSymlink, Size: 600}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.layer.IsIncompressible(); got != tt.want {
+				t.Errorf("IsIncompressible = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// openUncompressed opens the uncompressed stream of l
+//
+// The decompressor is chosen from the first bytes of the blob rather than
+// its media type, which registries and builders do not always get right.
+// It also returns the compression found: CompressionGzip, CompressionZstd
+// or CompressionNone
+func openUncompressed(l v1.Layer) (io.ReadCloser, string, error) {
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, "", err
+	}
+
+	br := bufio.NewReader(rc)
+
+	// Blobs shorter than the magic are read as they are
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		rc.Close()
+		return nil, "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, "", err
+		}
+		return &decompressed{Reader: zr, close: func() { zr.Close() }, blob: rc}, CompressionGzip, nil
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			rc.Close()
+			return nil, "", err
+		}
+		return &decompressed{Reader: zr, close: zr.Close, blob: rc}, CompressionZstd, nil
+
+	default:
+		return &decompressed{Reader: br, close: func() {}, blob: rc}, CompressionNone, nil
+	}
+}
+
+// decompressed is a decompressing reader over a layer blob
+type decompressed struct {
+	io.Reader
+	close func()
+	blob  io.Closer
+}
+
+// Close releases the decompressor and closes the blob
+func (d *decompressed) Close() error {
+	d.close()
+	return d.blob.Close()
+}
@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+)
+
+// elfHeadSize is how much of a file is kept to find its ELF interpreter
+// Linkers place the program headers and the interpreter path right after
+// the ELF header, well within the first page
+const elfHeadSize = 4096
+
+// headWriter keeps the first bytes written through it
+type headWriter struct {
+	buf []byte
+}
+
+func (h *headWriter) Write(p []byte) (int, error) {
+	if n := elfHeadSize - len(h.buf); n > 0 {
+		h.buf = append(h.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// elfInterpreter returns the program interpreter (PT_INTERP) of the ELF
+// executable starting with head, such as /lib/ld-musl-x86_64.so.1
+// It is empty for other files, static binaries, and interpreters beyond head
+func elfInterpreter(head []byte) string {
+	if len(head) < 52 || !bytes.HasPrefix(head, []byte(elf.ELFMAG)) {
+		return ""
+	}
+
+	var order binary.ByteOrder
+	switch elf.Data(head[elf.EI_DATA]) {
+	case elf.ELFDATA2LSB:
+		order = binary.LittleEndian
+	case elf.ELFDATA2MSB:
+		order = binary.BigEndian
+	default:
+		return ""
+	}
+
+	var phoff, phentsize, phnum uint64
+	switch elf.Class(head[elf.EI_CLASS]) {
+	case elf.ELFCLASS64:
+		if len(head) < 64 {
+			return ""
+		}
+		phoff = order.Uint64(head[32:])
+		phentsize = uint64(order.Uint16(head[54:]))
+		phnum = uint64(order.Uint16(head[56:]))
+	case elf.ELFCLASS32:
+		phoff = uint64(order.Uint32(head[28:]))
+		phentsize = uint64(order.Uint16(head[42:]))
+		phnum = uint64(order.Uint16(head[44:]))
+	default:
+		return ""
+	}
+
+	// Offsets come from the file; compare by subtraction so crafted
+	// values cannot wrap around the bounds checks
+	n := uint64(len(head))
+	if phoff > n || phentsize < 32 || phentsize > n {
+		return ""
+	}
+
+	is64 := elf.Class(head[elf.EI_CLASS]) == elf.ELFCLASS64
+	for i := uint64(0); i < phnum; i++ {
+		if i > (n-phoff)/phentsize {
+			return ""
+		}
+		off := phoff + i*phentsize
+		if phentsize > n-off {
+			return ""
+		}
+		ph := head[off : off+phentsize]
+		if elf.ProgType(order.Uint32(ph)) != elf.PT_INTERP {
+			continue
+		}
+
+		var at, size uint64
+		if is64 {
+			if len(ph) < 40 {
+				return ""
+			}
+			at, size = order.Uint64(ph[8:]), order.Uint64(ph[32:])
+		} else {
+			at, size = uint64(order.Uint32(ph[4:])), uint64(order.Uint32(ph[16:]))
+		}
+		if at > n || size > n-at {
+			return ""
+		}
+		return string(bytes.TrimRight(head[at:at+size], "\x00"))
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+)
+
+// elf64 builds the head of a little-endian 64-bit ELF file with one
+// PT_INTERP program header pointing at interp
+func elf64(phoff, phentsize, phnum uint64, interpAt, interpSize uint64, interp string) []byte {
+	head := make([]byte, 256)
+	copy(head, elf.ELFMAG)
+	head[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	head[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+
+	le := binary.LittleEndian
+	le.PutUint64(head[32:], phoff)
+	le.PutUint16(head[54:], uint16(phentsize))
+	le.PutUint16(head[56:], uint16(phnum))
+
+	if phoff <= uint64(len(head))-56 {
+		ph := head[phoff:]
+		le.PutUint32(ph, uint32(elf.PT_INTERP))
+		le.PutUint64(ph[8:], interpAt)
+		le.PutUint64(ph[32:], interpSize)
+	}
+	if interpAt <= uint64(len(head)-len(interp)) {
+		copy(head[interpAt:], interp)
+	}
+	return head
+}
+
+// elf32 builds the head of a little-endian 32-bit ELF file with one
+// PT_INTERP program header pointing at interp
+func elf32(interpAt, interpSize uint32, interp string) []byte {
+	head := make([]byte, 256)
+	copy(head, elf.ELFMAG)
+	head[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	head[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+
+	le := binary.LittleEndian
+	le.PutUint32(head[28:], 52)
+	le.PutUint16(head[42:], 32)
+	le.PutUint16(head[44:], 1)
+
+	ph := head[52:]
+	le.PutUint32(ph, uint32(elf.PT_INTERP))
+	le.PutUint32(ph[4:], interpAt)
+	le.PutUint32(ph[16:], interpSize)
+	copy(head[interpAt:], interp)
+	return head
+}
+
+func TestElfInterpreter(t *testing.T) {
+	const musl = "/lib/ld-musl-x86_64.so.1\x00"
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "64-bit", head: elf64(64, 56, 1, 128, uint64(len(musl)), musl), want: "/lib/ld-musl-x86_64.so.1"},
+		{name: "32-bit", head: elf32(128, uint32(len(musl)), musl), want: "/lib/ld-musl-x86_64.so.1"},
+		{name: "not elf", head: []byte("#!/bin/sh\necho hello, world, this is not a binary\n")},
+		{name: "empty", head: nil},
+		{name: "truncated header", head: elf64(64, 56, 1, 128, uint64(len(musl)), musl)[:40]},
+		{name: "truncated program headers", head: elf64(64, 56, 1, 128, uint64(len(musl)), musl)[:100]},
+		{name: "truncated interpreter", head: elf64(64, 56, 1, 128, uint64(len(musl)), musl)[:140]},
+		{name: "program headers beyond head", head: elf64(1<<20, 56, 1, 128, uint64(len(musl)), musl)},
+		{name: "overflowing program header offset", head: elf64(1<<64-16, 56, 1, 128, uint64(len(musl)), musl)},
+		{name: "program header count beyond head", head: func() []byte {
+			// The first header is not PT_INTERP, so the scan runs past head
+			h := elf64(64, 56, 0xffff, 128, uint64(len(musl)), musl)
+			binary.LittleEndian.PutUint32(h[64:], uint32(elf.PT_LOAD))
+			return h
+		}()},
+		{name: "small program header entries", head: elf64(64, 8, 1, 128, uint64(len(musl)), musl)},
+		{name: "overflowing interpreter offset", head: elf64(64, 56, 1, 1<<64-8, 16, musl)},
+		{name: "overflowing interpreter size", head: elf64(64, 56, 1, 128, 1<<64-64, musl)},
+		{name: "bad data encoding", head: func() []byte {
+			h := elf64(64, 56, 1, 128, uint64(len(musl)), musl)
+			h[elf.EI_DATA] = 0
+			return h
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := elfInterpreter(tt.head); got != tt.want {
+				t.Errorf("elfInterpreter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,16 @@ package analyzer
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"net"
+	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pnkcaht/image-slimmer-core/internal/credentials"
 )
 
 // MapRegistryError converts external registry-related errors into a structured AnalyzerError
@@ -32,6 +38,26 @@ func MapRegistryError(op, ref string, err error) error {
 		return NewError(CodeTimeout, op, ref, "operation canceled", err)
 	}
 
+	// Credential sources that failed before any request was authenticated
+	var srcErr *credentials.SourceError
+	if errors.As(err, &srcErr) {
+		return NewError(CodeUnauthorized, op, ref, "credential source failed", err)
+	}
+
+	// TLS failures name the misconfiguration rather than a generic network
+	// error. They come first: when a registry may use plain HTTP the
+	// client reports the TLS failure along with the HTTP fallback's
+	if tlsErr := classifyTLSError(op, ref, err); tlsErr != nil {
+		return tlsErr
+	}
+
+	// Registry responses: classified by OCI distribution error code, then
+	// by HTTP status for responses without a body (HEAD, some proxies)
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return classifyTransportError(op, ref, terr, err)
+	}
+
 	// URL-level errors (very common in registry HTTP calls)
 	var urlErr *url.Error
 	if errors.As(err, &urlErr) {
@@ -49,46 +75,100 @@ func MapRegistryError(op, ref string, err error) error {
 		return NewError(CodeFetchFailed, op, ref, "network error", err)
 	}
 
-	// Fallback classification using error message inspection
-	// Some registry implementations return inconsistent or wrapped errors
-	msg := strings.ToLower(err.Error())
+	// Final fallback classification
+	return NewError(CodeUnknown, op, ref, "unknown registry error", err)
+}
+
+// diagnosticCodes maps OCI distribution error codes to analyzer classifications
+var diagnosticCodes = map[transport.ErrorCode]struct {
+	code    ErrorCode
+	message string
+}{
+	transport.NameUnknownErrorCode:         {CodeRepositoryNotFound, "repository not found"},
+	transport.ManifestUnknownErrorCode:     {CodeImageNotFound, "manifest not found"},
+	transport.BlobUnknownErrorCode:         {CodeBlobNotFound, "blob not found"},
+	transport.ManifestBlobUnknownErrorCode: {CodeBlobNotFound, "manifest references an unknown blob"},
+	transport.UnauthorizedErrorCode:        {CodeUnauthorized, "unauthorized access"},
+	transport.DeniedErrorCode:              {CodeAccessDenied, "access denied"},
+	transport.TooManyRequestsErrorCode:     {CodeRateLimited, "rate limited"},
+	transport.NameInvalidErrorCode:         {CodeInvalidReference, "registry rejected repository name"},
+	transport.TagInvalidErrorCode:          {CodeInvalidReference, "registry rejected tag"},
+	transport.UnavailableErrorCode:         {CodeFetchFailed, "registry unavailable"},
+}
 
-	switch {
-	// Unauthorized / authentication failures
-	case strings.Contains(msg, "401"),
-		strings.Contains(msg, "unauthorized"),
-		strings.Contains(msg, "denied"):
+// classifyTransportError classifies a registry response error
+// err is the original error, kept as the cause
+func classifyTransportError(op, ref string, terr *transport.Error, err error) error {
+	// The first recognized diagnostic wins; registries list the primary cause first
+	for _, d := range terr.Errors {
+		if c, ok := diagnosticCodes[d.Code]; ok {
+			return NewError(c.code, op, ref, c.message, err)
+		}
+	}
+
+	switch status := terr.StatusCode; {
+	case status == http.StatusUnauthorized:
 		return NewError(CodeUnauthorized, op, ref, "unauthorized access", err)
 
-	// Forbidden access
-	case strings.Contains(msg, "403"),
-		strings.Contains(msg, "forbidden"):
-		return NewError(CodeUnauthorized, op, ref, "forbidden", err)
-
-	// Image not found
-	case strings.Contains(msg, "404"),
-		strings.Contains(msg, "not found"):
-		return NewError(CodeImageNotFound, op, ref, "image not found", err)
-
-	// Timeout indicated in message
-	case strings.Contains(msg, "timeout"):
-		return NewError(CodeTimeout, op, ref, "request timeout", err)
-
-	// Rate limiting
-	case strings.Contains(msg, "429"),
-		strings.Contains(msg, "too many requests"):
-		return NewError(CodeFetchFailed, op, ref, "rate limited", err)
-
-	// Registry server errors (5xx)
-	case strings.Contains(msg, "500"),
-		strings.Contains(msg, "502"),
-		strings.Contains(msg, "503"),
-		strings.Contains(msg, "504"):
+	case status == http.StatusForbidden:
+		return NewError(CodeAccessDenied, op, ref, "access denied", err)
+
+	case status == http.StatusNotFound:
+		if isBlobRequest(terr.Request) {
+			return NewError(CodeBlobNotFound, op, ref, "blob not found", err)
+		}
+		return NewError(CodeImageNotFound, op, ref, "manifest not found", err)
+
+	case status == http.StatusTooManyRequests:
+		return NewError(CodeRateLimited, op, ref, "rate limited", err)
+
+	case status == http.StatusRequestTimeout,
+		status == http.StatusGatewayTimeout:
+		return NewError(CodeTimeout, op, ref, "registry timeout", err)
+
+	case status >= 500:
 		return NewError(CodeFetchFailed, op, ref, "registry server error", err)
 	}
 
-	// Final fallback classification
-	return NewError(CodeUnknown, op, ref, "unknown registry error", err)
+	return NewError(CodeUnknown, op, ref, "unexpected registry response", err)
+}
+
+// isBlobRequest reports whether req targets the blob endpoint (/v2/<name>/blobs/<digest>)
+func isBlobRequest(req *http.Request) bool {
+	return req != nil && req.URL != nil && strings.Contains(req.URL.Path, "/blobs/")
+}
+
+// classifyTLSError classifies certificate and handshake failures
+// It returns nil when err is not TLS related
+func classifyTLSError(op, ref string, err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		record           tls.RecordHeaderError
+		opErr            *net.OpError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return NewError(CodeTLSUnknownAuthority, op, ref, "registry certificate signed by an unknown authority", err)
+
+	case errors.As(err, &hostname):
+		return NewError(CodeTLSHostnameMismatch, op, ref, "registry certificate does not match the host", err)
+
+	case errors.As(err, &invalid):
+		return NewError(CodeTLSCertificateInvalid, op, ref, "registry certificate is invalid", err)
+
+	case errors.Is(err, http.ErrSchemeMismatch), errors.As(err, &record):
+		return NewError(CodeTLSNotSupported, op, ref, "registry does not speak TLS; allow plain HTTP for the host", err)
+
+	// Alerts sent by the registry, typically about a missing or rejected
+	// client certificate
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		return NewError(CodeTLSHandshakeRejected, op, ref, "registry rejected the TLS handshake", err)
+	}
+
+	return nil
 }
 
 // classifyNetworkError normalizes lower-level transport errors into structured analyzer errors
@@ -0,0 +1,207 @@
+package analyzer
+
+import (
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const testBlobDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+// misleadingBody is an OCI error body whose text names other failures, so
+// a classification built on the message would pick the wrong code
+const misleadingBody = `{"errors":[{"code":"UNSUPPORTED","message":"unauthorized: blob not found, rate limited, timeout"}]}`
+
+// fakeRegistry serves the ping endpoint and answers every manifest and blob
+// request with status, headers and body
+func fakeRegistry(t *testing.T, status int, header http.Header, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		if body != "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fetchFake requests the manifest, or the blob, of app from the registry at
+// host and maps the failure the way fetchFrom does
+func fetchFake(t *testing.T, host string, blob bool, opts ...name.Option) error {
+	t.Helper()
+
+	rl := newRateLimit(http.DefaultTransport)
+	remoteOpts := []remote.Option{
+		remote.WithTransport(rl),
+		// One request per call: the retries of the client would only slow
+		// the test down
+		remote.WithRetryBackoff(remote.Backoff{Steps: 1}),
+	}
+
+	var err error
+	if blob {
+		ref, perr := name.NewDigest(host+"/app@"+testBlobDigest, opts...)
+		if perr != nil {
+			t.Fatal(perr)
+		}
+		layer, lerr := remote.Layer(ref, remoteOpts...)
+		if lerr != nil {
+			t.Fatal(lerr)
+		}
+		rc, lerr := layer.Compressed()
+		if rc != nil {
+			rc.Close()
+		}
+		err = lerr
+	} else {
+		ref, perr := name.NewTag(host+"/app:1", opts...)
+		if perr != nil {
+			t.Fatal(perr)
+		}
+		_, err = remote.Get(ref, remoteOpts...)
+	}
+
+	if err == nil {
+		t.Fatal("request succeeded, want an error")
+	}
+	return rl.annotate(MapRegistryError("fetch", host+"/app:1", err))
+}
+
+// assertCode checks err is an AnalyzerError with code
+func assertCode(t *testing.T, err error, code ErrorCode) *AnalyzerError {
+	t.Helper()
+
+	ae, ok := AsAnalyzerError(err)
+	if !ok {
+		t.Fatalf("error %v (%T) is not an AnalyzerError", err, err)
+	}
+	if ae.Code() != code {
+		t.Fatalf("code = %s, want %s (%v)", ae.Code(), code, err)
+	}
+	return ae
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     http.Header
+		body       string
+		blob       bool
+		want       ErrorCode
+		retryAfter time.Duration
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, want: CodeUnauthorized},
+		{name: "unauthorized diagnostic", status: http.StatusUnauthorized, body: `{"errors":[{"code":"UNAUTHORIZED","message":"access to the requested resource is not authorized"}]}`, want: CodeUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden, want: CodeAccessDenied},
+		{name: "denied diagnostic", status: http.StatusForbidden, body: `{"errors":[{"code":"DENIED","message":"requested access to the resource is denied"}]}`, want: CodeAccessDenied},
+		{name: "manifest not found", status: http.StatusNotFound, want: CodeImageNotFound},
+		{name: "manifest unknown diagnostic", status: http.StatusNotFound, body: `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`, want: CodeImageNotFound},
+		{name: "repository unknown diagnostic", status: http.StatusNotFound, body: `{"errors":[{"code":"NAME_UNKNOWN","message":"repository name not known to registry"}]}`, want: CodeRepositoryNotFound},
+		{name: "blob not found", status: http.StatusNotFound, blob: true, want: CodeBlobNotFound},
+		{name: "rate limited", status: http.StatusTooManyRequests, want: CodeRateLimited},
+		{
+			name:       "rate limited with retry after",
+			status:     http.StatusTooManyRequests,
+			header:     http.Header{"Retry-After": {"7"}},
+			want:       CodeRateLimited,
+			retryAfter: 7 * time.Second,
+		},
+		{name: "server error", status: http.StatusInternalServerError, want: CodeFetchFailed},
+		{name: "bad gateway", status: http.StatusBadGateway, want: CodeFetchFailed},
+		{
+			name:       "unavailable with retry after",
+			status:     http.StatusServiceUnavailable,
+			header:     http.Header{"Retry-After": {"3"}},
+			want:       CodeFetchFailed,
+			retryAfter: 3 * time.Second,
+		},
+		{name: "gateway timeout", status: http.StatusGatewayTimeout, want: CodeTimeout},
+		{name: "misleading body on server error", status: http.StatusInternalServerError, body: misleadingBody, want: CodeFetchFailed},
+		{name: "misleading body on not found", status: http.StatusNotFound, body: misleadingBody, want: CodeImageNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakeRegistry(t, tt.status, tt.header, tt.body)
+			host := strings.TrimPrefix(srv.URL, "http://")
+
+			err := fetchFake(t, host, tt.blob, name.Insecure)
+			ae := assertCode(t, err, tt.want)
+
+			// The classification comes from the response itself, which
+			// stays reachable as the cause
+			var terr *transport.Error
+			if !errors.As(ae, &terr) {
+				t.Fatalf("cause %v is not a transport error", ae.Unwrap())
+			}
+			if terr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", terr.StatusCode, tt.status)
+			}
+
+			if got := ae.RetryAfter(); got != tt.retryAfter {
+				t.Errorf("RetryAfter = %s, want %s", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestClassifyTLSError(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// The test server certificate is not trusted by the default transport
+	err := fetchFake(t, strings.TrimPrefix(srv.URL, "https://"), false)
+	ae := assertCode(t, err, CodeTLSUnknownAuthority)
+
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(ae, &unknown) {
+		t.Fatalf("cause %v is not an x509.UnknownAuthorityError", ae.Unwrap())
+	}
+}
+
+func TestClassifyNetworkError(t *testing.T) {
+	// Take a free port and close it so connections are refused
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := l.Addr().String()
+	l.Close()
+
+	err = fetchFake(t, host, false, name.Insecure)
+	ae := assertCode(t, err, CodeFetchFailed)
+
+	if !errors.Is(ae, syscall.ECONNREFUSED) {
+		t.Fatalf("cause %v is not a refused connection", ae.Unwrap())
+	}
+	if ae.RetryAfter() != 0 {
+		t.Errorf("RetryAfter = %s, want none", ae.RetryAfter())
+	}
+}
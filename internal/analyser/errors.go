@@ -3,6 +3,7 @@ package analyzer
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // ErrorCode represents a machine-readable classification of an analyzer error.
@@ -22,6 +23,23 @@ const (
 	CodeUnauthorized  ErrorCode = "UNAUTHORIZED"
 	CodeTimeout       ErrorCode = "TIMEOUT"
 	CodeFetchFailed   ErrorCode = "FETCH_FAILED"
+	CodeRateLimited   ErrorCode = "RATE_LIMITED"
+
+	// Registry errors refining not found and unauthorized
+	CodeRepositoryNotFound ErrorCode = "REPOSITORY_NOT_FOUND"
+	CodeBlobNotFound       ErrorCode = "BLOB_NOT_FOUND"
+	CodeAccessDenied       ErrorCode = "ACCESS_DENIED"
+
+	// Registry marked unhealthy by the circuit breaker
+	CodeCircuitOpen ErrorCode = "CIRCUIT_OPEN"
+
+	// TLS and transport configuration errors
+	CodeTLSUnknownAuthority   ErrorCode = "TLS_UNKNOWN_AUTHORITY"
+	CodeTLSHostnameMismatch   ErrorCode = "TLS_HOSTNAME_MISMATCH"
+	CodeTLSCertificateInvalid ErrorCode = "TLS_CERTIFICATE_INVALID"
+	CodeTLSHandshakeRejected  ErrorCode = "TLS_HANDSHAKE_REJECTED"
+	CodeTLSNotSupported       ErrorCode = "TLS_NOT_SUPPORTED"
+	CodeTLSConfig             ErrorCode = "TLS_CONFIG_INVALID"
 
 	// Image structure errors
 	CodeNoLayers        ErrorCode = "NO_LAYERS"
@@ -33,6 +51,9 @@ const (
 
 	CodeValidationFailed ErrorCode = "VALIDATION_FAILED"
 
+	// Plan errors
+	CodePlanDrift ErrorCode = "PLAN_DRIFT"
+
 	// Fallback classification
 	CodeUnknown ErrorCode = "UNKNOWN"
 )
@@ -45,6 +66,8 @@ type AnalyzerError struct {
 	err     error     // wrapped underlying error
 	op      string    // logical operation name (e.g., "fetch", "build")
 	ref     string    // image reference involved in the error
+
+	retryAfter time.Duration // delay requested by the registry, if any
 }
 
 // Error implements the error interface.
@@ -98,7 +121,13 @@ func (e *AnalyzerError) Reference() string {
 
 // Temporary reports whether the error is potentially retryable
 func (e *AnalyzerError) Temporary() bool {
-	return e.code == CodeTimeout || e.code == CodeFetchFailed
+	return e.code == CodeTimeout || e.code == CodeFetchFailed || e.code == CodeRateLimited
+}
+
+// RetryAfter returns how long the registry asked to wait before retrying
+// It is zero when the registry gave no hint
+func (e *AnalyzerError) RetryAfter() time.Duration {
+	return e.retryAfter
 }
 
 // Timeout reports whether the error represents a timeout condition
@@ -128,13 +157,20 @@ func Wrap(code ErrorCode, op, ref string, err error) *AnalyzerError {
 
 // These sentinel errors allow usage with errors.Is without requiring full struct matching
 var (
-	ErrInvalidReference = &AnalyzerError{code: CodeInvalidReference}
-	ErrImageNotFound    = &AnalyzerError{code: CodeImageNotFound}
-	ErrUnauthorized     = &AnalyzerError{code: CodeUnauthorized}
-	ErrTimeout          = &AnalyzerError{code: CodeTimeout}
-	ErrNoLayers         = &AnalyzerError{code: CodeNoLayers}
-	ErrFetchFailed      = &AnalyzerError{code: CodeFetchFailed}
-	ErrBuildFailed      = &AnalyzerError{code: CodeBuildFailed}
+	ErrInvalidReference   = &AnalyzerError{code: CodeInvalidReference}
+	ErrImageNotFound      = &AnalyzerError{code: CodeImageNotFound}
+	ErrUnauthorized       = &AnalyzerError{code: CodeUnauthorized}
+	ErrTimeout            = &AnalyzerError{code: CodeTimeout}
+	ErrNoLayers           = &AnalyzerError{code: CodeNoLayers}
+	ErrFetchFailed        = &AnalyzerError{code: CodeFetchFailed}
+	ErrRateLimited        = &AnalyzerError{code: CodeRateLimited}
+	ErrRepositoryNotFound = &AnalyzerError{code: CodeRepositoryNotFound}
+	ErrBlobNotFound       = &AnalyzerError{code: CodeBlobNotFound}
+	ErrAccessDenied       = &AnalyzerError{code: CodeAccessDenied}
+	ErrCircuitOpen        = &AnalyzerError{code: CodeCircuitOpen}
+	ErrTLSConfig          = &AnalyzerError{code: CodeTLSConfig}
+	ErrBuildFailed        = &AnalyzerError{code: CodeBuildFailed}
+	ErrPlanDrift          = &AnalyzerError{code: CodePlanDrift}
 )
 
 /*
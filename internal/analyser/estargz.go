@@ -0,0 +1,365 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/klauspost/compress/gzip"
+)
+
+// tarBlockSize is the tar record size entries are padded to
+const tarBlockSize = 512
+
+// layerSource gives layer extraction what the manifest and registry know
+// beyond the layers themselves
+type layerSource struct {
+	// tocs maps the digest of eStargz layers to their TOC digest
+	tocs map[string]string
+
+	// blobs reads byte ranges of layer blobs; nil when the image did not
+	// come from a registry
+	blobs *blobReader
+}
+
+// estargzTOCs maps the digest of every eStargz layer of img to the TOC
+// digest its manifest annotates it with
+func estargzTOCs(img v1.Image) map[string]string {
+	manifest, err := img.Manifest()
+	if err != nil || manifest == nil {
+		return nil
+	}
+
+	tocs := make(map[string]string)
+	for _, d := range manifest.Layers {
+		if toc := d.Annotations[estargz.TOCJSONDigestAnnotation]; toc != "" {
+			tocs[d.Digest.String()] = toc
+		}
+	}
+	return tocs
+}
+
+// isEstargzMetadata reports whether p is an entry eStargz adds to a layer
+// rather than a file of the image
+func isEstargzMetadata(p string) bool {
+	switch p {
+	case estargz.TOCTarName, estargz.PrefetchLandmark, estargz.NoPrefetchLandmark:
+		return true
+	}
+	return false
+}
+
+// withoutEstargzMetadata drops the entries eStargz adds from files
+func withoutEstargzMetadata(files []FileEntry) []FileEntry {
+	out := files[:0]
+	for _, f := range files {
+		if !isEstargzMetadata(f.Path) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// blobReader reads byte ranges of the blobs of one repository
+// Authentication is set up on first use, so images without eStargz layers
+// pay nothing for it
+type blobReader struct {
+	repo     name.Repository
+	rt       http.RoundTripper
+	keychain authn.Keychain
+
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+// newBlobReader creates a blobReader for repo
+func newBlobReader(repo name.Repository, rt http.RoundTripper, keychain authn.Keychain) *blobReader {
+	return &blobReader{repo: repo, rt: rt, keychain: keychain}
+}
+
+// init authenticates against the registry for pulls from the repository
+func (b *blobReader) init(ctx context.Context) error {
+	b.once.Do(func() {
+		auth, err := b.keychain.Resolve(b.repo)
+		if err != nil {
+			b.err = err
+			return
+		}
+
+		scopes := []string{b.repo.Scope(transport.PullScope)}
+		tr, err := transport.NewWithContext(ctx, b.repo.Registry, auth, b.rt, scopes)
+		if err != nil {
+			b.err = err
+			return
+		}
+		b.client = &http.Client{Transport: tr}
+	})
+	return b.err
+}
+
+// open returns a reader over the blob with digest and size; read counts
+// the bytes transferred
+func (b *blobReader) open(ctx context.Context, digest string, size int64, read *atomic.Int64) (*io.SectionReader, error) {
+	if err := b.init(ctx); err != nil {
+		return nil, err
+	}
+
+	u := url.URL{
+		Scheme: b.repo.Registry.Scheme(),
+		Host:   b.repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/blobs/%s", b.repo.RepositoryStr(), digest),
+	}
+
+	r := &rangeReader{ctx: ctx, client: b.client, url: u.String(), read: read}
+	return io.NewSectionReader(r, 0, size), nil
+}
+
+// rangeReader reads a blob with HTTP range requests
+type rangeReader struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	read   *atomic.Int64
+}
+
+// ReadAt implements io.ReaderAt
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// A registry ignoring the range would send the whole blob
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("registry did not serve a byte range: %s", resp.Status)
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	r.read.Add(int64(n))
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// indexEstargz lists an eStargz layer from its table of contents
+//
+// Only the footer, the TOC and the package databases are read, one range
+// request each, so the layer is listed without downloading it. The TOC
+// must match tocDigest, the digest the manifest annotates the layer with.
+// File sizes are exact; the uncompressed size is estimated from the tar
+// records they need
+func indexEstargz(sr *io.SectionReader, tocDigest string) (*layerIndex, error) {
+	tocOffset, footerSize, err := estargz.OpenFooter(sr)
+	if err != nil {
+		return nil, fmt.Errorf("read eStargz footer: %w", err)
+	}
+
+	// Parsing through sr would send a request per buffered read of the
+	// decompressor; fetch the whole TOC at once instead
+	tocSize := sr.Size() - tocOffset - footerSize
+	if tocOffset < 0 || tocSize <= 0 {
+		return nil, fmt.Errorf("read eStargz TOC: invalid offset %d", tocOffset)
+	}
+	raw := make([]byte, tocSize)
+	if _, err := sr.ReadAt(raw, tocOffset); err != nil {
+		return nil, fmt.Errorf("read eStargz TOC: %w", err)
+	}
+	toc, dgst, err := new(estargz.GzipDecompressor).ParseTOC(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read eStargz TOC: %w", err)
+	}
+	if tocDigest != "" && dgst.String() != tocDigest {
+		return nil, fmt.Errorf("eStargz TOC digest %s does not match annotation %s", dgst, tocDigest)
+	}
+
+	// Two zero blocks end the archive. Only package databases are read,
+	// so ELF interpreters are unknown
+	idx := &layerIndex{size: 2 * tarBlockSize, interpretersUnknown: true}
+	var databases []*estargzFile
+
+	for _, e := range toc.Entries {
+		// Chunks continue the regular file before them
+		if e.Type == "chunk" {
+			if n := len(databases); n > 0 && databases[n-1].entry.Name == e.Name && e.Offset > 0 {
+				databases[n-1].last = e.Offset
+			}
+			continue
+		}
+
+		entry := newFileEntry(tocHeader(e))
+		if entry.Path == "" || isEstargzMetadata(entry.Path) {
+			continue
+		}
+		if entry.Type == FileTypeRegular && !entry.Whiteout {
+			entry.Digest = e.Digest
+		}
+		idx.files = append(idx.files, entry)
+		idx.size += tarRecordSize(e)
+
+		if entry.Type == FileTypeRegular && !entry.Whiteout && isPackageDatabase(entry.Path) {
+			databases = append(databases, &estargzFile{entry: e, path: entry.Path, last: e.Offset})
+		}
+	}
+
+	if len(databases) == 0 {
+		return idx, nil
+	}
+
+	offsets := contentOffsets(toc.Entries)
+	for _, db := range databases {
+		pkgs, err := readEstargzDatabase(sr, db, nextOffset(offsets, db.last, tocOffset))
+		if err != nil {
+			return nil, err
+		}
+		idx.packages = append(idx.packages, pkgs...)
+	}
+
+	return idx, nil
+}
+
+// estargzFile is a regular file of an eStargz layer
+type estargzFile struct {
+	entry *estargz.TOCEntry
+	path  string
+
+	// last is the offset of the gzip member holding its final chunk
+	last int64
+}
+
+// contentOffsets returns the sorted offsets of the gzip members holding
+// file content
+func contentOffsets(entries []*estargz.TOCEntry) []int64 {
+	var offsets []int64
+	for _, e := range entries {
+		if e.Offset > 0 {
+			offsets = append(offsets, e.Offset)
+		}
+	}
+	slices.Sort(offsets)
+	return slices.Compact(offsets)
+}
+
+// nextOffset returns where the gzip member at off ends: the next content
+// offset, or the TOC for the last one
+func nextOffset(offsets []int64, off, tocOffset int64) int64 {
+	i, found := slices.BinarySearch(offsets, off)
+	if found {
+		i++
+	}
+	if i < len(offsets) {
+		return offsets[i]
+	}
+	return tocOffset
+}
+
+// readEstargzDatabase fetches the compressed chunks of db, which end at
+// end, with a single range request and parses the package database in them
+// The content is checked against the digest the TOC records
+func readEstargzDatabase(sr *io.SectionReader, db *estargzFile, end int64) ([]Package, error) {
+	e := db.entry
+	if e.Offset <= 0 || end <= e.Offset {
+		return nil, fmt.Errorf("open %s: no content in eStargz TOC", db.path)
+	}
+
+	raw := make([]byte, end-e.Offset)
+	if _, err := sr.ReadAt(raw, e.Offset); err != nil {
+		return nil, fmt.Errorf("open %s: %w", db.path, err)
+	}
+
+	// Chunks are consecutive gzip members, which the reader concatenates
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", db.path, err)
+	}
+	defer zr.Close()
+	if _, err := io.CopyN(io.Discard, zr, e.InnerOffset); err != nil {
+		return nil, fmt.Errorf("open %s: %w", db.path, err)
+	}
+
+	h := sha256.New()
+	content := io.TeeReader(io.LimitReader(zr, e.Size), h)
+
+	pkgs, err := parsePackageDatabase(db.path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Digest != "" {
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return nil, fmt.Errorf("open %s: %w", db.path, err)
+		}
+		if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != e.Digest {
+			return nil, fmt.Errorf("open %s: content digest %s does not match eStargz TOC digest %s", db.path, got, e.Digest)
+		}
+	}
+	return pkgs, nil
+}
+
+// tocHeader converts a TOC entry into the tar header it was built from
+func tocHeader(e *estargz.TOCEntry) *tar.Header {
+	hdr := &tar.Header{
+		Name:     e.Name,
+		Size:     e.Size,
+		Mode:     e.Mode,
+		Linkname: e.LinkName,
+	}
+
+	switch e.Type {
+	case "dir":
+		hdr.Typeflag = tar.TypeDir
+	case "reg":
+		hdr.Typeflag = tar.TypeReg
+	case "symlink":
+		hdr.Typeflag = tar.TypeSymlink
+	case "hardlink":
+		hdr.Typeflag = tar.TypeLink
+	case "char":
+		hdr.Typeflag = tar.TypeChar
+	case "block":
+		hdr.Typeflag = tar.TypeBlock
+	case "fifo":
+		hdr.Typeflag = tar.TypeFifo
+	}
+	return hdr
+}
+
+// tarRecordSize estimates the bytes e takes in a tar archive: a header,
+// an extended header for names the classic format cannot hold, and the
+// content padded to whole blocks
+func tarRecordSize(e *estargz.TOCEntry) int64 {
+	size := int64(tarBlockSize)
+	if len(e.Name) > 100 || len(e.LinkName) > 100 || len(e.Xattrs) > 0 {
+		size += 2 * tarBlockSize
+	}
+	if e.Type == "reg" {
+		size += (e.Size + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+	}
+	return size
+}
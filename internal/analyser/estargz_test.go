@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+)
+
+// estargzBlob builds an eStargz layer of a TOC and its footer, without file
+// content: listing a layer only reads those
+func estargzBlob(t *testing.T, entries ...*estargz.TOCEntry) []byte {
+	t.Helper()
+
+	toc, err := json.Marshal(estargz.JTOC{Version: 1, Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blob bytes.Buffer
+	zw := gzip.NewWriter(&blob)
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: estargz.TOCTarName, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(toc))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(toc); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The footer is an empty gzip member whose extra field holds the TOC offset
+	blob.Write([]byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 26, 0})
+	blob.Write([]byte{'S', 'G', 22, 0})
+	fmt.Fprintf(&blob, "%016xSTARGZ", 0)
+	blob.Write([]byte{1, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0})
+	return blob.Bytes()
+}
+
+func TestIndexEstargz(t *testing.T) {
+	blob := estargzBlob(t,
+		&estargz.TOCEntry{Name: "usr/", Type: "dir", Mode: 0o755},
+		&estargz.TOCEntry{Name: "usr/bin/app", Type: "reg", Mode: 0o755, Size: 1000, Digest: "sha256:" + strings.Repeat("a", 64)},
+		&estargz.TOCEntry{Name: "usr/bin/sh", Type: "symlink", LinkName: "app"},
+		&estargz.TOCEntry{Name: estargz.PrefetchLandmark, Type: "reg", Size: 1},
+	)
+	sr := io.NewSectionReader(bytes.NewReader(blob), 0, int64(len(blob)))
+
+	idx, err := indexEstargz(sr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, f := range idx.files {
+		paths = append(paths, f.Path)
+	}
+	if got, want := strings.Join(paths, " "), "usr usr/bin/app usr/bin/sh"; got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+	if !idx.interpretersUnknown {
+		t.Error("eStargz listing claims to know ELF interpreters")
+	}
+	if idx.size < 1000 {
+		t.Errorf("size = %d, want at least the file content", idx.size)
+	}
+
+	if _, err := indexEstargz(sr, "sha256:"+strings.Repeat("0", 64)); err == nil {
+		t.Error("TOC digest mismatch accepted")
+	}
+}
@@ -0,0 +1,225 @@
+package analyzer
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+// EventType identifies the kind of a progress Event
+type EventType string
+
+const (
+	// EventResolveStarted is emitted before a load resolves the reference to
+	// a manifest, once per attempt
+	EventResolveStarted EventType = "resolve.started"
+
+	// EventResolveFinished is emitted once resolution ends; Digest or Err is set
+	EventResolveFinished EventType = "resolve.finished"
+
+	// EventLayerDownload reports compressed bytes received for a layer
+	EventLayerDownload EventType = "layer.download"
+
+	// EventLayerDecompress reports uncompressed bytes read from a layer
+	EventLayerDecompress EventType = "layer.decompress"
+
+	// EventStageStarted is emitted when a pipeline stage begins
+	EventStageStarted EventType = "stage.started"
+
+	// EventStageFinished is emitted when a pipeline stage ends; Err is set on failure
+	EventStageFinished EventType = "stage.finished"
+
+	// EventPlanBuilt is emitted once the slimming plan is available
+	EventPlanBuilt EventType = "plan.built"
+)
+
+// Pipeline stages reported by stage events
+const (
+	StageFetch         = "fetch"
+	StageBuild         = "build"
+	StageDeterministic = "deterministic"
+	StagePlan          = "plan"
+)
+
+// Event is a single progress notification
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Reference string
+
+	// Digest is the image digest for resolve events and the layer digest
+	// for layer events
+	Digest string
+
+	// Layer is the layer index for layer events, otherwise -1
+	Layer int
+
+	// Stage names the stage of stage events
+	Stage string
+
+	// Bytes is the cumulative byte count of layer events
+	// Total is the expected final count, or zero when unknown
+	Bytes int64
+	Total int64
+
+	// Done marks the last event of a layer stream
+	Done bool
+
+	Err error
+}
+
+// Observer receives progress events
+//
+// Observe is called synchronously from the goroutine doing the work, which
+// may be one of several concurrent layer workers. Implementations must be
+// safe for concurrent use and return quickly
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(Event)
+
+// Observe implements Observer
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// progressInterval is the number of bytes between two layer byte events
+const progressInterval = 1 << 20
+
+// emit delivers e to the configured observer, if any
+func (o *options) emit(e Event) {
+	if o.observer == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	o.observer.Observe(e)
+}
+
+// stage emits the start of stage and returns a func emitting its end
+func (o *options) stage(ref, stage string) func(error) {
+	o.emit(Event{Type: EventStageStarted, Reference: ref, Layer: -1, Stage: stage})
+	return func(err error) {
+		o.emit(Event{Type: EventStageFinished, Reference: ref, Layer: -1, Stage: stage, Err: err})
+	}
+}
+
+// progressReader emits cumulative byte events while it is read
+type progressReader struct {
+	r    io.Reader
+	n    int64
+	last int64
+	done bool
+	send func(n int64, done bool)
+}
+
+// Read implements io.Reader
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+
+	switch {
+	case err == io.EOF && !p.done:
+		p.done = true
+		p.send(p.n, true)
+	case p.n-p.last >= progressInterval:
+		p.last = p.n
+		p.send(p.n, false)
+	}
+
+	return n, err
+}
+
+// observeLayers wraps layers so reading their blobs is counted and
+// emits download events
+func observeLayers(layers []v1.Layer, ref string, opts *options) []*observedLayer {
+	out := make([]*observedLayer, len(layers))
+	for i, l := range layers {
+		out[i] = &observedLayer{Layer: l, index: i, ref: ref, opts: opts}
+	}
+	return out
+}
+
+// observedLayer counts and reports compressed bytes as they are downloaded
+type observedLayer struct {
+	v1.Layer
+	index int
+	ref   string
+	opts  *options
+
+	// received is the number of compressed bytes downloaded so far
+	received atomic.Int64
+}
+
+// Compressed implements v1.Layer
+func (l *observedLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, _ := l.Layer.Digest()
+	total, _ := l.Layer.Size()
+
+	pr := &progressReader{
+		r: &countingReadTo{r: rc, n: &l.received},
+		send: func(n int64, done bool) {
+			l.opts.emit(Event{
+				Type:      EventLayerDownload,
+				Reference: l.ref,
+				Digest:    digest.String(),
+				Layer:     l.index,
+				Bytes:     n,
+				Total:     total,
+				Done:      done,
+			})
+		},
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{pr, rc}, nil
+}
+
+// Uncompressed implements v1.Layer by decompressing the observed blob
+func (l *observedLayer) Uncompressed() (io.ReadCloser, error) {
+	ul, err := partial.CompressedToLayer(l)
+	if err != nil {
+		return nil, err
+	}
+	return ul.Uncompressed()
+}
+
+// countingReadTo adds the bytes read through it to a shared counter
+type countingReadTo struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+// Read implements io.Reader
+func (c *countingReadTo) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// wrapLayers applies download observation and then the blob cache, so
+// blobs served from the cache report no download
+// It also returns the observed layers, which hold the download counters
+func wrapLayers(layers []v1.Layer, ref string, opts *options) ([]v1.Layer, []*observedLayer) {
+	observed := observeLayers(layers, ref, opts)
+
+	plain := make([]v1.Layer, len(observed))
+	for i, l := range observed {
+		plain[i] = l
+	}
+
+	return cacheLayers(plain, opts.cache), observed
+}
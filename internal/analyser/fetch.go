@@ -2,75 +2,116 @@ package analyzer
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pnkcaht/image-slimmer-core/internal/registries"
 )
 
+// withDefaultTimeout guarantees timeout enforcement when the caller did not set a deadline
+//
+// The returned image is lazy: layers are downloaded with the context bound at
+// fetch time, so the deadline must outlive fetchImage and cover the build phase
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// fetched is the outcome of a successful fetch
+type fetched struct {
+	image v1.Image
+
+	// resolved is the digest the reference resolved to, which is the
+	// index digest for multi-platform images
+	resolved string
+
+	// endpoint is the registry that served the image, a mirror or the upstream
+	endpoint string
+
+	// platform is the platform selected when the reference names an index
+	platform string
+
+	// blobs reads byte ranges of layer blobs from the same endpoint
+	blobs *blobReader
+}
+
 // fetchImage resolves and downloads a container image from a remote registry
+// Configured mirrors are tried in order before the upstream; the error of
+// the upstream is returned when every endpoint fails
 // This function acts as a strict external boundary: all errors are normalized
-func fetchImage(ctx context.Context, ref string, opts *options) (v1.Image, error) {
+func fetchImage(ctx context.Context, ref string, opts *options) (fetched, error) {
 	const op = "fetch"
 
-	// Guarantee timeout enforcement
-	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
-		defer cancel()
-	}
-
 	if ref == "" {
-		return nil, NewError(CodeInvalidReference, op, ref, "image reference cannot be empty", nil)
+		return fetched{}, NewError(CodeInvalidReference, op, ref, "image reference cannot be empty", nil)
 	}
 
 	// Strict parsing prevents ambiguous references
 	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
 	if err != nil {
-		return nil, NewError(CodeInvalidReference, op, ref, "invalid image reference format", err)
+		return fetched{}, NewError(CodeInvalidReference, op, ref, "invalid image reference format", err)
 	}
 
-	// Detect if reference is digest-pinned (more secure)
-	_, isDigest := parsedRef.(name.Digest)
-
-	// Prepare remote options (auth + transport extensible)
-	remoteOpts := []remote.Option{
-		remote.WithContext(ctx),
-	}
-
-	if opts.keychain != nil {
-		remoteOpts = append(remoteOpts, remote.WithAuthFromKeychain(opts.keychain))
+	rt, err := opts.roundTripper()
+	if err != nil {
+		return fetched{}, NewError(CodeTLSConfig, op, ref, "invalid transport configuration", err)
 	}
 
-	if opts.transport != nil {
-		remoteOpts = append(remoteOpts, remote.WithTransport(opts.transport))
+	if opts.mirrors.Blocked(parsedRef) {
+		return fetched{}, NewError(CodeAccessDenied, op, ref, "registry blocked by the registries configuration", nil)
 	}
 
-	start := time.Now()
-
-	img, err := remote.Image(parsedRef, remoteOpts...)
+	opts.emit(Event{Type: EventResolveStarted, Reference: ref, Layer: -1})
 
-	fetchDuration := time.Since(start)
+	var f fetched
+	for _, ep := range endpoints(parsedRef, opts) {
+		err = opts.breaker.do(ctx, op, ref, ep.Registry, func() error {
+			var err error
+			f, err = fetchFrom(ctx, ref, parsedRef, ep.Reference, rt, opts)
+			return err
+		})
+		if err == nil {
+			f.endpoint = ep.Registry
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
 
 	if err != nil {
-		return nil, MapRegistryError(op, ref, err)
+		opts.emit(Event{Type: EventResolveFinished, Reference: ref, Layer: -1, Err: err})
+		return fetched{}, err
 	}
 
-	if img == nil {
-		return nil, NewError(CodeFetchFailed, op, ref, "registry returned nil image", nil)
-	}
+	opts.emit(Event{Type: EventResolveFinished, Reference: ref, Layer: -1, Digest: f.resolved})
+	return f, nil
+}
 
-	// Force validation to ensure image is not partially resolved
-	digest, err := img.Digest()
+// fetchFrom fetches ref from a single endpoint, where it is named target
+func fetchFrom(ctx context.Context, ref string, parsedRef, target name.Reference, rt http.RoundTripper, opts *options) (fetched, error) {
+	const op = "fetch"
+
+	rl := newRateLimit(rt)
+	at := newAuthTrace(opts.keychain)
+
+	// Fetch the descriptor first so digest pinning is checked against the
+	// manifest the reference names, which may be a multi-platform index
+	desc, err := remote.Get(target, remoteOptions(ctx, opts, rl, at)...)
 	if err != nil {
-		return nil, NewError(CodeFetchFailed, op, ref, "failed to resolve image digest", err)
+		return fetched{}, at.annotate(rl.annotate(MapRegistryError(op, ref, err)))
 	}
 
-	// If reference was digest-pinned, enforce digest match
-	if isDigest {
-		if parsedRef.Identifier() != digest.String() {
-			return nil, NewError(
+	// A digest-pinned reference must get exactly that manifest, whichever
+	// endpoint answered
+	if _, isDigest := parsedRef.(name.Digest); isDigest {
+		if parsedRef.Identifier() != desc.Digest.String() {
+			return fetched{}, NewError(
 				CodeFetchFailed,
 				op,
 				ref,
@@ -80,15 +121,141 @@ func fetchImage(ctx context.Context, ref string, opts *options) (v1.Image, error
 		}
 	}
 
-	// Attach fetch duration to context if metrics enabled
-	if opts.metricsHook != nil {
-		opts.metricsHook(FetchMetrics{
+	img, err := desc.Image()
+	if err != nil {
+		return fetched{}, at.annotate(rl.annotate(MapRegistryError(op, ref, err)))
+	}
+
+	if img == nil {
+		return fetched{}, NewError(CodeFetchFailed, op, ref, "registry returned nil image", nil)
+	}
+
+	// Force validation to ensure image is not partially resolved
+	if _, err := img.Digest(); err != nil {
+		return fetched{}, NewError(CodeFetchFailed, op, ref, "failed to resolve image digest", err)
+	}
+
+	f := fetched{
+		image:    img,
+		resolved: desc.Digest.String(),
+		blobs:    newBlobReader(target.Context(), rt, opts.keychain),
+	}
+	if desc.MediaType.IsIndex() {
+		f.platform = DefaultPlatform
+		if opts.platform != nil {
+			f.platform = opts.platform.String()
+		}
+	}
+	return f, nil
+}
+
+// endpoints lists where parsedRef is pulled from, mirrors first
+// Hosts allowed to speak plain HTTP get references that permit it
+func endpoints(parsedRef name.Reference, opts *options) []registries.Endpoint {
+	eps := opts.mirrors.Endpoints(parsedRef)
+
+	for i, ep := range eps {
+		if !opts.hosts.AllowsHTTP(ep.Registry) {
+			continue
+		}
+		if r, err := name.ParseReference(ep.Reference.String(), name.StrictValidation, name.Insecure); err == nil {
+			eps[i].Reference = r
+		}
+	}
+
+	return eps
+}
+
+// endpointRegistries lists the registries a fetch of ref tries, mirrors
+// first, or nil if ref is invalid
+func endpointRegistries(ref string, opts *options) []string {
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	if err != nil {
+		return nil
+	}
+
+	eps := endpoints(parsedRef, opts)
+	regs := make([]string, len(eps))
+	for i, ep := range eps {
+		regs[i] = ep.Registry
+	}
+	return regs
+}
+
+// fetchAttempts runs fetchImage under the retry policy, reporting every
+// attempt to the metrics hook
+// It returns the fetched image and the number of attempts
+func fetchAttempts(ctx context.Context, ref string, opts *options) (fetched, int, error) {
+	pinned := IsDigestPinned(ref)
+
+	var (
+		f       fetched
+		attempt int
+	)
+
+	attempts, err := retry(ctx, opts.retries, opts.backoff, opts.breaker.retryGate(endpointRegistries(ref, opts)...), func() error {
+		attempt++
+		start := time.Now()
+
+		var err error
+		f, err = fetchImage(ctx, ref, opts)
+
+		reportAttempt(opts, FetchMetrics{
 			Reference:    ref,
-			Duration:     fetchDuration,
-			Digest:       digest.String(),
-			DigestPinned: isDigest,
-		})
+			Duration:     time.Since(start),
+			DigestPinned: pinned,
+			Attempts:     attempt,
+			Endpoint:     f.endpoint,
+		}, f.image, err)
+
+		return err
+	})
+
+	return f, attempts, err
+}
+
+// reportAttempt completes m from the attempt outcome and passes it to the metrics hook
+func reportAttempt(opts *options, m FetchMetrics, img v1.Image, err error) {
+	if opts.metricsHook == nil {
+		return
+	}
+
+	if err != nil {
+		m.ErrorCode = CodeUnknown
+		if ae, ok := AsAnalyzerError(err); ok {
+			m.ErrorCode = ae.Code()
+		}
+	} else {
+		m.Success = true
+		if d, derr := img.Digest(); derr == nil {
+			m.Digest = d.String()
+		}
+	}
+
+	opts.metricsHook(m)
+}
+
+// remoteOptions prepares registry client options (auth + transport extensible)
+// Requests go through rt and at, which wrap the configured transport and keychain
+// The client does not retry on its own: retries belong to retry, which
+// honours Retry-After and the retry budget
+func remoteOptions(ctx context.Context, opts *options, rt http.RoundTripper, at *authTrace) []remote.Option {
+	remoteOpts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithRetryBackoff(remote.Backoff{Steps: 1}),
+	}
+
+	if opts.keychain != nil {
+		remoteOpts = append(remoteOpts, remote.WithAuthFromKeychain(at))
+	}
+
+	if rt != nil {
+		remoteOpts = append(remoteOpts, remote.WithTransport(rt))
+	}
+
+	if opts.platform != nil {
+		remoteOpts = append(remoteOpts, remote.WithPlatform(*opts.platform))
 	}
 
-	return img, nil
+	return remoteOpts
 }
@@ -6,15 +6,32 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // ExtractLayerToFS writes the contents of a single Layer to the target directory
 // It uses the Layer info from Image.Layers
+//
+// Every path is resolved inside targetDir: entries that would leave it,
+// directly or through a symlink written by a layer, are refused
 func ExtractLayerToFS(layer Layer, layerReader io.ReadCloser, targetDir string) error {
 	defer layerReader.Close()
 
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create target directory: %s", targetDir), err)
+	}
+	root, err := os.OpenRoot(targetDir)
+	if err != nil {
+		return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to open target directory: %s", targetDir), err)
+	}
+	defer root.Close()
+
 	tr := tar.NewReader(layerReader)
 
+	// Whiteouts only hide lower layers, but the tar may list them after
+	// entries of the same layer; those entries are kept
+	written := layerWrites{root: root}
+
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -25,21 +42,38 @@ func ExtractLayerToFS(layer Layer, layerReader io.ReadCloser, targetDir string)
 		}
 
 		// Sanitize path
-		fpath := filepath.Join(targetDir, filepath.Clean(hdr.Name))
-		if !filepath.HasPrefix(fpath, filepath.Clean(targetDir)+string(os.PathSeparator)) {
+		fpath, ok := layerPath(hdr.Name)
+		if !ok {
 			return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("illegal file path in layer: %s", hdr.Name), nil)
 		}
+		if fpath == "." {
+			continue
+		}
+
+		// Whiteouts delete content written by lower layers
+		if base := filepath.Base(fpath); strings.HasPrefix(base, whiteoutPrefix) {
+			if err := written.applyWhiteout(fpath, base); err != nil {
+				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to apply whiteout: %s", hdr.Name), err)
+			}
+			continue
+		}
+
+		written.add(fpath)
+
+		if err := replace(root, fpath, hdr.Typeflag == tar.TypeDir); err != nil {
+			return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to replace: %s", fpath), err)
+		}
 
 		switch hdr.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(fpath, os.FileMode(hdr.Mode)); err != nil {
+			if err := root.MkdirAll(fpath, os.FileMode(hdr.Mode)); err != nil {
 				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create directory: %s", fpath), err)
 			}
 		case tar.TypeReg, tar.TypeRegA:
-			if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			if err := root.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
 				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create parent dir for file: %s", fpath), err)
 			}
-			f, err := os.OpenFile(fpath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			f, err := root.OpenFile(fpath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
 			if err != nil {
 				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create file: %s", fpath), err)
 			}
@@ -50,19 +84,121 @@ func ExtractLayerToFS(layer Layer, layerReader io.ReadCloser, targetDir string)
 			}
 			f.Close()
 		case tar.TypeSymlink:
-			if err := os.Symlink(hdr.Linkname, fpath); err != nil {
+			if err := root.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create parent dir for symlink: %s", fpath), err)
+			}
+			if err := root.Symlink(hdr.Linkname, fpath); err != nil {
 				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create symlink: %s", fpath), err)
 			}
+		case tar.TypeLink:
+			// Hardlink targets are paths in the image, not relative to the link
+			target, ok := layerPath(hdr.Linkname)
+			if !ok || target == "." {
+				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("illegal hardlink target in layer: %s", hdr.Linkname), nil)
+			}
+			if err := root.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create parent dir for hardlink: %s", fpath), err)
+			}
+			if err := root.Link(target, fpath); err != nil {
+				return NewError(CodeLayerExtract, "filesystem", "", fmt.Sprintf("failed to create hardlink: %s", fpath), err)
+			}
 		default:
-			// Ignora outros tipos (hardlinks, dispositivos, etc.)
+			// Device nodes and FIFOs need privileges to create and hold no
+			// content, so they are skipped
 		}
 	}
 
 	return nil
 }
 
+// layerPath returns the path of a tar entry relative to the extraction root
+// ok is false for names that leave the root
+func layerPath(name string) (string, bool) {
+	p := filepath.Clean(strings.TrimLeft(filepath.FromSlash(name), string(os.PathSeparator)))
+	return p, filepath.IsLocal(p)
+}
+
+// replace removes what a lower layer left at p before an entry is written
+// there, so files and symlinks are replaced rather than written through
+// Directories are kept when the entry is a directory too
+func replace(root *os.Root, p string, dir bool) error {
+	fi, err := root.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dir && fi.IsDir() {
+		return nil
+	}
+	return root.RemoveAll(p)
+}
+
+// layerWrites records the paths written by the layer being extracted,
+// together with their parent directories
+type layerWrites struct {
+	root  *os.Root
+	paths map[string]bool
+}
+
+// add records fpath and its parents up to the extraction root
+func (w *layerWrites) add(fpath string) {
+	if w.paths == nil {
+		w.paths = make(map[string]bool)
+	}
+	for p := fpath; p != "." && !w.paths[p]; p = filepath.Dir(p) {
+		w.paths[p] = true
+	}
+}
+
+// applyWhiteout removes the path (or, for opaque whiteouts, the directory
+// contents) hidden by a whiteout entry located at fpath, keeping what the
+// layer itself wrote
+func (w *layerWrites) applyWhiteout(fpath, base string) error {
+	dir := filepath.Dir(fpath)
+
+	if base == whiteoutOpaque {
+		return w.pruneChildren(dir)
+	}
+	return w.prune(filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+}
+
+// prune removes p unless the layer wrote it or something below it
+func (w *layerWrites) prune(p string) error {
+	if !w.paths[p] {
+		return w.root.RemoveAll(p)
+	}
+	if fi, err := w.root.Lstat(p); err != nil || !fi.IsDir() {
+		return nil
+	}
+	return w.pruneChildren(p)
+}
+
+// pruneChildren prunes every entry of dir
+func (w *layerWrites) pruneChildren(dir string) error {
+	d, err := w.root.Open(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := d.ReadDir(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := w.prune(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ExtractAllLayersToFS writes all layers from an image to targetDir
-// Recebe os Layers da struct Image e um map de readers
+// layerReaders maps the index of every layer of img to its uncompressed tar
 func ExtractAllLayersToFS(img *Image, layerReaders map[int]io.ReadCloser, targetDir string) error {
 	if img == nil {
 		return NewError(CodeBuildFailed, "filesystem", "", "image is nil", nil)
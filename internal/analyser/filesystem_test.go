@@ -0,0 +1,211 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is an entry of a test layer; content is the body of regular files
+type tarEntry struct {
+	name     string
+	typ      byte
+	linkname string
+	content  string
+}
+
+// layerTar builds an uncompressed layer from entries
+func layerTar(t *testing.T, entries ...tarEntry) io.ReadCloser {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Linkname: e.linkname, Mode: 0644}
+		switch e.typ {
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		case tar.TypeReg:
+			hdr.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return io.NopCloser(&buf)
+}
+
+func TestExtractLayerToFSRefusesEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func(outside string) []tarEntry
+	}{
+		{
+			name: "parent path",
+			entries: func(string) []tarEntry {
+				return []tarEntry{{name: "../victim", typ: tar.TypeReg, content: "owned"}}
+			},
+		},
+		{
+			name: "file through absolute symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: outside},
+					{name: "etc/victim", typ: tar.TypeReg, content: "owned"},
+				}
+			},
+		},
+		{
+			name: "file through relative symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: "../outside"},
+					{name: "etc/victim", typ: tar.TypeReg, content: "owned"},
+				}
+			},
+		},
+		{
+			name: "directory through symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: outside},
+					{name: "etc/sub/", typ: tar.TypeDir},
+				}
+			},
+		},
+		{
+			name: "whiteout through symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: outside},
+					{name: "etc/.wh.victim", typ: tar.TypeReg},
+				}
+			},
+		},
+		{
+			name: "opaque whiteout through symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: outside},
+					{name: "etc/.wh..wh..opq", typ: tar.TypeReg},
+				}
+			},
+		},
+		{
+			name: "hardlink outside the root",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{{name: "victim", typ: tar.TypeLink, linkname: "../outside/victim"}}
+			},
+		},
+		{
+			name: "hardlink through symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc", typ: tar.TypeSymlink, linkname: outside},
+					{name: "copy", typ: tar.TypeLink, linkname: "etc/victim"},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			out := filepath.Join(base, "out")
+			outside := filepath.Join(base, "outside")
+			for _, dir := range []string{out, outside} {
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			victim := filepath.Join(outside, "victim")
+			if err := os.WriteFile(victim, []byte("original"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := ExtractLayerToFS(Layer{}, layerTar(t, tt.entries(outside)...), out)
+			assertCode(t, err, CodeLayerExtract)
+
+			data, err := os.ReadFile(victim)
+			if err != nil {
+				t.Fatalf("file outside the root: %v", err)
+			}
+			if string(data) != "original" {
+				t.Errorf("file outside the root = %q, want it untouched", data)
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+				t.Errorf("directory outside the root has %d entries, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestExtractLayerToFS(t *testing.T) {
+	out := t.TempDir()
+
+	lower := layerTar(t,
+		tarEntry{name: "etc/", typ: tar.TypeDir},
+		tarEntry{name: "etc/os-release", typ: tar.TypeReg, content: "lower"},
+		tarEntry{name: "etc/hosts", typ: tar.TypeReg, content: "lower"},
+		tarEntry{name: "bin/", typ: tar.TypeDir},
+		tarEntry{name: "bin/sh", typ: tar.TypeSymlink, linkname: "busybox"},
+		tarEntry{name: "bin/busybox", typ: tar.TypeReg, content: "busybox"},
+		tarEntry{name: "bin/ls", typ: tar.TypeLink, linkname: "bin/busybox"},
+		tarEntry{name: "lib/", typ: tar.TypeDir},
+		tarEntry{name: "lib/old", typ: tar.TypeReg, content: "lower"},
+	)
+	if err := ExtractLayerToFS(Layer{Index: 0}, lower, out); err != nil {
+		t.Fatal(err)
+	}
+
+	upper := layerTar(t,
+		// A file replaced by a symlink, and a symlink by a file
+		tarEntry{name: "etc/os-release", typ: tar.TypeSymlink, linkname: "../usr/lib/os-release"},
+		tarEntry{name: "bin/sh", typ: tar.TypeReg, content: "sh"},
+		// A whiteout listed after an entry the same layer wrote
+		tarEntry{name: "lib/new", typ: tar.TypeReg, content: "upper"},
+		tarEntry{name: "lib/.wh..wh..opq", typ: tar.TypeReg},
+		tarEntry{name: "etc/.wh.hosts", typ: tar.TypeReg},
+	)
+	if err := ExtractLayerToFS(Layer{Index: 1}, upper, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if target, err := os.Readlink(filepath.Join(out, "etc/os-release")); err != nil || target != "../usr/lib/os-release" {
+		t.Errorf("etc/os-release link = %q, %v, want ../usr/lib/os-release", target, err)
+	}
+
+	files := map[string]string{
+		"bin/sh":      "sh",
+		"bin/busybox": "busybox",
+		"bin/ls":      "busybox",
+		"lib/new":     "upper",
+	}
+	for p, want := range files {
+		data, err := os.ReadFile(filepath.Join(out, p))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", p, data, err, want)
+		}
+	}
+
+	busybox, _ := os.Stat(filepath.Join(out, "bin/busybox"))
+	ls, _ := os.Stat(filepath.Join(out, "bin/ls"))
+	if busybox == nil || ls == nil || !os.SameFile(busybox, ls) {
+		t.Error("bin/ls is not a hardlink to bin/busybox")
+	}
+
+	for _, p := range []string{"etc/hosts", "lib/old"} {
+		if _, err := os.Lstat(filepath.Join(out, p)); !os.IsNotExist(err) {
+			t.Errorf("%s survived its whiteout: %v", p, err)
+		}
+	}
+}
@@ -3,8 +3,6 @@ package analyzer
 import (
 	"context"
 	"time"
-
-	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // Image represents a fully resolved container image ready for analysis
@@ -16,6 +14,29 @@ type Image struct {
 	Size      int64
 	Layers    []Layer
 	LoadedAt  time.Time
+
+	// ResolvedDigest is the digest the reference resolved to; for
+	// multi-platform images it names the index rather than the manifest
+	ResolvedDigest string
+
+	// Platform is the platform of the image as os/arch[/variant]: the one
+	// selected from a multi-platform index, or the one of its config
+	Platform string
+
+	// MetadataOnly reports that layers were intentionally not extracted
+	MetadataOnly bool
+
+	// ListingsOmitted reports that the image was decoded from a document
+	// written without file listings, so its files, packages and wasted
+	// bytes are unknown rather than empty
+	ListingsOmitted bool
+
+	// Base is the base image the leading layers come from, if known
+	Base *BaseImage
+
+	// Entrypoint and Cmd are the process the image runs, from its config
+	Entrypoint []string
+	Cmd        []string
 }
 
 // Load resolves and builds a container image from a remote reference
@@ -33,23 +54,20 @@ func Load(ctx context.Context, ref string, opts ...Option) (*Image, Metrics, err
 		opt(options)
 	}
 
+	ctx, cancel := withDefaultTimeout(ctx, options.timeout)
+	defer cancel()
+
 	collector := newMetricsCollector()
 
 	// ---- FETCH PHASE ----
 
 	collector.startFetch()
+	endStage := options.stage(ref, StageFetch)
 
-	var rawImg v1.Image
-	var fetchErr error
-	var attempts int
-
-	attempts, fetchErr = retry(ctx, options.retries, options.backoff, func() error {
-		var err error
-		rawImg, err = fetchImage(ctx, ref, options)
-		return err
-	})
+	fetch, attempts, fetchErr := fetchAttempts(ctx, ref, options)
 
-	collector.endFetch(attempts, false) // digestPinned can be improved later
+	collector.endFetch(attempts, IsDigestPinned(ref), fetch.endpoint)
+	endStage(fetchErr)
 
 	if fetchErr != nil {
 		collector.markSuccess(false)
@@ -59,17 +77,44 @@ func Load(ctx context.Context, ref string, opts ...Option) (*Image, Metrics, err
 	// ---- BUILD PHASE ----
 
 	collector.startBuild()
+	endStage = options.stage(ref, StageBuild)
 
-	image, buildErr := buildImage(ref, rawImg, options)
+	image, layerMetrics, buildErr := buildImage(ctx, ref, fetch.image, fetch.blobs, options)
 
 	collector.endBuild()
+	collector.recordLayers(layerMetrics)
+	endStage(buildErr)
 
 	if buildErr != nil {
 		collector.markSuccess(false)
 		return nil, collector.snapshot(), buildErr
 	}
 
+	image.ResolvedDigest = fetch.resolved
+	if fetch.platform != "" {
+		image.Platform = fetch.platform
+	}
+	image.Base = annotatedBase(ctx, fetch.image, image.Layers, options)
+
 	collector.markSuccess(true)
 
 	return image, collector.snapshot(), nil
 }
+
+// CompressedSize returns the sum of compressed layer sizes (the pull size)
+func (i *Image) CompressedSize() int64 {
+	var total int64
+	for _, l := range i.Layers {
+		total += l.CompressedSize
+	}
+	return total
+}
+
+// UncompressedSize returns the sum of uncompressed layer sizes (the size on disk)
+func (i *Image) UncompressedSize() int64 {
+	var total int64
+	for _, l := range i.Layers {
+		total += l.UncompressedSize
+	}
+	return total
+}
@@ -52,8 +52,8 @@ func (i *Image) Validate() error {
 		)
 	}
 
-	// If metadataOnly mode was used, layers may be intentionally empty.
-	if len(i.Layers) == 0 {
+	// If metadataOnly mode was used, layers are intentionally empty.
+	if len(i.Layers) == 0 && !i.MetadataOnly {
 		return NewError(
 			CodeValidationFailed,
 			op,
@@ -0,0 +1,296 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileType classifies a filesystem entry recorded from a layer tarball
+type FileType string
+
+const (
+	FileTypeRegular  FileType = "file"
+	FileTypeDir      FileType = "dir"
+	FileTypeSymlink  FileType = "symlink"
+	FileTypeHardlink FileType = "hardlink"
+	FileTypeOther    FileType = "other"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// FileEntry represents a single filesystem entry recorded from a layer
+//
+// Paths are normalized: relative to the image root, without leading "/" or "./"
+// Whiteout entries mark Path (or, when Opaque is set, everything below Path)
+// as deleted from lower layers
+type FileEntry struct {
+	Path     string
+	Type     FileType
+	Size     int64
+	Mode     int64
+	Linkname string
+	Whiteout bool
+	Opaque   bool
+
+	// Digest is the sha256 digest of the content of regular files
+	Digest string `json:",omitempty"`
+
+	// Interpreter is the program interpreter of ELF executables, such as
+	// /lib/ld-musl-x86_64.so.1; empty for static binaries and other files.
+	// Layers listed from an eStargz TOC do not record it and have
+	// Layer.InterpretersUnknown set
+	Interpreter string `json:",omitempty"`
+}
+
+// layerIndex is the result of a single streaming pass over a layer tarball
+type layerIndex struct {
+	size      int64
+	files     []FileEntry
+	packages  []Package
+	estimates []CompressionEstimate
+
+	// compression is the compression the layer blob was found in
+	compression string
+
+	// interpretersUnknown is set when file contents were not read
+	interpretersUnknown bool
+}
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// indexLayer reads an uncompressed layer tarball once, recording every entry,
+// parsing package databases and measuring the total uncompressed size
+// With estimate set, the same pass also recompresses the stream to
+// estimate its compressed size in other formats
+func indexLayer(r io.Reader, estimate bool) (*layerIndex, error) {
+	var est *compressionEstimator
+	if estimate {
+		var err error
+		if est, err = newCompressionEstimator(); err != nil {
+			return nil, err
+		}
+		defer est.close()
+		r = io.TeeReader(r, est)
+	}
+
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
+	idx := &layerIndex{}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := newFileEntry(hdr)
+		if entry.Path == "" {
+			continue
+		}
+
+		if entry.Type == FileTypeRegular && !entry.Whiteout {
+			h := sha256.New()
+			head := &headWriter{}
+			content := io.TeeReader(tr, io.MultiWriter(h, head))
+
+			if isPackageDatabase(entry.Path) {
+				pkgs, err := parsePackageDatabase(entry.Path, content)
+				if err != nil {
+					return nil, err
+				}
+				idx.packages = append(idx.packages, pkgs...)
+			}
+
+			if _, err := io.Copy(io.Discard, content); err != nil {
+				return nil, err
+			}
+			entry.Digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+			entry.Interpreter = elfInterpreter(head.buf)
+		}
+
+		idx.files = append(idx.files, entry)
+	}
+
+	// Drain tar padding so the size reflects the full uncompressed blob
+	if _, err := io.Copy(io.Discard, cr); err != nil {
+		return nil, err
+	}
+
+	idx.size = cr.n
+
+	if est != nil {
+		var regular int
+		for _, f := range idx.files {
+			if f.Type == FileTypeRegular && !f.Whiteout {
+				regular++
+			}
+		}
+
+		estimates, err := est.finish(regular)
+		if err != nil {
+			return nil, err
+		}
+		idx.estimates = estimates
+	}
+
+	return idx, nil
+}
+
+// newFileEntry converts a tar header into a normalized FileEntry
+func newFileEntry(hdr *tar.Header) FileEntry {
+	p := normalizePath(hdr.Name)
+
+	entry := FileEntry{
+		Path:     p,
+		Size:     hdr.Size,
+		Mode:     hdr.Mode,
+		Linkname: hdr.Linkname,
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		entry.Type = FileTypeDir
+	case tar.TypeReg, tar.TypeRegA:
+		entry.Type = FileTypeRegular
+	case tar.TypeSymlink:
+		entry.Type = FileTypeSymlink
+	case tar.TypeLink:
+		entry.Type = FileTypeHardlink
+	default:
+		entry.Type = FileTypeOther
+	}
+
+	dir, base := path.Split(p)
+	switch {
+	case base == whiteoutOpaque:
+		entry.Path = strings.TrimSuffix(dir, "/")
+		entry.Whiteout = true
+		entry.Opaque = true
+		entry.Size = 0
+	case strings.HasPrefix(base, whiteoutPrefix):
+		entry.Path = dir + strings.TrimPrefix(base, whiteoutPrefix)
+		entry.Whiteout = true
+		entry.Size = 0
+	}
+
+	return entry
+}
+
+// normalizePath cleans a tar entry name into an image-root-relative path
+func normalizePath(name string) string {
+	p := path.Clean("/" + name)
+	return strings.TrimPrefix(p, "/")
+}
+
+// Files returns the merged filesystem view of the image, after applying
+// every layer in order including whiteouts. Entries are sorted by path
+func (i *Image) Files() []FileEntry {
+	fs, _ := i.mergeLayers()
+
+	files := make([]FileEntry, 0, len(fs))
+	for _, f := range fs {
+		files = append(files, f.entry)
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].Path < files[b].Path
+	})
+
+	return files
+}
+
+// WastedBytes returns the number of bytes stored in layers for regular files
+// that are overwritten or deleted by a later layer and therefore never
+// visible in the final filesystem
+func (i *Image) WastedBytes() int64 {
+	_, wasted := i.mergeLayers()
+	return wasted
+}
+
+// mergedFile tracks a visible file and the layer it came from
+type mergedFile struct {
+	entry FileEntry
+	layer int
+}
+
+// mergeLayers applies layers in index order and returns the visible
+// filesystem together with the bytes shadowed by later layers
+func (i *Image) mergeLayers() (map[string]mergedFile, int64) {
+	fs := make(map[string]mergedFile)
+	var wasted int64
+
+	shadow := func(p string) {
+		if f, ok := fs[p]; ok {
+			if f.entry.Type == FileTypeRegular {
+				wasted += f.entry.Size
+			}
+			delete(fs, p)
+		}
+	}
+
+	shadowTree := func(dir string, keepDir bool) {
+		prefix := dir + "/"
+		if dir == "" {
+			prefix = ""
+		}
+		for p := range fs {
+			if strings.HasPrefix(p, prefix) && p != dir {
+				shadow(p)
+			}
+		}
+		if !keepDir {
+			shadow(dir)
+		}
+	}
+
+	for _, l := range i.orderedLayers() {
+		// Whiteouts only hide lower layers, so apply them before additions
+		for _, f := range l.Files {
+			switch {
+			case f.Opaque:
+				shadowTree(f.Path, true)
+			case f.Whiteout:
+				shadowTree(f.Path, false)
+			}
+		}
+		for _, f := range l.Files {
+			if !f.Whiteout {
+				shadow(f.Path)
+				fs[f.Path] = mergedFile{entry: f, layer: l.Index}
+			}
+		}
+	}
+
+	return fs, wasted
+}
+
+// orderedLayers returns the image layers sorted by index without mutating the image
+func (i *Image) orderedLayers() []Layer {
+	layers := make([]Layer, len(i.Layers))
+	copy(layers, i.Layers)
+	sort.Slice(layers, func(a, b int) bool {
+		return layers[a].Index < layers[b].Index
+	})
+	return layers
+}
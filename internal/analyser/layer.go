@@ -1,9 +1,17 @@
 package analyzer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 // Layer represents extracted metadata from a container image layer
@@ -14,62 +22,246 @@ type Layer struct {
 	MediaType        string
 	CompressedSize   int64
 	UncompressedSize int64
+	Files            []FileEntry
+	Packages         []Package
+
+	// Compression is the compression of the layer blob: CompressionGzip,
+	// CompressionZstd, CompressionEstargz or CompressionNone
+	Compression string
+
+	// Estimates are the estimated compressed sizes of the layer in other
+	// formats; only set with WithCompressionEstimates
+	Estimates []CompressionEstimate
+
+	// InterpretersUnknown reports that the layer was listed from its eStargz
+	// TOC without reading file contents, so its files record no Interpreter
+	InterpretersUnknown bool
 }
 
 // ExtractLayers converts raw v1 layers into structured Layer metadata
+//
+// Layers are downloaded and indexed concurrently, bounded by WithParallelism
+// and WithMemoryBudget. The result is always in index order. The first
+// failure cancels the remaining layers through ctx
 // All errors are normalized to AnalyzerError.
-func ExtractLayers(rawLayers []v1.Layer, ref string) ([]Layer, error) {
+func ExtractLayers(ctx context.Context, rawLayers []v1.Layer, ref string, opts ...Option) ([]Layer, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	layers, _, err := extractLayers(ctx, rawLayers, ref, layerSource{}, options)
+	return layers, err
+}
+
+// extractLayers implements ExtractLayers with resolved options
+// It also returns the metrics of every layer, in index order
+func extractLayers(ctx context.Context, rawLayers []v1.Layer, ref string, src layerSource, opts *options) ([]Layer, []LayerMetrics, error) {
 	const op = "extract_layers"
 
 	if len(rawLayers) == 0 {
-		return nil, NewError(CodeLayerExtract, op, ref, "no layers to extract", nil)
+		return nil, nil, NewError(CodeLayerExtract, op, ref, "no layers to extract", nil)
 	}
 
-	layers := make([]Layer, 0, len(rawLayers))
+	wrapped, observed := wrapLayers(rawLayers, ref, opts)
 
-	for i, l := range rawLayers {
+	// Each worker writes only its own slot, which keeps the output ordered
+	layers := make([]Layer, len(rawLayers))
+	metrics := make([]LayerMetrics, len(rawLayers))
 
-		digest, err := l.Digest()
-		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to get layer digest", err)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.parallelism)
+
+	var budget *semaphore.Weighted
+	if opts.memoryBudget > 0 {
+		budget = semaphore.NewWeighted(opts.memoryBudget)
+	}
+
+	for i, l := range wrapped {
+		g.Go(func() error {
+			layer, m, err := extractLayer(gctx, i, l, ref, src, opts, budget)
+			if err != nil {
+				return err
+			}
+			m.CompressedBytes += observed[i].received.Load()
+			layers[i], metrics[i] = layer, m
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	return layers, metrics, nil
+}
+
+// extractLayer builds the Layer at index i and measures its cost
+// The caller adds the bytes downloaded through l; ranges read directly from
+// the registry are counted here
+func extractLayer(ctx context.Context, i int, l v1.Layer, ref string, src layerSource, opts *options, budget *semaphore.Weighted) (Layer, LayerMetrics, error) {
+	const op = "extract_layers"
+
+	digest, err := l.Digest()
+	if err != nil {
+		return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to get layer digest", err)
+	}
+
+	diffID, err := l.DiffID()
+	if err != nil {
+		return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to get layer diffID", err)
+	}
+
+	mediaType, err := l.MediaType()
+	if err != nil {
+		return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to get layer media type", err)
+	}
+
+	compressedSize, err := l.Size()
+	if err != nil {
+		return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to get compressed size", err)
+	}
+
+	var m LayerMetrics
+
+	// Index contents and calculate uncompressed size in a single pass
+	idx, cached := loadLayerAnalysis(opts.cache, digest.String())
+
+	// Analysis cached without estimates is redone when they are requested
+	if cached && opts.compressionEstimates && idx.estimates == nil {
+		cached, idx = false, nil
+	}
+
+	// eStargz layers are listed from their TOC without downloading them,
+	// unless estimates need the whole stream. Any failure falls back to
+	// the full download
+	tocDigest, isEstargz := src.tocs[digest.String()]
+	if !cached && isEstargz && src.blobs != nil && !opts.compressionEstimates {
+		start := time.Now()
+
+		var read atomic.Int64
+		sr, err := src.blobs.open(ctx, digest.String(), compressedSize, &read)
+		if err == nil {
+			idx, err = indexEstargz(sr, tocDigest)
+		}
+		if ctx.Err() != nil {
+			return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, fmt.Sprintf("layer %d canceled", i), ctx.Err())
 		}
+		if err == nil {
+			idx.compression = CompressionEstargz
+			storeLayerAnalysis(opts.cache, digest.String(), idx)
 
-		diffID, err := l.DiffID()
-		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to get layer diffID", err)
+			m.Duration = time.Since(start)
+			m.CompressedBytes = read.Load()
+		} else {
+			idx = nil
 		}
+	}
 
-		mediaType, err := l.MediaType()
+	if !cached && idx == nil {
+		release, err := acquireBudget(ctx, budget, opts.memoryBudget, compressedSize)
 		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to get layer media type", err)
+			return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, fmt.Sprintf("layer %d canceled", i), err)
 		}
+		defer release()
+
+		start := time.Now()
 
-		compressedSize, err := l.Size()
+		rc, compression, err := openUncompressed(l)
 		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to get compressed size", err)
+			// Opening the layer downloads the blob; keep the registry's verdict
+			var terr *transport.Error
+			if errors.As(err, &terr) {
+				return Layer{}, LayerMetrics{}, MapRegistryError(op, ref, err)
+			}
+			return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to get uncompressed reader", err)
 		}
 
-		// Calculate uncompressed size manually
-		rc, err := l.Uncompressed()
-		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to get uncompressed reader", err)
+		r := io.Reader(&ctxReader{ctx: ctx, r: rc})
+		if opts.observer != nil {
+			r = &progressReader{
+				r: r,
+				send: func(n int64, done bool) {
+					opts.emit(Event{
+						Type:      EventLayerDecompress,
+						Reference: ref,
+						Digest:    digest.String(),
+						Layer:     i,
+						Bytes:     n,
+						Done:      done,
+					})
+				},
+			}
 		}
 
-		uncompressedSize, err := io.Copy(io.Discard, rc)
+		idx, err = indexLayer(r, opts.compressionEstimates)
 		rc.Close()
 		if err != nil {
-			return nil, NewError(CodeLayerExtract, op, ref, "failed to compute uncompressed size", err)
+			return Layer{}, LayerMetrics{}, NewError(CodeLayerExtract, op, ref, "failed to index layer contents", err)
 		}
 
-		layers = append(layers, Layer{
-			Index:            i,
-			Digest:           digest.String(),
-			DiffID:           diffID.String(),
-			MediaType:        string(mediaType),
-			CompressedSize:   compressedSize,
-			UncompressedSize: uncompressedSize,
-		})
+		idx.compression = compression
+		if isEstargz && compression == CompressionGzip {
+			idx.compression = CompressionEstargz
+			idx.files = withoutEstargzMetadata(idx.files)
+		}
+
+		storeLayerAnalysis(opts.cache, digest.String(), idx)
+
+		m.Duration = time.Since(start)
+		m.UncompressedBytes = idx.size
 	}
 
-	return layers, nil
+	m.Index, m.Digest, m.Cached = i, digest.String(), cached
+
+	// Estimates cached by an earlier load are only reported when requested
+	estimates := idx.estimates
+	if !opts.compressionEstimates {
+		estimates = nil
+	}
+
+	return Layer{
+		Index:            i,
+		Digest:           digest.String(),
+		DiffID:           diffID.String(),
+		MediaType:        string(mediaType),
+		CompressedSize:   compressedSize,
+		UncompressedSize: idx.size,
+		Files:            idx.files,
+		Packages:         idx.packages,
+		Compression:      idx.compression,
+		Estimates:        estimates,
+
+		InterpretersUnknown: idx.interpretersUnknown,
+	}, m, nil
+}
+
+// acquireBudget reserves size bytes of the memory budget
+// A layer larger than the whole budget reserves all of it and runs alone
+func acquireBudget(ctx context.Context, budget *semaphore.Weighted, limit, size int64) (func(), error) {
+	if budget == nil {
+		return func() {}, nil
+	}
+
+	weight := min(max(size, 1), limit)
+	if err := budget.Acquire(ctx, weight); err != nil {
+		return nil, err
+	}
+	return func() { budget.Release(weight) }, nil
+}
+
+// ctxReader stops reading once ctx is done
+// Layer downloads are bound to the fetch context, so this is what lets a
+// failing sibling stop in-flight layers
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
@@ -15,6 +15,45 @@ type Metrics struct {
 	FetchAttempts int
 	DigestPinned  bool
 	Success       bool
+
+	// Endpoint is the registry that served the image: a mirror, or the
+	// registry of the reference
+	Endpoint string
+
+	// Cached reports that the result was served from a cache without fetching layers
+	Cached bool
+
+	// FetchSkipped reports that only the digest was resolved because the
+	// image was unchanged since a previous result
+	FetchSkipped bool
+
+	// CompressedBytes and UncompressedBytes total the bytes transferred
+	// and decompressed over all layers
+	CompressedBytes   int64
+	UncompressedBytes int64
+
+	// Layers holds per-layer metrics in index order
+	Layers []LayerMetrics
+}
+
+// LayerMetrics records the cost of analyzing a single layer
+type LayerMetrics struct {
+	Index  int
+	Digest string
+
+	// Duration is the time spent downloading and indexing the layer,
+	// excluding time waiting for a worker or the memory budget
+	Duration time.Duration
+
+	// CompressedBytes is the number of blob bytes downloaded; zero when
+	// the blob or the analysis came from the cache
+	CompressedBytes int64
+
+	// UncompressedBytes is the number of tar bytes read while indexing
+	UncompressedBytes int64
+
+	// Cached reports that the layer analysis came from the cache
+	Cached bool
 }
 
 // metricsCollector accumulates execution timings internally
@@ -32,7 +71,10 @@ type metricsCollector struct {
 
 	fetchAttempts int
 	digestPinned  bool
+	endpoint      string
 	success       bool
+
+	layers []LayerMetrics
 }
 
 // newMetricsCollector initializes timing collection
@@ -50,7 +92,7 @@ func (m *metricsCollector) startFetch() {
 	m.fetchStart = time.Now()
 }
 
-func (m *metricsCollector) endFetch(attempts int, digestPinned bool) {
+func (m *metricsCollector) endFetch(attempts int, digestPinned bool, endpoint string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -60,6 +102,7 @@ func (m *metricsCollector) endFetch(attempts int, digestPinned bool) {
 
 	m.fetchAttempts = attempts
 	m.digestPinned = digestPinned
+	m.endpoint = endpoint
 }
 
 // BUILD PHASE
@@ -79,6 +122,13 @@ func (m *metricsCollector) endBuild() {
 	}
 }
 
+// recordLayers stores the per-layer metrics of the build phase
+func (m *metricsCollector) recordLayers(layers []LayerMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.layers = layers
+}
+
 // FINALIZATION
 
 // markSuccess marks the execution result.
@@ -94,12 +144,21 @@ func (m *metricsCollector) snapshot() Metrics {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return Metrics{
+	snap := Metrics{
 		FetchDuration: m.fetchDuration,
 		BuildDuration: m.buildDuration,
 		TotalDuration: time.Since(m.start),
 		FetchAttempts: m.fetchAttempts,
 		DigestPinned:  m.digestPinned,
+		Endpoint:      m.endpoint,
 		Success:       m.success,
+		Layers:        append([]LayerMetrics(nil), m.layers...),
+	}
+
+	for _, l := range m.layers {
+		snap.CompressedBytes += l.CompressedBytes
+		snap.UncompressedBytes += l.UncompressedBytes
 	}
+
+	return snap
 }
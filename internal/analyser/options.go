@@ -1,33 +1,46 @@
 package analyzer
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pnkcaht/image-slimmer-core/internal/cache"
+	"github.com/pnkcaht/image-slimmer-core/internal/credentials"
+	"github.com/pnkcaht/image-slimmer-core/internal/registries"
 )
 
 // FetchMetrics represents structured telemetry data emitted
-// after an image fetch operation.
+// after every image fetch attempt, successful or not.
 type FetchMetrics struct {
 	// Reference is the original image reference string
 	Reference string
 
-	// Duration represents total time spent fetching
+	// Duration represents time spent in this attempt
 	Duration time.Duration
 
-	// Digest is the resolved content digest of the image
+	// Digest is the resolved content digest of the image; empty on failure
 	Digest string
 
 	// DigestPinned indicates whether the original reference
 	// was already pinned to a digest.
 	DigestPinned bool
 
-	// Attempts indicates how many attempts were performed
+	// Attempts indicates how many attempts were performed,
+	// including the one being reported
 	Attempts int
 
 	// Success indicates whether the fetch completed successfully
 	Success bool
+
+	// ErrorCode classifies the failure of an unsuccessful attempt
+	ErrorCode ErrorCode
+
+	// Endpoint is the registry that served the image, which differs from
+	// the reference registry when a mirror answered; empty on failure
+	Endpoint string
 }
 
 // options holds internal configuration for the analyzer
@@ -40,6 +53,16 @@ type options struct {
 	transport    http.RoundTripper
 	metricsHook  func(FetchMetrics)
 	metadataOnly bool
+	platform     *v1.Platform
+	cache        cache.Cache
+	parallelism  int
+	memoryBudget int64
+	observer     Observer
+	breaker      *CircuitBreaker
+	mirrors      *registries.Config
+	hosts        *registries.Hosts
+
+	compressionEstimates bool
 }
 
 // Option defines a functional configuration modifier
@@ -55,6 +78,7 @@ func defaultOptions() *options {
 		keychain:     authn.DefaultKeychain,
 		transport:    http.DefaultTransport,
 		metadataOnly: false,
+		parallelism:  4,
 	}
 }
 
@@ -108,7 +132,7 @@ func WithTransport(t http.RoundTripper) Option {
 }
 
 // WithMetricsHook registers a callback invoked after
-// a fetch operation completes. It can be used for
+// every fetch attempt, including failed ones. It can be used for
 // logging, telemetry, or observability integration
 func WithMetricsHook(h func(FetchMetrics)) Option {
 	return func(o *options) {
@@ -123,3 +147,110 @@ func WithMetadataOnly(enabled bool) Option {
 		o.metadataOnly = enabled
 	}
 }
+
+// WithCompressionEstimates recompresses every layer while it is indexed
+// to estimate its size under gzip, zstd and eStargz. It costs CPU time
+// proportional to the uncompressed size of the image
+func WithCompressionEstimates(enabled bool) Option {
+	return func(o *options) {
+		o.compressionEstimates = enabled
+	}
+}
+
+// DefaultPlatform is the platform resolved from multi-platform indexes
+// when WithPlatform is not given
+const DefaultPlatform = "linux/amd64"
+
+// WithPlatform selects the platform to resolve when the reference
+// points to a multi-platform index. Defaults to DefaultPlatform
+func WithPlatform(p v1.Platform) Option {
+	return func(o *options) {
+		if p.OS != "" && p.Architecture != "" {
+			o.platform = &p
+		}
+	}
+}
+
+// WithCache stores layer blobs and per-layer analysis in c, keyed by layer
+// digest, so layers already seen are neither downloaded nor indexed again
+func WithCache(c cache.Cache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// WithParallelism configures how many layers are downloaded
+// and analyzed concurrently
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.parallelism = n
+		}
+	}
+}
+
+// WithMemoryBudget bounds the combined compressed size of layers being
+// analyzed at once. A layer larger than the budget is analyzed alone.
+// Zero means no budget
+func WithMemoryBudget(bytes int64) Option {
+	return func(o *options) {
+		if bytes >= 0 {
+			o.memoryBudget = bytes
+		}
+	}
+}
+
+// WithObserver registers an observer receiving progress events
+// while the image is resolved, downloaded and analyzed
+func WithObserver(o Observer) Option {
+	return func(opts *options) {
+		opts.observer = o
+	}
+}
+
+// WithCircuitBreaker shares registry health and retry budgets across calls
+// Calls to a registry whose circuit is open fail fast with CodeCircuitOpen
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(opts *options) {
+		opts.breaker = b
+	}
+}
+
+// WithMirrors configures registry mirrors tried before the upstream
+// Digest-pinned references are verified whichever endpoint answers
+func WithMirrors(c *registries.Config) Option {
+	return func(opts *options) {
+		opts.mirrors = c
+	}
+}
+
+// WithHosts configures per-registry CA bundles, client certificates,
+// insecure and plain-HTTP hosts and the proxy
+// The transport set with WithTransport must then be an *http.Transport
+func WithHosts(h *registries.Hosts) Option {
+	return func(opts *options) {
+		opts.hosts = h
+	}
+}
+
+// roundTripper returns the transport with the host settings applied
+func (o *options) roundTripper() (http.RoundTripper, error) {
+	if o.hosts == nil {
+		return o.transport, nil
+	}
+
+	base, ok := o.transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("host settings need an *http.Transport, got %T", o.transport)
+	}
+	return o.hosts.Transport(base), nil
+}
+
+// WithCredentials resolves registry credentials from sources, tried in
+// order; it replaces the keychain. Authentication errors name the source
+// that was used
+func WithCredentials(sources ...credentials.Source) Option {
+	return func(opts *options) {
+		opts.keychain = credentials.Chain(sources)
+	}
+}
@@ -0,0 +1,206 @@
+package analyzer
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Package represents an OS package recorded in a package manager database
+type Package struct {
+	Name    string
+	Version string
+	Manager string // "dpkg", "apk"
+	Source  string // database path the package was read from
+
+	// Files are the paths the package installed, when its database lists
+	// them: apk records them inline, dpkg in a file list per package
+	Files []string `json:",omitempty"`
+}
+
+const (
+	dpkgStatusPath  = "var/lib/dpkg/status"
+	dpkgStatusDir   = "var/lib/dpkg/status.d/"
+	dpkgInfoDir     = "var/lib/dpkg/info/"
+	apkInstalledDB  = "lib/apk/db/installed"
+	maxDatabaseSize = 64 << 20
+)
+
+// isPackageDatabase reports whether a normalized path is a known package
+// database or dpkg file list
+func isPackageDatabase(p string) bool {
+	return p == dpkgStatusPath ||
+		p == apkInstalledDB ||
+		strings.HasPrefix(p, dpkgStatusDir) ||
+		isFileList(p)
+}
+
+// isFileList reports whether p lists the files of a single dpkg package:
+// info/<name>.list, or status.d/<name>.md5sums on distroless images
+func isFileList(p string) bool {
+	return (strings.HasPrefix(p, dpkgInfoDir) && strings.HasSuffix(p, ".list")) ||
+		(strings.HasPrefix(p, dpkgStatusDir) && strings.HasSuffix(p, ".md5sums"))
+}
+
+// parsePackageDatabase parses the package database stored at p
+// Both dpkg and apk databases are stanza based "Key: value" files
+func parsePackageDatabase(p string, r io.Reader) ([]Package, error) {
+	switch {
+	case p == apkInstalledDB:
+		return parseStanzas(p, "apk", r, "P", "V")
+	case isFileList(p):
+		return parseFileList(p, r)
+	}
+	return parseStanzas(p, "dpkg", r, "Package", "Version")
+}
+
+// parseFileList reads a dpkg file list into a package carrying only its
+// name and files; Packages attaches them to the package of that name
+func parseFileList(p string, r io.Reader) ([]Package, error) {
+	sc := bufio.NewScanner(io.LimitReader(r, maxDatabaseSize))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	name := strings.TrimSuffix(strings.TrimSuffix(path.Base(p), ".list"), ".md5sums")
+	name, _, _ = strings.Cut(name, ":") // drop the architecture qualifier
+	pkg := Package{Name: name, Manager: "dpkg", Source: p}
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		// md5sums lines are "<checksum>  <path>"
+		if strings.HasSuffix(p, ".md5sums") {
+			_, line, _ = strings.Cut(line, "  ")
+		}
+		if f := normalizePath(line); f != "" && f != "." {
+			pkg.Files = append(pkg.Files, f)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return []Package{pkg}, nil
+}
+
+// parseStanzas reads blank-line separated stanzas and extracts name/version keys
+// dpkg entries whose Status is not "installed" are skipped
+func parseStanzas(source, manager string, r io.Reader, nameKey, versionKey string) ([]Package, error) {
+	sc := bufio.NewScanner(io.LimitReader(r, maxDatabaseSize))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pkgs []Package
+	var cur Package
+	var folder string
+	installed := true
+
+	flush := func() {
+		if cur.Name != "" && installed {
+			cur.Manager = manager
+			cur.Source = source
+			pkgs = append(pkgs, cur)
+		}
+		cur = Package{}
+		folder = ""
+		installed = true
+	}
+
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		// Continuation lines belong to multi-line fields we do not track
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case nameKey:
+			cur.Name = value
+		case versionKey:
+			cur.Version = value
+		case "Status":
+			installed = strings.HasSuffix(value, " installed")
+
+		// apk lists the files of a package as folders followed by the
+		// files inside them
+		case "F":
+			if manager == "apk" {
+				folder = value
+			}
+		case "R":
+			if manager == "apk" {
+				cur.Files = append(cur.Files, normalizePath(path.Join(folder, value)))
+			}
+		}
+	}
+	flush()
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return pkgs, nil
+}
+
+// Packages returns the OS packages installed in the final image filesystem
+//
+// Each database file is taken from the topmost layer that contains it and is
+// ignored if a later layer deleted it. Results are sorted by manager and name
+func (i *Image) Packages() []Package {
+	fs, _ := i.mergeLayers()
+
+	bySource := make(map[string][]Package)
+	for _, l := range i.orderedLayers() {
+		layerSources := make(map[string][]Package)
+		for _, p := range l.Packages {
+			layerSources[p.Source] = append(layerSources[p.Source], p)
+		}
+		for src, pkgs := range layerSources {
+			bySource[src] = pkgs
+		}
+	}
+
+	var pkgs []Package
+	lists := make(map[string][]string)
+	for src, list := range bySource {
+		if _, visible := fs[src]; !visible {
+			continue
+		}
+		if isFileList(src) {
+			for _, p := range list {
+				lists[p.Name] = append(lists[p.Name], p.Files...)
+			}
+			continue
+		}
+		pkgs = append(pkgs, list...)
+	}
+
+	for i, p := range pkgs {
+		if p.Manager == "dpkg" && len(p.Files) == 0 {
+			pkgs[i].Files = lists[p.Name]
+		}
+	}
+
+	sort.Slice(pkgs, func(a, b int) bool {
+		if pkgs[a].Manager != pkgs[b].Manager {
+			return pkgs[a].Manager < pkgs[b].Manager
+		}
+		if pkgs[a].Name != pkgs[b].Name {
+			return pkgs[a].Name < pkgs[b].Name
+		}
+		return pkgs[a].Source < pkgs[b].Source
+	})
+
+	return pkgs
+}
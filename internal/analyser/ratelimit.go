@@ -0,0 +1,184 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// rateLimit records the rate limit hints of registry responses
+// It wraps the configured transport for the duration of one fetch or resolve
+type rateLimit struct {
+	next http.RoundTripper
+
+	mu         sync.Mutex
+	retryAfter time.Duration
+	remaining  int
+	limit      int
+	window     time.Duration
+	seen       bool
+}
+
+func newRateLimit(next http.RoundTripper) *rateLimit {
+	if next == nil {
+		next = remote.DefaultTransport
+	}
+	return &rateLimit{next: next, remaining: -1, limit: -1}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *rateLimit) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	r.observe(resp.StatusCode, resp.Header, time.Now())
+	return resp, nil
+}
+
+// observe records the hints carried by a response
+func (r *rateLimit) observe(status int, h http.Header, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Docker Hub style quota headers: "100;w=21600"
+	if n, w, ok := parseQuota(h.Get("RateLimit-Remaining")); ok {
+		r.remaining, r.window, r.seen = n, w, true
+	}
+	if n, _, ok := parseQuota(h.Get("RateLimit-Limit")); ok {
+		r.limit, r.seen = n, true
+	}
+
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(h.Get("Retry-After"), now); ok {
+			r.retryAfter, r.seen = d, true
+		}
+	}
+}
+
+// wait returns how long the registry asked the client to wait
+//
+// An explicit Retry-After wins. Otherwise an exhausted quota is assumed
+// to recover after its window, which is an upper bound
+func (r *rateLimit) wait() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.retryAfter > 0 {
+		return r.retryAfter
+	}
+	if r.remaining == 0 && r.window > 0 {
+		return r.window
+	}
+	return 0
+}
+
+// describe renders the recorded quota for error messages
+func (r *rateLimit) describe() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.seen {
+		return "rate limited"
+	}
+
+	var parts []string
+	if r.remaining >= 0 {
+		quota := fmt.Sprintf("%d", r.remaining)
+		if r.limit >= 0 {
+			quota += fmt.Sprintf(" of %d", r.limit)
+		}
+		if r.window > 0 {
+			quota += fmt.Sprintf(" per %s", r.window)
+		}
+		parts = append(parts, "remaining "+quota)
+	}
+	if r.retryAfter > 0 {
+		parts = append(parts, "retry after "+r.retryAfter.String())
+	}
+	if len(parts) == 0 {
+		return "rate limited"
+	}
+	return "rate limited (" + strings.Join(parts, ", ") + ")"
+}
+
+// annotate attaches the recorded hints to a retryable error
+// Rate limited errors also describe the quota; other errors, such as a
+// 503 during maintenance, only take an explicit Retry-After
+func (r *rateLimit) annotate(err error) error {
+	ae, ok := AsAnalyzerError(err)
+	if !ok || !ae.Temporary() {
+		return err
+	}
+
+	out := *ae
+	if ae.code == CodeRateLimited {
+		out.message = r.describe()
+		out.retryAfter = r.wait()
+		return &out
+	}
+
+	r.mu.Lock()
+	out.retryAfter = r.retryAfter
+	r.mu.Unlock()
+	if out.retryAfter == 0 {
+		return err
+	}
+	return &out
+}
+
+// parseRetryAfter parses a Retry-After value: delay seconds or an HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// parseQuota parses a quota header such as "76;w=21600"
+// It returns the count and the window, which is zero when absent
+func parseQuota(v string) (int, time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, 0, false
+	}
+
+	fields := strings.Split(v, ";")
+
+	n, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil || n < 0 {
+		return 0, 0, false
+	}
+
+	var window time.Duration
+	for _, f := range fields[1:] {
+		key, val, ok := strings.Cut(strings.TrimSpace(f), "=")
+		if !ok || key != "w" {
+			continue
+		}
+		if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+			window = time.Duration(secs) * time.Second
+		}
+	}
+
+	return n, window, true
+}
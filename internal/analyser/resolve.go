@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Resolve returns the current content digest of ref without downloading
+// the manifest body or any layer
+//
+// It issues a single HEAD request per attempt and endpoint, trying mirrors
+// before the upstream. For multi-platform images the digest is the one of
+// the index, not of the platform-specific manifest
+//
+// It emits no progress events: a Resolve ahead of Load would otherwise
+// report the reference resolved twice
+func Resolve(ctx context.Context, ref string, opts ...Option) (string, error) {
+	const op = "resolve"
+
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx, options.timeout)
+	defer cancel()
+
+	if ref == "" {
+		return "", NewError(CodeInvalidReference, op, ref, "image reference cannot be empty", nil)
+	}
+
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	if err != nil {
+		return "", NewError(CodeInvalidReference, op, ref, "invalid image reference format", err)
+	}
+
+	if options.mirrors.Blocked(parsedRef) {
+		return "", NewError(CodeAccessDenied, op, ref, "registry blocked by the registries configuration", nil)
+	}
+
+	rt, err := options.roundTripper()
+	if err != nil {
+		return "", NewError(CodeTLSConfig, op, ref, "invalid transport configuration", err)
+	}
+
+	var digest string
+	_, err = retry(ctx, options.retries, options.backoff, options.breaker.retryGate(endpointRegistries(ref, options)...), func() error {
+		var err error
+		for _, ep := range endpoints(parsedRef, options) {
+			err = options.breaker.do(ctx, op, ref, ep.Registry, func() error {
+				rl := newRateLimit(rt)
+				at := newAuthTrace(options.keychain)
+
+				desc, err := remote.Head(ep.Reference, remoteOptions(ctx, options, rl, at)...)
+				if err != nil {
+					return at.annotate(rl.annotate(MapRegistryError(op, ref, err)))
+				}
+				if _, ok := parsedRef.(name.Digest); ok && parsedRef.Identifier() != desc.Digest.String() {
+					return NewError(CodeFetchFailed, op, ref, "digest mismatch between reference and remote image", nil)
+				}
+				digest = desc.Digest.String()
+				return nil
+			})
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+// Registry returns the registry host of ref, or an empty string if ref is invalid
+func Registry(ref string) string {
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	if err != nil {
+		return ""
+	}
+	return parsedRef.Context().RegistryStr()
+}
+
+// PinDigest returns ref pinned to digest (repository@digest)
+func PinDigest(ref, digest string) (string, error) {
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	if err != nil {
+		return "", NewError(CodeInvalidReference, "pin", ref, "invalid image reference format", err)
+	}
+	return parsedRef.Context().Name() + "@" + digest, nil
+}
+
+// IsDigestPinned reports whether ref names its image by digest
+func IsDigestPinned(ref string) bool {
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	if err != nil {
+		return false
+	}
+	_, ok := parsedRef.(name.Digest)
+	return ok
+}
@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveEventsComeFromTheFetch(t *testing.T) {
+	srv := fakeRegistry(t, http.StatusNotFound, nil, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`)
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/app:1"
+
+	var (
+		mu     sync.Mutex
+		events []EventType
+	)
+	observer := WithObserver(ObserverFunc(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Type == EventResolveStarted || e.Type == EventResolveFinished {
+			events = append(events, e.Type)
+		}
+	}))
+	opts := []Option{observer, WithRetries(0)}
+
+	if _, err := Resolve(context.Background(), ref, opts...); err == nil {
+		t.Fatal("Resolve succeeded against a registry without the image")
+	}
+	if len(events) != 0 {
+		t.Fatalf("Resolve emitted %v, want no events", events)
+	}
+
+	if _, _, err := Load(context.Background(), ref, opts...); err == nil {
+		t.Fatal("Load succeeded against a registry without the image")
+	}
+	if want := []EventType{EventResolveStarted, EventResolveFinished}; !slices.Equal(events, want) {
+		t.Fatalf("Load emitted %v, want %v", events, want)
+	}
+}
+
+func TestRegistryRequestsAreNotRetriedByTheClient(t *testing.T) {
+	var manifests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		manifests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/app:1"
+
+	tests := []struct {
+		name string
+		call func(opts ...Option) error
+	}{
+		{name: "resolve", call: func(opts ...Option) error {
+			_, err := Resolve(context.Background(), ref, opts...)
+			return err
+		}},
+		{name: "load", call: func(opts ...Option) error {
+			_, _, err := Load(context.Background(), ref, opts...)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifests.Store(0)
+			err := tt.call(WithRetries(1), WithBackoff(time.Millisecond))
+			assertCode(t, err, CodeFetchFailed)
+			if got := manifests.Load(); got != 2 {
+				t.Errorf("manifest requests = %d, want 2 (one per attempt)", got)
+			}
+		})
+	}
+}
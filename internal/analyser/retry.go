@@ -16,6 +16,9 @@ import (
 //   - Respect for context cancellation and deadlines
 //   - Exponential backoff with capped delay
 //   - Retry only for temporary AnalyzerError instances
+//   - Retries stop early when allowRetry (optional) denies them
+//   - Registry Retry-After hints replace the backoff; a hint beyond the
+//     context deadline fails fast with the rate limit error
 //   - Protection against duration overflow
 //
 // It returns the number of executions performed (including the first attempt)
@@ -24,6 +27,7 @@ func retry(
 	ctx context.Context,
 	attempts int,
 	baseDelay time.Duration,
+	allowRetry func() bool,
 	fn func() error,
 ) (int, error) {
 
@@ -64,8 +68,18 @@ func retry(
 			break
 		}
 
-		// Compute exponential backoff delay (capped)
+		// A shared retry budget may be exhausted by other calls
+		if allowRetry != nil && !allowRetry() {
+			break
+		}
+
+		// Compute exponential backoff delay (capped), unless the registry
+		// said how long to wait
 		delay := exponentialBackoff(baseDelay, attempt)
+		hinted := retryAfter(err)
+		if hinted > 0 {
+			delay = hinted
+		}
 
 		// Respect remaining context deadline (timeout budget enforcement)
 		if deadline, ok := ctx.Deadline(); ok {
@@ -74,6 +88,10 @@ func retry(
 				return executions, ctx.Err()
 			}
 			if delay > remaining {
+				// Waiting out the deadline cannot satisfy the registry
+				if hinted > 0 {
+					return executions, err
+				}
 				delay = remaining
 			}
 		}
@@ -103,6 +121,14 @@ func isRetryable(err error) bool {
 	return false
 }
 
+// retryAfter returns the delay requested by the registry for err, if any
+func retryAfter(err error) time.Duration {
+	if ae, ok := AsAnalyzerError(err); ok {
+		return ae.RetryAfter()
+	}
+	return 0
+}
+
 // exponentialBackoff calculates a capped exponential delay
 //
 // delay = base * 2^attempt
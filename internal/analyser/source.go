@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// LayerSource provides ordered, lazy access to the contents of the layers
+// of a resolved remote image. Layer blobs are only downloaded when opened
+//
+// Close must be called to release the timeout bound to the source
+type LayerSource struct {
+	Reference string
+	Digest    string
+	layers    []v1.Layer
+	cancel    context.CancelFunc
+}
+
+// Open resolves a remote image and returns a LayerSource for streaming its layers
+// It uses the same fetch, retry and error normalization as Load
+func Open(ctx context.Context, ref string, opts ...Option) (*LayerSource, error) {
+	const op = "open"
+
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// The deadline outlives Open because layers are fetched lazily
+	ctx, cancel := withDefaultTimeout(ctx, options.timeout)
+
+	fetch, _, err := fetchAttempts(ctx, ref, options)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rawImg := fetch.image
+
+	digest, err := rawImg.Digest()
+	if err != nil {
+		cancel()
+		return nil, NewError(CodeDigestFailed, op, ref, "failed to resolve image digest", err)
+	}
+
+	layers, err := rawImg.Layers()
+	if err != nil {
+		cancel()
+		return nil, NewError(CodeBuildFailed, op, ref, "failed to retrieve image layers", err)
+	}
+
+	return &LayerSource{
+		Reference: ref,
+		Digest:    digest.String(),
+		layers:    sourceLayers(layers, ref, options),
+		cancel:    cancel,
+	}, nil
+}
+
+// Close releases the resources bound to the source
+func (s *LayerSource) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+// Len returns the number of layers in the image
+func (s *LayerSource) Len() int {
+	return len(s.layers)
+}
+
+// Uncompressed opens the uncompressed tar stream of the layer at index
+// The caller is responsible for closing the returned reader
+func (s *LayerSource) Uncompressed(index int) (io.ReadCloser, error) {
+	const op = "open_layer"
+
+	if index < 0 || index >= len(s.layers) {
+		return nil, NewError(CodeLayerExtract, op, s.Reference, fmt.Sprintf("layer %d out of range", index), nil)
+	}
+
+	rc, err := s.layers[index].Uncompressed()
+	if err != nil {
+		return nil, NewError(CodeLayerExtract, op, s.Reference, fmt.Sprintf("failed to open layer %d", index), err)
+	}
+	return rc, nil
+}
+
+// sourceLayers wraps layers for streaming through the cache and observer
+func sourceLayers(layers []v1.Layer, ref string, opts *options) []v1.Layer {
+	wrapped, _ := wrapLayers(layers, ref, opts)
+	return wrapped
+}
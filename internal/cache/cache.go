@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrMiss is returned by Get when no entry exists for a key
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores immutable entries across runs
+//
+// Keys are content addresses (a digest plus a namespace), so an entry never
+// changes once written. Implementations must be safe for concurrent use
+type Cache interface {
+	// Get opens the entry stored under key, or returns ErrMiss
+	// The caller is responsible for closing the returned reader
+	Get(key string) (io.ReadCloser, error)
+
+	// Put stores the contents of r under key
+	// A failed or partial read of r must not leave an entry behind
+	Put(key string, r io.Reader) error
+}
+
+// Key namespaces used by the analyzer
+const (
+	// KindBlob holds compressed layer blobs, keyed by layer digest
+	KindBlob = "blob"
+
+	// KindLayer holds per-layer analysis, keyed by layer digest
+	KindLayer = "layer"
+
+	// KindResult holds whole analysis results, keyed by image digest
+	KindResult = "result"
+)
+
+// Key builds the cache key of digest within kind
+func Key(kind, digest string) string {
+	return kind + "/" + digest
+}
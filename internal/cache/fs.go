@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tmpPrefix = ".tmp-"
+
+	// staleTemp is the age after which temp files of crashed writers are removed
+	staleTemp = time.Hour
+)
+
+// FS is a Cache backed by a directory
+//
+// Entries are written to a temp file and renamed into place, so readers in
+// any process only ever observe complete entries. Reads refresh the entry's
+// modification time, which eviction uses as the LRU order. Several processes
+// may share a directory: every operation is atomic or idempotent
+type FS struct {
+	dir      string
+	maxBytes int64
+
+	// mu serializes eviction within the process
+	mu sync.Mutex
+}
+
+// NewFS creates a cache rooted at dir
+// maxBytes bounds the total size of entries; zero means unbounded
+func NewFS(dir string, maxBytes int64) (*FS, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory cannot be empty")
+	}
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("invalid cache size %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+	return &FS{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}, nil
+}
+
+// Dir returns the root directory of the cache
+func (c *FS) Dir() string {
+	return c.dir
+}
+
+// path maps key to its file
+// Keys are hashed so arbitrary strings are safe on every filesystem
+func (c *FS) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// Get implements Cache
+func (c *FS) Get(key string) (io.ReadCloser, error) {
+	p := c.path(key)
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Best effort: a failed touch only makes the entry look older
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	return f, nil
+}
+
+// Put implements Cache
+func (c *FS) Put(key string, r io.Reader) error {
+	p := c.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temp file on every failure path
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return err
+	}
+	committed = true
+
+	return c.evict()
+}
+
+// entry is a cache file considered for eviction
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// evict removes least recently used entries until the cache fits maxBytes
+func (c *FS) evict() error {
+	if c.maxBytes == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		entries []entry
+		total   int64
+	)
+
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Entries may disappear under a concurrent eviction
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), tmpPrefix) {
+			if time.Since(info.ModTime()) > staleTemp {
+				os.Remove(p)
+			}
+			return nil
+		}
+
+		entries = append(entries, entry{path: p, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= e.size
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestFSEviction(t *testing.T) {
+	type op struct {
+		// get reads key instead of writing size bytes under it
+		get  bool
+		key  string
+		size int
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		ops      []op
+		want     []string
+	}{
+		{
+			name: "unbounded",
+			ops:  []op{{key: "a", size: 100}, {key: "b", size: 100}, {key: "c", size: 100}},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:     "within bounds",
+			maxBytes: 300,
+			ops:      []op{{key: "a", size: 100}, {key: "b", size: 100}, {key: "c", size: 100}},
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "oldest evicted first",
+			maxBytes: 300,
+			ops:      []op{{key: "a", size: 100}, {key: "b", size: 100}, {key: "c", size: 100}, {key: "d", size: 100}},
+			want:     []string{"b", "c", "d"},
+		},
+		{
+			name:     "reads refresh entries",
+			maxBytes: 300,
+			ops:      []op{{key: "a", size: 100}, {key: "b", size: 100}, {key: "c", size: 100}, {get: true, key: "a"}, {key: "d", size: 100}},
+			want:     []string{"a", "c", "d"},
+		},
+		{
+			name:     "large entry evicts several",
+			maxBytes: 300,
+			ops:      []op{{key: "a", size: 100}, {key: "b", size: 100}, {key: "c", size: 100}, {key: "d", size: 250}},
+			want:     []string{"d"},
+		},
+		{
+			name:     "entry larger than the cache is not kept",
+			maxBytes: 300,
+			ops:      []op{{key: "a", size: 100}, {key: "b", size: 400}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewFS(t.TempDir(), tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Written entries are aged a minute apart so the LRU order does
+			// not depend on the clock resolution; reads touch them to now
+			written := time.Now().Add(-time.Hour)
+			for _, o := range tt.ops {
+				if o.get {
+					rc, err := c.Get(o.key)
+					if err != nil {
+						t.Fatalf("Get(%s) = %v", o.key, err)
+					}
+					rc.Close()
+					continue
+				}
+
+				if err := c.Put(o.key, bytes.NewReader(make([]byte, o.size))); err != nil {
+					t.Fatalf("Put(%s) = %v", o.key, err)
+				}
+				written = written.Add(time.Minute)
+				if err := os.Chtimes(c.path(o.key), written, written); err != nil && !errors.Is(err, os.ErrNotExist) {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			for _, o := range tt.ops {
+				rc, err := c.Get(o.key)
+				if errors.Is(err, ErrMiss) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Get(%s) = %v", o.key, err)
+				}
+				rc.Close()
+				if !slices.Contains(got, o.key) {
+					got = append(got, o.key)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFSRemovesStaleTempFiles(t *testing.T) {
+	c, err := NewFS(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := filepath.Join(c.Dir(), tmpPrefix+"crashed")
+	fresh := filepath.Join(c.Dir(), tmpPrefix+"writing")
+	for _, p := range []string{stale, fresh} {
+		if err := os.WriteFile(p, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTemp)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Put("a", strings.NewReader("entry")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale temp file kept: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("temp file of a running writer removed: %v", err)
+	}
+}
+
+func TestFSPutFailureLeavesNoEntry(t *testing.T) {
+	c, err := NewFS(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := c.Put("a", broken); err == nil {
+		t.Fatal("Put succeeded with a failing reader")
+	}
+	if _, err := c.Get("a"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get after a failed Put = %v, want ErrMiss", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(c.path("a")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("failed Put left %d files behind", len(entries))
+	}
+}
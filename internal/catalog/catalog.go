@@ -0,0 +1,187 @@
+// Package catalog describes base images known locally
+//
+// A Catalog is a JSON file listing base images by name, digest and the
+// diff IDs of their layers, so the leading layers of an image can be
+// attributed to its base without contacting a registry. Bases also
+// describe their size and contents, so smaller alternatives can be
+// recommended
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Catalog lists known base images
+type Catalog struct {
+	Bases []Base `json:"bases"`
+}
+
+// Base is a known base image
+type Base struct {
+	// Name is the image reference, such as "docker.io/library/debian:12-slim"
+	Name string `json:"name"`
+
+	// Digest is the manifest digest of the image, if known
+	Digest string `json:"digest,omitempty"`
+
+	// DiffIDs are the uncompressed digests of the layers, base first;
+	// empty for scratch
+	DiffIDs []string `json:"diffIds,omitempty"`
+
+	// Size is the uncompressed size of the image in bytes
+	Size int64 `json:"size,omitempty"`
+
+	// Libc is the C library the base provides: LibcGlibc, LibcMusl, or
+	// empty for bases without one such as scratch and static distroless
+	Libc string `json:"libc,omitempty"`
+
+	// Shell reports that the base has /bin/sh
+	Shell bool `json:"shell,omitempty"`
+
+	// CABundle reports that the base has a CA certificate bundle
+	CABundle bool `json:"caBundle,omitempty"`
+}
+
+// C libraries a base can provide
+const (
+	LibcGlibc = "glibc"
+	LibcMusl  = "musl"
+)
+
+// Load reads a catalog file
+func Load(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse reads a catalog in its JSON form:
+//
+//	{
+//	  "bases": [
+//	    {
+//	      "name": "docker.io/library/debian:12-slim",
+//	      "digest": "sha256:...",
+//	      "diffIds": ["sha256:..."],
+//	      "size": 74801152,
+//	      "libc": "glibc",
+//	      "shell": true,
+//	      "caBundle": true
+//	    }
+//	  ]
+//	}
+func Parse(r io.Reader) (*Catalog, error) {
+	var c Catalog
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	for i, b := range c.Bases {
+		if b.Name == "" {
+			return nil, fmt.Errorf("base %d: missing name", i)
+		}
+		switch b.Libc {
+		case "", LibcGlibc, LibcMusl:
+		default:
+			return nil, fmt.Errorf("base %s: unknown libc %q", b.Name, b.Libc)
+		}
+		for _, id := range b.DiffIDs {
+			if !strings.Contains(id, ":") {
+				return nil, fmt.Errorf("base %s: invalid diff ID %q", b.Name, id)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Lookup finds a base by digest, or by name when digest is empty or unknown
+// A nil Catalog has no bases
+func (c *Catalog) Lookup(ref, digest string) (*Base, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	if digest != "" {
+		for i := range c.Bases {
+			if c.Bases[i].Digest == digest {
+				return &c.Bases[i], true
+			}
+		}
+	}
+
+	if ref == "" {
+		return nil, false
+	}
+	key := normalizeName(ref)
+	for i := range c.Bases {
+		if normalizeName(c.Bases[i].Name) == key {
+			return &c.Bases[i], true
+		}
+	}
+	return nil, false
+}
+
+// Match finds the base whose layers are the longest prefix of diffIDs
+// Bases covering every layer are ignored: an image identical to a base has
+// no layers of its own to attribute
+func (c *Catalog) Match(diffIDs []string) (*Base, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	var best *Base
+	for i := range c.Bases {
+		b := &c.Bases[i]
+		if len(b.DiffIDs) == 0 || len(b.DiffIDs) >= len(diffIDs) || !IsPrefix(b.DiffIDs, diffIDs) {
+			continue
+		}
+		if best == nil || len(b.DiffIDs) > len(best.DiffIDs) {
+			best = b
+		}
+	}
+	return best, best != nil
+}
+
+// IsPrefix reports whether base is a prefix of diffIDs
+func IsPrefix(base, diffIDs []string) bool {
+	if len(base) > len(diffIDs) {
+		return false
+	}
+	for i := range base {
+		if base[i] != diffIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SameImage reports whether two references name the same image, so
+// "debian:12" and "docker.io/library/debian:12" are the same
+func SameImage(a, b string) bool {
+	return normalizeName(a) == normalizeName(b)
+}
+
+// normalizeName expands a reference so "debian:12" and
+// "docker.io/library/debian:12" compare equal
+func normalizeName(ref string) string {
+	if r, err := name.ParseReference(ref); err == nil {
+		return r.Name()
+	}
+	return ref
+}
@@ -0,0 +1,241 @@
+// Package credentials composes registry credential sources into one keychain
+//
+// Every Source has a name used in errors, so a failed authentication tells
+// which source was tried. Names never include secrets
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/docker-credential-helpers/client"
+	dockercreds "github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Source is a named credential source
+type Source interface {
+	authn.Keychain
+
+	// Name identifies the source in errors
+	Name() string
+}
+
+// SourceError reports a credential source that failed to produce credentials
+// It names the source; the underlying error never carries the secret
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+// Error implements the error interface
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("credential source %s: %v", e.Source, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// source implements Source with a resolve function
+// It returns authn.Anonymous when it has no credentials for the target
+type source struct {
+	name    string
+	resolve func(ctx context.Context, target authn.Resource) (authn.Authenticator, error)
+}
+
+func (s *source) Name() string { return s.name }
+
+func (s *source) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return s.ResolveContext(context.Background(), target)
+}
+
+func (s *source) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	auth, err := s.resolve(ctx, target)
+	if err != nil {
+		return nil, &SourceError{Source: s.name, Err: err}
+	}
+	return auth, nil
+}
+
+// Default is authn.DefaultKeychain: the docker config of the user, or the
+// podman auth file
+func Default() Source {
+	return Keychain("default keychain", authn.DefaultKeychain)
+}
+
+// Keychain names an arbitrary keychain as a Source
+func Keychain(name string, kc authn.Keychain) Source {
+	return &source{
+		name: name,
+		resolve: func(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+			return authn.Resolve(ctx, kc, target)
+		},
+	}
+}
+
+// Static returns fixed credentials for the registry host
+func Static(host, username, password string) Source {
+	key := hostKey(host)
+	return &source{
+		name: "static credentials for " + host,
+		resolve: func(_ context.Context, target authn.Resource) (authn.Authenticator, error) {
+			if target.RegistryStr() != key {
+				return authn.Anonymous, nil
+			}
+			return authn.FromConfig(authn.AuthConfig{Username: username, Password: password}), nil
+		},
+	}
+}
+
+// DockerConfig reads the docker config.json at path, honoring its
+// credsStore and credHelpers entries
+// The file is read on every resolution so updates are picked up
+func DockerConfig(path string) Source {
+	return &source{
+		name: "docker config " + path,
+		resolve: func(_ context.Context, target authn.Resource) (authn.Authenticator, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+
+			cf, err := config.LoadFromReader(f)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, key := range []string{target.String(), target.RegistryStr()} {
+				if key == name.DefaultRegistry {
+					key = authn.DefaultAuthKey
+				}
+
+				cfg, err := cf.GetAuthConfig(key)
+				if err != nil {
+					return nil, err
+				}
+
+				ac := authn.AuthConfig{
+					Username:      cfg.Username,
+					Password:      cfg.Password,
+					Auth:          cfg.Auth,
+					IdentityToken: cfg.IdentityToken,
+					RegistryToken: cfg.RegistryToken,
+				}
+				if ac != (authn.AuthConfig{}) {
+					return authn.FromConfig(ac), nil
+				}
+			}
+
+			return authn.Anonymous, nil
+		},
+	}
+}
+
+// TokenFile sends the bearer token stored at path to the registry host, or
+// to every registry when host is empty
+// The file is read on every resolution, so rotated tokens such as mounted
+// service account tokens are picked up
+func TokenFile(host, path string) Source {
+	key := hostKey(host)
+	return &source{
+		name: "token file " + path,
+		resolve: func(_ context.Context, target authn.Resource) (authn.Authenticator, error) {
+			if host != "" && target.RegistryStr() != key {
+				return authn.Anonymous, nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			token := strings.TrimSpace(string(data))
+			if token == "" {
+				return nil, fmt.Errorf("token file is empty")
+			}
+			return authn.FromConfig(authn.AuthConfig{RegistryToken: token}), nil
+		},
+	}
+}
+
+// Helper runs the docker credential helper docker-credential-<helper> for
+// the registry host, or for every registry when host is empty
+func Helper(host, helper string) Source {
+	key := hostKey(host)
+	program := client.NewShellProgramFunc("docker-credential-" + helper)
+
+	return &source{
+		name: "credential helper docker-credential-" + helper,
+		resolve: func(_ context.Context, target authn.Resource) (authn.Authenticator, error) {
+			if host != "" && target.RegistryStr() != key {
+				return authn.Anonymous, nil
+			}
+
+			serverURL := target.RegistryStr()
+			if serverURL == name.DefaultRegistry {
+				serverURL = authn.DefaultAuthKey
+			}
+
+			creds, err := client.Get(program, serverURL)
+			if err != nil {
+				if dockercreds.IsErrCredentialsNotFound(err) {
+					return authn.Anonymous, nil
+				}
+				return nil, err
+			}
+
+			// Helpers return identity tokens under a sentinel username
+			if creds.Username == "<token>" {
+				return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+			}
+			return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+		},
+	}
+}
+
+// Chain tries sources in order; the first one with credentials for a
+// registry wins, and anonymous access is used when none has any
+// A failing source stops the chain with a *SourceError naming it
+type Chain []Source
+
+// Resolve implements authn.Keychain
+func (c Chain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return c.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements authn.ContextKeychain
+func (c Chain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	auth, _, err := c.ResolveSource(ctx, target)
+	return auth, err
+}
+
+// ResolveSource resolves target and also returns the name of the source
+// that answered; the name is empty for anonymous access
+func (c Chain) ResolveSource(ctx context.Context, target authn.Resource) (authn.Authenticator, string, error) {
+	for _, s := range c {
+		auth, err := authn.Resolve(ctx, s, target)
+		if err != nil {
+			return nil, s.Name(), err
+		}
+		if auth != authn.Anonymous {
+			return auth, s.Name(), nil
+		}
+	}
+	return authn.Anonymous, "", nil
+}
+
+// hostKey normalizes host as references do, so "docker.io" matches
+// Docker Hub references
+func hostKey(host string) string {
+	if reg, err := name.NewRegistry(strings.TrimSpace(host)); err == nil {
+		return reg.Name()
+	}
+	return host
+}
@@ -0,0 +1,205 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// helperScript is a docker credential helper that knows helper.example.com
+// and an identity token for token.example.com
+const helperScript = `#!/bin/sh
+read server
+case "$server" in
+helper.example.com) echo '{"ServerURL":"helper.example.com","Username":"robot","Secret":"helper-secret"}' ;;
+token.example.com) echo '{"ServerURL":"token.example.com","Username":"<token>","Secret":"identity"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string, perm os.FileMode) string {
+	t.Helper()
+
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestChainResolveSource(t *testing.T) {
+	dir := t.TempDir()
+
+	auth := base64.StdEncoding.EncodeToString([]byte("config-user:config-pass"))
+	dockerConfig := writeFile(t, dir, "config.json", `{"auths":{
+		"registry.example.com": {"auth": "`+auth+`"},
+		"registry.example.com/team/app": {"username": "repo-user", "password": "repo-pass"},
+		"https://index.docker.io/v1/": {"username": "hub-user", "password": "hub-pass"}
+	}}`, 0o600)
+	token := writeFile(t, dir, "token", "  bearer-token\n", 0o600)
+	empty := writeFile(t, dir, "empty", "\n", 0o600)
+	writeFile(t, dir, "docker-credential-test", helperScript, 0o755)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name    string
+		chain   Chain
+		repo    string
+		want    authn.AuthConfig
+		source  string
+		wantErr string
+	}{
+		{
+			name:   "docker config by registry",
+			chain:  Chain{DockerConfig(dockerConfig)},
+			repo:   "registry.example.com/other",
+			want:   authn.AuthConfig{Username: "config-user", Password: "config-pass"},
+			source: "docker config " + dockerConfig,
+		},
+		{
+			name:   "docker config by repository",
+			chain:  Chain{DockerConfig(dockerConfig)},
+			repo:   "registry.example.com/team/app",
+			want:   authn.AuthConfig{Username: "repo-user", Password: "repo-pass"},
+			source: "docker config " + dockerConfig,
+		},
+		{
+			name:   "docker config for Docker Hub",
+			chain:  Chain{DockerConfig(dockerConfig)},
+			repo:   "docker.io/library/alpine",
+			want:   authn.AuthConfig{Username: "hub-user", Password: "hub-pass"},
+			source: "docker config " + dockerConfig,
+		},
+		{
+			name:  "docker config without entry",
+			chain: Chain{DockerConfig(dockerConfig)},
+			repo:  "quay.io/app",
+		},
+		{
+			name:   "token file for its host",
+			chain:  Chain{TokenFile("registry.example.com", token), DockerConfig(dockerConfig)},
+			repo:   "registry.example.com/app",
+			want:   authn.AuthConfig{RegistryToken: "bearer-token"},
+			source: "token file " + token,
+		},
+		{
+			name:   "token file for another host falls through",
+			chain:  Chain{TokenFile("quay.io", token), DockerConfig(dockerConfig)},
+			repo:   "registry.example.com/app",
+			want:   authn.AuthConfig{Username: "config-user", Password: "config-pass"},
+			source: "docker config " + dockerConfig,
+		},
+		{
+			name:   "token file for every host",
+			chain:  Chain{TokenFile("", token)},
+			repo:   "quay.io/app",
+			want:   authn.AuthConfig{RegistryToken: "bearer-token"},
+			source: "token file " + token,
+		},
+		{
+			name:    "empty token file",
+			chain:   Chain{TokenFile("", empty), DockerConfig(dockerConfig)},
+			repo:    "registry.example.com/app",
+			source:  "token file " + empty,
+			wantErr: "credential source token file " + empty + ": token file is empty",
+		},
+		{
+			name:   "helper",
+			chain:  Chain{Helper("", "test")},
+			repo:   "helper.example.com/app",
+			want:   authn.AuthConfig{Username: "robot", Password: "helper-secret"},
+			source: "credential helper docker-credential-test",
+		},
+		{
+			name:   "helper identity token",
+			chain:  Chain{Helper("token.example.com", "test")},
+			repo:   "token.example.com/app",
+			want:   authn.AuthConfig{IdentityToken: "identity"},
+			source: "credential helper docker-credential-test",
+		},
+		{
+			name:   "helper without credentials falls through",
+			chain:  Chain{Helper("", "test"), DockerConfig(dockerConfig)},
+			repo:   "registry.example.com/app",
+			want:   authn.AuthConfig{Username: "config-user", Password: "config-pass"},
+			source: "docker config " + dockerConfig,
+		},
+		{
+			name:    "missing helper",
+			chain:   Chain{Helper("", "missing")},
+			repo:    "helper.example.com/app",
+			source:  "credential helper docker-credential-missing",
+			wantErr: "credential source credential helper docker-credential-missing",
+		},
+		{
+			name:   "static credentials",
+			chain:  Chain{Static("docker.io", "static-user", "static-pass"), DockerConfig(dockerConfig)},
+			repo:   "index.docker.io/library/alpine",
+			want:   authn.AuthConfig{Username: "static-user", Password: "static-pass"},
+			source: "static credentials for docker.io",
+		},
+		{name: "empty chain", repo: "registry.example.com/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := name.NewRepository(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auth, source, err := tt.chain.ResolveSource(context.Background(), repo)
+			if source != tt.source {
+				t.Errorf("source = %q, want %q", source, tt.source)
+			}
+			if tt.wantErr != "" {
+				var se *SourceError
+				if !errors.As(err, &se) || se.Source != tt.source {
+					t.Fatalf("error = %v, want a SourceError from %s", err, tt.source)
+				}
+				if !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("error = %q, want prefix %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == (authn.AuthConfig{}) {
+				if auth != authn.Anonymous {
+					t.Errorf("auth = %v, want anonymous", auth)
+				}
+				return
+			}
+			got, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("auth = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingDockerConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	repo, err := name.NewRepository("registry.example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DockerConfig(path).Resolve(repo)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error = %v, want the missing file", err)
+	}
+}
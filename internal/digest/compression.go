@@ -0,0 +1,103 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+// CompressionRecommendation is a layer media type change with its estimated
+// transfer savings
+type CompressionRecommendation struct {
+	// MediaType is the recommended layer media type
+	MediaType string
+	Level     int
+
+	// Layers are the indexes of the layers to recompress
+	Layers []int
+
+	// CurrentSize and EstimatedSize are the compressed sizes of Layers
+	// before and after the change
+	CurrentSize   int64
+	EstimatedSize int64
+
+	// Incompressible lists layers left as they are because most of their
+	// content is already compressed
+	Incompressible []int
+
+	Description string
+}
+
+// Savings is the estimated reduction in transfer size
+func (r *CompressionRecommendation) Savings() int64 {
+	return r.CurrentSize - r.EstimatedSize
+}
+
+// RecommendCompression recommends recompressing the gzip layers of img with
+// zstd at the level that saves the most, using the estimates recorded by
+// WithCompressionEstimates. It returns nil when no layer has estimates or
+// nothing would be saved
+//
+// Base layers are left alone so they stay shared with other images of the
+// same base, and so are eStargz layers and layers mostly made of
+// compressed files
+func RecommendCompression(img *analyzer.Image) *CompressionRecommendation {
+	if img == nil {
+		return nil
+	}
+
+	var best *CompressionRecommendation
+	for _, level := range analyzer.ZstdLevels {
+		rec := &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: level}
+
+		for _, l := range sortedLayers(img) {
+			if img.IsBaseLayer(l.Index) || !isGzip(l) {
+				continue
+			}
+			if l.IsIncompressible() {
+				rec.Incompressible = append(rec.Incompressible, l.Index)
+				continue
+			}
+
+			est, ok := l.Estimate(analyzer.CompressionZstd, level)
+			if !ok || est.Size >= l.CompressedSize {
+				continue
+			}
+			rec.Layers = append(rec.Layers, l.Index)
+			rec.CurrentSize += l.CompressedSize
+			rec.EstimatedSize += est.Size
+		}
+
+		// Lower levels win ties: they are cheaper to push
+		if len(rec.Layers) > 0 && (best == nil || rec.Savings() > best.Savings()) {
+			best = rec
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	best.Description = fmt.Sprintf("recompress %d layers as zstd level %d, saving about %d of %d compressed bytes",
+		len(best.Layers), best.Level, best.Savings(), best.CurrentSize)
+	if img.MediaType == string(types.DockerManifestSchema2) {
+		best.Description += "; zstd layers need the manifest converted to OCI"
+	}
+	if len(best.Incompressible) > 0 {
+		best.Description += fmt.Sprintf("; layers %s are mostly compressed files and gain little", joinInts(best.Incompressible))
+	}
+	return best
+}
+
+// isGzip reports whether l is a plain gzip layer. eStargz layers are gzip
+// too, but recompressing them would lose lazy pulling. Layers without a
+// recorded compression are judged by media type
+func isGzip(l analyzer.Layer) bool {
+	if l.Compression != "" {
+		return l.Compression == analyzer.CompressionGzip
+	}
+	return l.MediaType == string(types.DockerLayer) || l.MediaType == string(types.OCILayer) ||
+		strings.HasSuffix(l.MediaType, "+gzip")
+}
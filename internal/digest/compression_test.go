@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+// zstdLayer is a gzip layer of compressed bytes with zstd estimates by level
+func zstdLayer(index int, compressed int64, estimates map[int]int64) analyzer.Layer {
+	l := analyzer.Layer{
+		Index:            index,
+		MediaType:        string(types.OCILayer),
+		Compression:      analyzer.CompressionGzip,
+		CompressedSize:   compressed,
+		UncompressedSize: 4 * compressed,
+	}
+	for level, size := range estimates {
+		l.Estimates = append(l.Estimates, analyzer.CompressionEstimate{Format: analyzer.CompressionZstd, Level: level, Size: size})
+	}
+	return l
+}
+
+func TestRecommendCompression(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       *analyzer.Image
+		want      *CompressionRecommendation
+		wantInDoc string
+	}{
+		{name: "nil image"},
+		{
+			name: "no estimates",
+			img:  &analyzer.Image{Layers: []analyzer.Layer{zstdLayer(0, 1000, nil)}},
+		},
+		{
+			name: "best level",
+			img: &analyzer.Image{Layers: []analyzer.Layer{
+				zstdLayer(0, 1000, map[int]int64{1: 900, 3: 800, 7: 700}),
+				zstdLayer(1, 2000, map[int]int64{1: 1900, 3: 1700, 7: 1600}),
+			}},
+			want: &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: 7, Layers: []int{0, 1}, CurrentSize: 3000, EstimatedSize: 2300},
+		},
+		{
+			name: "lower level wins ties",
+			img: &analyzer.Image{Layers: []analyzer.Layer{
+				zstdLayer(0, 1000, map[int]int64{1: 900, 3: 800, 7: 800}),
+			}},
+			want: &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: 3, Layers: []int{0}, CurrentSize: 1000, EstimatedSize: 800},
+		},
+		{
+			name: "no savings",
+			img:  &analyzer.Image{Layers: []analyzer.Layer{zstdLayer(0, 1000, map[int]int64{1: 1000, 3: 1100, 7: 1200})}},
+		},
+		{
+			name: "base, eStargz and zstd layers are left alone",
+			img: func() *analyzer.Image {
+				stargz := zstdLayer(2, 1000, map[int]int64{1: 500})
+				stargz.Compression = analyzer.CompressionEstargz
+				zst := zstdLayer(3, 1000, map[int]int64{1: 500})
+				zst.Compression = analyzer.CompressionZstd
+				return &analyzer.Image{
+					Base:   &analyzer.BaseImage{Layers: 1},
+					Layers: []analyzer.Layer{zstdLayer(0, 1000, map[int]int64{1: 500}), zstdLayer(1, 1000, map[int]int64{1: 900}), stargz, zst},
+				}
+			}(),
+			want: &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: 1, Layers: []int{1}, CurrentSize: 1000, EstimatedSize: 900},
+		},
+		{
+			name: "incompressible layers are reported",
+			img: func() *analyzer.Image {
+				jars := zstdLayer(1, 1000, map[int]int64{1: 990})
+				jars.Files = []analyzer.FileEntry{{Path: "app/lib.jar", Type: analyzer.FileTypeRegular, Size: 4000}}
+				return &analyzer.Image{Layers: []analyzer.Layer{zstdLayer(0, 1000, map[int]int64{1: 900}), jars}}
+			}(),
+			want:      &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: 1, Layers: []int{0}, CurrentSize: 1000, EstimatedSize: 900, Incompressible: []int{1}},
+			wantInDoc: "layers 1 are mostly compressed files",
+		},
+		{
+			name: "docker manifests need converting",
+			img: &analyzer.Image{
+				MediaType: string(types.DockerManifestSchema2),
+				Layers:    []analyzer.Layer{zstdLayer(0, 1000, map[int]int64{1: 900})},
+			},
+			want:      &CompressionRecommendation{MediaType: string(types.OCILayerZStd), Level: 1, Layers: []int{0}, CurrentSize: 1000, EstimatedSize: 900},
+			wantInDoc: "converted to OCI",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RecommendCompression(tt.img)
+			if got != nil {
+				if !strings.Contains(got.Description, tt.wantInDoc) {
+					t.Errorf("description %q does not mention %q", got.Description, tt.wantInDoc)
+				}
+				got.Description = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecommendCompression = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsGzip(t *testing.T) {
+	tests := []struct {
+		layer analyzer.Layer
+		want  bool
+	}{
+		{layer: analyzer.Layer{Compression: analyzer.CompressionGzip}, want: true},
+		{layer: analyzer.Layer{Compression: analyzer.CompressionEstargz, MediaType: string(types.OCILayer)}},
+		{layer: analyzer.Layer{Compression: analyzer.CompressionZstd}},
+		{layer: analyzer.Layer{MediaType: string(types.DockerLayer)}, want: true},
+		{layer: analyzer.Layer{MediaType: string(types.OCILayer)}, want: true},
+		{layer: analyzer.Layer{MediaType: "application/vnd.example.layer+gzip"}, want: true},
+		{layer: analyzer.Layer{MediaType: string(types.OCILayerZStd)}},
+		{layer: analyzer.Layer{MediaType: string(types.OCIUncompressedLayer)}},
+	}
+
+	for _, tt := range tests {
+		if got := isGzip(tt.layer); got != tt.want {
+			t.Errorf("isGzip(%+v) = %t, want %t", tt.layer, got, tt.want)
+		}
+	}
+}
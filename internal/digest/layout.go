@@ -0,0 +1,236 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+// Layout recommendation kinds
+const (
+	// LayoutSquash merges a run of consecutive small layers into one
+	LayoutSquash = "squash"
+
+	// LayoutReorder moves a frequently changing layer above the stable
+	// layers it currently invalidates
+	LayoutReorder = "reorder"
+)
+
+const (
+	// smallLayerSize is the uncompressed size below which a layer costs
+	// more in per-layer overhead than it saves in cache granularity
+	smallLayerSize = 1 << 20
+
+	// minSquashRun is the number of consecutive small layers worth merging
+	minSquashRun = 3
+)
+
+// LayoutRecommendation is a change to the layer order or count of an image
+// that improves registry dedup and pull caching
+type LayoutRecommendation struct {
+	// Kind is LayoutSquash or LayoutReorder
+	Kind string
+
+	// Layers are the indexes concerned: the run to merge, or the layer to
+	// move followed by the layers it should move above
+	Layers []int
+
+	// Bytes is the estimated saving: for a squash, the bytes shadowed
+	// inside the run; for a reorder, the bytes pulled again per release
+	// only because the moved layer sits below them
+	Bytes int64
+
+	Description string
+}
+
+// RecommendLayout inspects the layers of an image and, when history holds
+// earlier versions of it such as previous tags, how they changed
+//
+// history is ordered oldest first and ends with the current image. Only
+// layers of the image itself are considered; base layers are left alone
+// so they stay shared with other images of the same base
+func RecommendLayout(history ...*analyzer.Image) []LayoutRecommendation {
+	if len(history) == 0 || history[len(history)-1] == nil {
+		return nil
+	}
+
+	current := history[len(history)-1]
+
+	recs := squashRuns(current)
+	recs = append(recs, reorders(history)...)
+	return recs
+}
+
+// squashRuns finds runs of consecutive small layers of the image itself
+func squashRuns(img *analyzer.Image) []LayoutRecommendation {
+	layers := sortedLayers(img)
+
+	var recs []LayoutRecommendation
+	var run []analyzer.Layer
+
+	flush := func() {
+		if len(run) >= minSquashRun {
+			idx := make([]int, len(run))
+			var size int64
+			for i, l := range run {
+				idx[i] = l.Index
+				size += l.UncompressedSize
+			}
+			shadowed := (&analyzer.Image{Layers: run}).WastedBytes()
+			recs = append(recs, LayoutRecommendation{
+				Kind:   LayoutSquash,
+				Layers: idx,
+				Bytes:  shadowed,
+				Description: fmt.Sprintf("merge %d small layers (%d bytes in total) into one, saving %d layer fetches and %d shadowed bytes",
+					len(run), size, len(run)-1, shadowed),
+			})
+		}
+		run = nil
+	}
+
+	for _, l := range layers {
+		if img.IsBaseLayer(l.Index) || l.UncompressedSize >= smallLayerSize {
+			flush()
+			continue
+		}
+		run = append(run, l)
+	}
+	flush()
+
+	return recs
+}
+
+// layerChange is how one layer position changed across the history
+type layerChange struct {
+	// changed counts releases where the content of the layer changed
+	changed int
+
+	// caused sums, by layer index, the bytes of layers that got a new
+	// digest without a content change in releases where this layer was the
+	// lowest one to change
+	caused map[int]int64
+}
+
+// reorders compares consecutive versions of the image and recommends moving
+// the layer that changes most often above the stable layers it invalidates
+//
+// Builders recreate every layer above a changed one, so stable content
+// placed above a volatile layer gets a new digest and is pulled again on
+// every release. Versions with a different number of layers are skipped
+func reorders(history []*analyzer.Image) []LayoutRecommendation {
+	current := history[len(history)-1]
+	n := len(current.Layers)
+
+	changes := make([]layerChange, n)
+	for j := range changes {
+		changes[j].caused = make(map[int]int64)
+	}
+	var releases int
+
+	for i := 1; i < len(history); i++ {
+		prev, next := history[i-1], history[i]
+		if prev == nil || next == nil || len(prev.Layers) != n || len(next.Layers) != n {
+			continue
+		}
+		releases++
+
+		// Rebuilds are attributed to the lowest changed layer only: moving
+		// a higher one would not have kept them cached
+		a, b := sortedLayers(prev), sortedLayers(next)
+		lowest := -1
+		for j := 0; j < n; j++ {
+			switch {
+			case fingerprint(a[j]) != fingerprint(b[j]):
+				changes[j].changed++
+				if lowest < 0 {
+					lowest = j
+				}
+			case a[j].DiffID != b[j].DiffID && lowest >= 0:
+				changes[lowest].caused[j] += b[j].UncompressedSize
+			}
+		}
+	}
+
+	if releases == 0 {
+		return nil
+	}
+
+	layers := sortedLayers(current)
+
+	var recs []LayoutRecommendation
+	for j := 0; j < n; j++ {
+		// A layer is volatile when its content changes in most releases
+		if current.IsBaseLayer(j) || changes[j].changed*2 < releases {
+			continue
+		}
+
+		var stable []int
+		var bytes int64
+		for k := j + 1; k < n; k++ {
+			if changes[k].changed == 0 && changes[j].caused[k] > 0 {
+				stable = append(stable, k)
+				bytes += changes[j].caused[k]
+			}
+		}
+		if len(stable) == 0 {
+			continue
+		}
+
+		perRelease := bytes / int64(releases)
+		recs = append(recs, LayoutRecommendation{
+			Kind:   LayoutReorder,
+			Layers: append([]int{layers[j].Index}, stable...),
+			Bytes:  perRelease,
+			Description: fmt.Sprintf("layer %d changed in %d of %d releases; moving it above layers %s would keep about %d bytes per release cached",
+				layers[j].Index, changes[j].changed, releases, joinInts(stable), perRelease),
+		})
+	}
+
+	sort.SliceStable(recs, func(a, b int) bool {
+		return recs[a].Bytes > recs[b].Bytes
+	})
+
+	return recs
+}
+
+// fingerprint identifies the content of a layer from its file listing and
+// the content digests of its files, so a layer rebuilt with identical files
+// matches even when timestamps give it a new diff ID. Layers without a
+// listing fall back to their diff ID
+func fingerprint(l analyzer.Layer) string {
+	if len(l.Files) == 0 {
+		return l.DiffID
+	}
+
+	entries := make([]string, len(l.Files))
+	for i, f := range l.Files {
+		entries[i] = fmt.Sprintf("%s\x00%s\x00%d\x00%o\x00%s\x00%t\x00%t\x00%s",
+			f.Path, f.Type, f.Size, f.Mode, f.Linkname, f.Whiteout, f.Opaque, f.Digest)
+	}
+	sort.Strings(entries)
+
+	h := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(h[:])
+}
+
+// sortedLayers returns the layers of img in index order
+func sortedLayers(img *analyzer.Image) []analyzer.Layer {
+	layers := append([]analyzer.Layer(nil), img.Layers...)
+	sort.Slice(layers, func(a, b int) bool {
+		return layers[a].Index < layers[b].Index
+	})
+	return layers
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ", ")
+}
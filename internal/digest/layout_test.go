@@ -0,0 +1,200 @@
+package digest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := analyzer.FileEntry{Path: "app/a", Type: analyzer.FileTypeRegular, Size: 10, Mode: 0o644, Digest: "sha256:a"}
+	b := analyzer.FileEntry{Path: "app/b", Type: analyzer.FileTypeRegular, Size: 20, Mode: 0o644, Digest: "sha256:b"}
+	base := analyzer.Layer{DiffID: "sha256:one", Files: []analyzer.FileEntry{a, b}}
+
+	with := func(f func(*analyzer.Layer)) analyzer.Layer {
+		l := base
+		l.Files = append([]analyzer.FileEntry(nil), base.Files...)
+		f(&l)
+		return l
+	}
+
+	tests := []struct {
+		name  string
+		layer analyzer.Layer
+		same  bool
+	}{
+		{name: "rebuilt with a new diff ID", layer: with(func(l *analyzer.Layer) { l.DiffID = "sha256:two" }), same: true},
+		{name: "entries in another order", layer: with(func(l *analyzer.Layer) { l.Files[0], l.Files[1] = l.Files[1], l.Files[0] }), same: true},
+		{name: "same size, new content", layer: with(func(l *analyzer.Layer) { l.Files[0].Digest = "sha256:c" })},
+		{name: "mode change", layer: with(func(l *analyzer.Layer) { l.Files[0].Mode = 0o755 })},
+		{name: "added file", layer: with(func(l *analyzer.Layer) {
+			l.Files = append(l.Files, analyzer.FileEntry{Path: "app/c", Type: analyzer.FileTypeRegular})
+		})},
+		{name: "whiteout", layer: with(func(l *analyzer.Layer) { l.Files[1] = analyzer.FileEntry{Path: "app/b", Whiteout: true} })},
+		{name: "no listing, same diff ID", layer: analyzer.Layer{DiffID: "sha256:one"}},
+	}
+
+	want := fingerprint(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(tt.layer) == want; got != tt.same {
+				t.Errorf("same fingerprint = %t, want %t", got, tt.same)
+			}
+		})
+	}
+
+	if got := fingerprint(analyzer.Layer{DiffID: "sha256:one"}); got != "sha256:one" {
+		t.Errorf("fingerprint without a listing = %q, want the diff ID", got)
+	}
+}
+
+// release builds a version of a four layer image: a base layer and three
+// layers of the image itself, each given by the content version of its
+// files and its diff ID
+func release(contents [4]int, diffIDs [4]int) *analyzer.Image {
+	img := &analyzer.Image{Base: &analyzer.BaseImage{Name: "debian:12", Layers: 1}}
+	for i := range 4 {
+		img.Layers = append(img.Layers, analyzer.Layer{
+			Index:            i,
+			DiffID:           fmt.Sprintf("sha256:layer%d-build%d", i, diffIDs[i]),
+			UncompressedSize: int64(1000 * (i + 1)),
+			Files: []analyzer.FileEntry{{
+				Path:   fmt.Sprintf("layer%d/file", i),
+				Type:   analyzer.FileTypeRegular,
+				Size:   100,
+				Digest: fmt.Sprintf("sha256:layer%d-v%d", i, contents[i]),
+			}},
+		})
+	}
+	return img
+}
+
+func TestReorders(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []*analyzer.Image
+		want    []LayoutRecommendation
+	}{
+		{
+			name:    "single version",
+			history: []*analyzer.Image{release([4]int{}, [4]int{})},
+		},
+		{
+			// Layer 1 changes every release and invalidates layers 2 and 3,
+			// whose files never change
+			name: "volatile layer below stable ones",
+			history: []*analyzer.Image{
+				release([4]int{0, 1, 0, 0}, [4]int{0, 1, 1, 1}),
+				release([4]int{0, 2, 0, 0}, [4]int{0, 2, 2, 2}),
+				release([4]int{0, 3, 0, 0}, [4]int{0, 3, 3, 3}),
+			},
+			want: []LayoutRecommendation{{Kind: LayoutReorder, Layers: []int{1, 2, 3}, Bytes: 7000}},
+		},
+		{
+			// Layers 1 and 2 both change; the rebuild of layer 3 is caused
+			// by layer 1, the lowest one, and layer 2 invalidates nothing
+			name: "rebuilds attributed to the lowest changed layer",
+			history: []*analyzer.Image{
+				release([4]int{0, 1, 1, 0}, [4]int{0, 1, 1, 1}),
+				release([4]int{0, 2, 2, 0}, [4]int{0, 2, 2, 2}),
+			},
+			want: []LayoutRecommendation{{Kind: LayoutReorder, Layers: []int{1, 3}, Bytes: 4000}},
+		},
+		{
+			name: "stable content rebuilt without a change below",
+			history: []*analyzer.Image{
+				release([4]int{0, 0, 0, 0}, [4]int{0, 1, 1, 1}),
+				release([4]int{0, 0, 0, 0}, [4]int{0, 2, 2, 2}),
+			},
+		},
+		{
+			name: "layer changing in a minority of releases",
+			history: []*analyzer.Image{
+				release([4]int{0, 1, 0, 0}, [4]int{0, 1, 1, 1}),
+				release([4]int{0, 2, 0, 0}, [4]int{0, 2, 2, 2}),
+				release([4]int{0, 2, 0, 1}, [4]int{0, 2, 2, 3}),
+				release([4]int{0, 2, 0, 2}, [4]int{0, 2, 2, 4}),
+				release([4]int{0, 2, 0, 3}, [4]int{0, 2, 2, 5}),
+			},
+		},
+		{
+			name: "base layers are left alone",
+			history: []*analyzer.Image{
+				release([4]int{1, 0, 0, 0}, [4]int{1, 1, 1, 1}),
+				release([4]int{2, 0, 0, 0}, [4]int{2, 2, 2, 2}),
+			},
+		},
+		{
+			name: "versions with another layer count are skipped",
+			history: []*analyzer.Image{
+				{Layers: []analyzer.Layer{{Index: 0, DiffID: "sha256:other"}}},
+				release([4]int{0, 1, 0, 0}, [4]int{0, 1, 1, 1}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reorders(tt.history)
+			for i := range got {
+				if got[i].Description == "" {
+					t.Errorf("recommendation %d has no description", i)
+				}
+				got[i].Description = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reorders = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSquashRuns(t *testing.T) {
+	small := func(i int, files ...analyzer.FileEntry) analyzer.Layer {
+		return analyzer.Layer{Index: i, UncompressedSize: 1000, Files: files}
+	}
+	large := func(i int) analyzer.Layer {
+		return analyzer.Layer{Index: i, UncompressedSize: 2 * smallLayerSize}
+	}
+	motd := func(size int64) analyzer.FileEntry {
+		return analyzer.FileEntry{Path: "etc/motd", Type: analyzer.FileTypeRegular, Size: size}
+	}
+
+	tests := []struct {
+		name   string
+		layers []analyzer.Layer
+		base   int
+		want   []LayoutRecommendation
+	}{
+		{name: "run too short", layers: []analyzer.Layer{large(0), small(1), small(2), large(3)}},
+		{
+			name:   "run of small layers",
+			layers: []analyzer.Layer{large(0), small(1, motd(300)), small(2), small(3, motd(10)), large(4)},
+			want:   []LayoutRecommendation{{Kind: LayoutSquash, Layers: []int{1, 2, 3}, Bytes: 300}},
+		},
+		{
+			name:   "base layers break runs",
+			layers: []analyzer.Layer{small(0), small(1), small(2), small(3)},
+			base:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &analyzer.Image{Layers: tt.layers}
+			if tt.base > 0 {
+				img.Base = &analyzer.BaseImage{Layers: tt.base}
+			}
+
+			got := squashRuns(img)
+			for i := range got {
+				got[i].Description = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("squashRuns = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,12 +7,29 @@ import (
 	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
 )
 
+// Planned layer actions
+const (
+	ActionKeep    = "keep"
+	ActionRemove  = "remove"
+	ActionRebuild = "rebuild"
+)
+
 // LayerPlan represents the planned action for a specific image layer
 type LayerPlan struct {
 	Index       int
 	Digest      string
-	Action      string // "keep", "remove", "rebuild"
+	Action      string // ActionKeep, ActionRemove, ActionRebuild
 	Description string
+
+	// Size is the uncompressed size of the layer, saved if it is removed
+	Size int64
+
+	// Risk and RiskReason assess modifying or removing the layer
+	Risk       RiskLevel
+	RiskReason string
+
+	// Base reports that the layer comes from the base image
+	Base bool
 }
 
 // ImagePlan represents the overall plan for slimming an image, including actions for each layer
@@ -20,6 +37,19 @@ type ImagePlan struct {
 	Reference string
 	Digest    string
 	Layers    []LayerPlan
+
+	// Base is the base image the leading layers come from, if known
+	Base *analyzer.BaseImage
+
+	// Recommendations lists smaller bases the image could be rebuilt on
+	Recommendations []BaseRecommendation
+
+	// Layout lists layer merges and reorders that improve pull caching
+	Layout []LayoutRecommendation
+
+	// Compression recommends a layer media type change, when layers carry
+	// compression estimates and one would save transfer size
+	Compression *CompressionRecommendation
 }
 
 // NewImagePlan creates a new ImagePlan based on the analyzed image data. It initializes all layers with a default action of "keep" and includes descriptive metadata for each layer. Returns an error if the input image is nil
@@ -30,11 +60,16 @@ func NewImagePlan(img *analyzer.Image) (*ImagePlan, error) {
 
 	layers := make([]LayerPlan, len(img.Layers))
 	for i, l := range img.Layers {
+		risk := assessImageLayer(img, l)
 		layers[i] = LayerPlan{
 			Index:       l.Index,
 			Digest:      l.Digest,
-			Action:      "keep",
+			Action:      ActionKeep,
 			Description: fmt.Sprintf("Layer %d size=%d mediaType=%s", l.Index, l.UncompressedSize, l.MediaType),
+			Size:        l.UncompressedSize,
+			Risk:        risk.Level,
+			RiskReason:  risk.Reason,
+			Base:        img.IsBaseLayer(l.Index),
 		}
 	}
 
@@ -42,6 +77,7 @@ func NewImagePlan(img *analyzer.Image) (*ImagePlan, error) {
 		Reference: img.Reference,
 		Digest:    img.Digest,
 		Layers:    layers,
+		Base:      img.Base,
 	}, nil
 }
 
@@ -51,7 +87,7 @@ func (p *ImagePlan) MarkLayerForRemoval(index int, reason string) error {
 	if err != nil {
 		return err
 	}
-	lp.Action = "remove"
+	lp.Action = ActionRemove
 	lp.Description = strings.TrimSpace(lp.Description + " | removal reason: " + reason)
 	return nil
 }
@@ -62,11 +98,37 @@ func (p *ImagePlan) MarkLayerForRebuild(index int, reason string) error {
 	if err != nil {
 		return err
 	}
-	lp.Action = "rebuild"
+	lp.Action = ActionRebuild
 	lp.Description = strings.TrimSpace(lp.Description + " | rebuild reason: " + reason)
 	return nil
 }
 
+// EstimatedSavings returns the uncompressed bytes saved by removing the
+// layers marked for removal
+func (p *ImagePlan) EstimatedSavings() int64 {
+	var total int64
+	for _, l := range p.Layers {
+		if l.Action == ActionRemove {
+			total += l.Size
+		}
+	}
+	return total
+}
+
+// CheckDrift verifies that the plan was built for the image with the given
+// manifest digest. It returns a CodePlanDrift error when the image changed
+// since the plan was built, or when the plan has no digest to verify
+func (p *ImagePlan) CheckDrift(imageDigest string) error {
+	if p.Digest == "" {
+		return analyzer.NewError(analyzer.CodePlanDrift, "check_plan", p.Reference, "plan has no digest to verify", nil)
+	}
+	if p.Digest != imageDigest {
+		return analyzer.NewError(analyzer.CodePlanDrift, "check_plan", p.Reference,
+			fmt.Sprintf("plan was built for %s but the image is now %s", p.Digest, imageDigest), nil)
+	}
+	return nil
+}
+
 // findLayer is a helper method to locate a LayerPlan by its index. Returns an error if the layer is not found in the plan
 func (p *ImagePlan) findLayer(index int) (*LayerPlan, error) {
 	for i := range p.Layers {
@@ -81,8 +143,36 @@ func (p *ImagePlan) findLayer(index int) (*LayerPlan, error) {
 func (p *ImagePlan) Summary() string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Image Plan for %s (digest=%s)\n", p.Reference, p.Digest))
+	if p.Base != nil {
+		sb.WriteString(fmt.Sprintf("Base image: %s (%d layers, from %s)\n", baseLabel(p.Base), p.Base.Layers, p.Base.Source))
+	}
 	for _, l := range p.Layers {
-		sb.WriteString(fmt.Sprintf("- Layer %d: %s | Action: %s\n", l.Index, l.Digest, l.Action))
+		owner := "own"
+		if l.Base {
+			owner = "base"
+		}
+		sb.WriteString(fmt.Sprintf("- Layer %d: %s | %s | Action: %s | Risk: %s\n", l.Index, l.Digest, owner, l.Action, l.Risk))
+	}
+	sb.WriteString(fmt.Sprintf("Estimated savings: %d bytes\n", p.EstimatedSavings()))
+	for _, r := range p.Recommendations {
+		sb.WriteString(fmt.Sprintf("Recommended base: %s (%+d bytes)\n", r.Name, r.SizeDelta))
+		for _, b := range r.Blockers {
+			sb.WriteString(fmt.Sprintf("  blocker: %s\n", b))
+		}
+	}
+	if c := p.Compression; c != nil {
+		sb.WriteString(fmt.Sprintf("Compression: %s\n", c.Description))
+	}
+	for _, r := range p.Layout {
+		sb.WriteString(fmt.Sprintf("Layout (%s): %s\n", r.Kind, r.Description))
 	}
 	return sb.String()
 }
+
+// baseLabel names a base image by reference, or by digest when unnamed
+func baseLabel(b *analyzer.BaseImage) string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return b.Digest
+}
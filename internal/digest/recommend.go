@@ -0,0 +1,244 @@
+package digest
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/catalog"
+)
+
+// caBundlePaths are the CA bundle locations of common distributions
+var caBundlePaths = []string{
+	"etc/ssl/certs/ca-certificates.crt",
+	"etc/pki/tls/certs/ca-bundle.crt",
+	"etc/ssl/cert.pem",
+}
+
+// caSourceDirs hold the certificates the CA bundle is built from
+var caSourceDirs = []string{
+	"usr/local/share/ca-certificates/",
+	"usr/share/ca-certificates/",
+	"etc/pki/ca-trust/source/anchors/",
+}
+
+// shells are the interpreters a shell-form entrypoint or command runs
+var shells = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"ash":  true,
+	"dash": true,
+	"zsh":  true,
+}
+
+// RuntimeNeeds is what an image is expected to need from its base at runtime
+// It is inferred from the layers of the image itself and its config, not
+// from executing the image; what the current base ships goes away with it
+type RuntimeNeeds struct {
+	// Libc is the C library the binaries of the image itself are linked
+	// against, catalog.LibcGlibc or catalog.LibcMusl; empty when they are
+	// static or ship their own
+	Libc string
+
+	// LibcUnknown reports that layers of the image itself were listed
+	// without reading their binaries, so Libc may miss a need
+	LibcUnknown bool
+
+	// Shell reports that the entrypoint or command runs a shell, or that
+	// the layers of the image itself ship shell scripts
+	Shell bool
+
+	// CABundle reports that the layers of the image itself add trusted
+	// certificates without shipping the bundle they are merged into
+	CABundle bool
+}
+
+// BaseRecommendation is a smaller base image the image could be rebuilt on
+type BaseRecommendation struct {
+	Name   string
+	Digest string
+
+	// SizeDelta is the estimated change in uncompressed image size;
+	// negative values are savings
+	SizeDelta int64
+
+	// EstimatedSize is the estimated uncompressed image size on the new base
+	EstimatedSize int64
+
+	// Blockers lists the runtime needs the base does not meet
+	// Recommendations without blockers are expected to be drop-in
+	Blockers []string
+}
+
+// InferRuntimeNeeds derives the runtime needs of img from the layers of
+// the image itself and its config
+func InferRuntimeNeeds(img *analyzer.Image) RuntimeNeeds {
+	var needs RuntimeNeeds
+
+	for _, args := range [][]string{img.Entrypoint, img.Cmd} {
+		if len(args) > 0 && shells[path.Base(args[0])] {
+			needs.Shell = true
+		}
+	}
+
+	// The files of the current base are replaced by those of the new one,
+	// so only what the image itself ships uses the base
+	shipped := make(map[string]bool)
+	interpreters := make(map[string]bool)
+	var certs bool
+
+	for _, l := range img.Layers {
+		if img.IsBaseLayer(l.Index) {
+			continue
+		}
+		if l.InterpretersUnknown {
+			needs.LibcUnknown = true
+		}
+		for _, f := range l.Files {
+			if f.Whiteout {
+				continue
+			}
+			shipped[f.Path] = true
+
+			if f.Type != analyzer.FileTypeRegular {
+				continue
+			}
+			if f.Interpreter != "" {
+				interpreters[strings.TrimPrefix(path.Clean(f.Interpreter), "/")] = true
+			}
+			if strings.HasSuffix(f.Path, ".sh") {
+				needs.Shell = true
+			}
+			if isCASource(f.Path) {
+				certs = true
+			}
+		}
+	}
+
+	// Binaries need the C library of their interpreter unless the image
+	// ships that interpreter too; musl wins when both are used
+	for interp := range interpreters {
+		if shipped[interp] {
+			continue
+		}
+		switch base := path.Base(interp); {
+		case strings.HasPrefix(base, "ld-musl-"):
+			needs.Libc = catalog.LibcMusl
+		case needs.Libc == "" && (strings.HasPrefix(base, "ld-linux") || strings.HasPrefix(base, "ld64.so")):
+			needs.Libc = catalog.LibcGlibc
+		}
+	}
+
+	// Added certificates are merged into the bundle of the base, unless
+	// the image ships its own bundle
+	if certs {
+		needs.CABundle = true
+		for _, p := range caBundlePaths {
+			if shipped[p] {
+				needs.CABundle = false
+			}
+		}
+	}
+
+	return needs
+}
+
+// isCASource reports whether p is a certificate added to the trust store
+func isCASource(p string) bool {
+	for _, dir := range caSourceDirs {
+		if strings.HasPrefix(p, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecommendBases lists the bases of cat smaller than the current base of
+// img, drop-in candidates first and then by savings
+//
+// The current base must be known, with its layers attributed, since the
+// estimate replaces their size with the size of the candidate. Catalog
+// entries without a size are skipped
+func RecommendBases(img *analyzer.Image, cat *catalog.Catalog) ([]BaseRecommendation, error) {
+	if img == nil {
+		return nil, fmt.Errorf("image is nil")
+	}
+	if img.Base == nil || img.Base.Layers == 0 {
+		return nil, fmt.Errorf("base image of %s is unknown", img.Reference)
+	}
+	if cat == nil {
+		return nil, nil
+	}
+
+	var baseSize int64
+	for _, l := range img.Layers {
+		if img.IsBaseLayer(l.Index) {
+			baseSize += l.UncompressedSize
+		}
+	}
+
+	needs := InferRuntimeNeeds(img)
+	total := img.UncompressedSize()
+
+	var recs []BaseRecommendation
+	for _, b := range cat.Bases {
+		if b.Size == 0 || b.Size >= baseSize || isCurrentBase(img.Base, b) {
+			continue
+		}
+
+		delta := b.Size - baseSize
+		recs = append(recs, BaseRecommendation{
+			Name:          b.Name,
+			Digest:        b.Digest,
+			SizeDelta:     delta,
+			EstimatedSize: total + delta,
+			Blockers:      blockers(needs, b),
+		})
+	}
+
+	sort.SliceStable(recs, func(a, b int) bool {
+		if len(recs[a].Blockers) != len(recs[b].Blockers) {
+			return len(recs[a].Blockers) < len(recs[b].Blockers)
+		}
+		if recs[a].SizeDelta != recs[b].SizeDelta {
+			return recs[a].SizeDelta < recs[b].SizeDelta
+		}
+		return recs[a].Name < recs[b].Name
+	})
+
+	return recs, nil
+}
+
+// isCurrentBase reports whether b is the base the image is built on
+func isCurrentBase(current *analyzer.BaseImage, b catalog.Base) bool {
+	if current.Digest != "" && current.Digest == b.Digest {
+		return true
+	}
+	return current.Name != "" && catalog.SameImage(current.Name, b.Name)
+}
+
+// blockers lists the needs b does not meet
+func blockers(needs RuntimeNeeds, b catalog.Base) []string {
+	var out []string
+
+	if needs.Libc != "" && b.Libc != needs.Libc {
+		provided := b.Libc
+		if provided == "" {
+			provided = "no libc"
+		}
+		out = append(out, fmt.Sprintf("image links against %s but the base provides %s", needs.Libc, provided))
+	}
+	if needs.LibcUnknown {
+		out = append(out, "C library of the image is unknown: layers listed from eStargz TOCs record no ELF interpreters")
+	}
+	if needs.Shell && !b.Shell {
+		out = append(out, "image runs a shell but the base has none")
+	}
+	if needs.CABundle && !b.CABundle {
+		out = append(out, "image adds trusted certificates but the base has no CA bundle")
+	}
+
+	return out
+}
@@ -0,0 +1,150 @@
+package digest
+
+import (
+	"reflect"
+	"testing"
+
+	analyzer "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/catalog"
+)
+
+// binary is a regular file run by interp
+func binary(p, interp string) analyzer.FileEntry {
+	return analyzer.FileEntry{Path: p, Type: analyzer.FileTypeRegular, Interpreter: interp}
+}
+
+func TestInferRuntimeNeeds(t *testing.T) {
+	base := analyzer.Layer{Index: 0, Files: []analyzer.FileEntry{
+		binary("bin/busybox", "/lib/ld-musl-x86_64.so.1"),
+		{Path: "bin/sh", Type: analyzer.FileTypeSymlink, Linkname: "busybox"},
+	}}
+
+	tests := []struct {
+		name   string
+		layers []analyzer.Layer
+		cmd    []string
+		want   RuntimeNeeds
+	}{
+		{name: "base layers only", layers: nil},
+		{
+			name:   "glibc binary",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{binary("app", "/lib64/ld-linux-x86-64.so.2")}}},
+			want:   RuntimeNeeds{Libc: catalog.LibcGlibc},
+		},
+		{
+			name: "musl wins over glibc",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{
+				binary("a", "/lib64/ld-linux-x86-64.so.2"),
+				binary("b", "/lib/ld-musl-x86_64.so.1"),
+			}}},
+			want: RuntimeNeeds{Libc: catalog.LibcMusl},
+		},
+		{
+			name: "shipped interpreter",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{
+				binary("app", "/opt/lib/ld-linux-x86-64.so.2"),
+				{Path: "opt/lib/ld-linux-x86-64.so.2", Type: analyzer.FileTypeRegular},
+			}}},
+		},
+		{
+			name:   "static binary",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{binary("app", "")}}},
+		},
+		{
+			name:   "listed from an eStargz TOC",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{binary("app", "")}, InterpretersUnknown: true}},
+			want:   RuntimeNeeds{LibcUnknown: true},
+		},
+		{
+			name:   "shell command",
+			layers: []analyzer.Layer{{}},
+			cmd:    []string{"/bin/sh", "-c", "app"},
+			want:   RuntimeNeeds{Shell: true},
+		},
+		{
+			name:   "shell script",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{{Path: "entrypoint.sh", Type: analyzer.FileTypeRegular}}}},
+			want:   RuntimeNeeds{Shell: true},
+		},
+		{
+			name:   "added certificate",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{{Path: "usr/local/share/ca-certificates/corp.crt", Type: analyzer.FileTypeRegular}}}},
+			want:   RuntimeNeeds{CABundle: true},
+		},
+		{
+			name: "added certificate with bundle",
+			layers: []analyzer.Layer{{Files: []analyzer.FileEntry{
+				{Path: "usr/local/share/ca-certificates/corp.crt", Type: analyzer.FileTypeRegular},
+				{Path: "etc/ssl/certs/ca-certificates.crt", Type: analyzer.FileTypeRegular},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &analyzer.Image{
+				Layers: []analyzer.Layer{base},
+				Base:   &analyzer.BaseImage{Name: "alpine:3", Layers: 1},
+				Cmd:    tt.cmd,
+			}
+			for i, l := range tt.layers {
+				l.Index = i + 1
+				img.Layers = append(img.Layers, l)
+			}
+
+			if got := InferRuntimeNeeds(img); got != tt.want {
+				t.Errorf("InferRuntimeNeeds = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockers(t *testing.T) {
+	distroless := catalog.Base{Name: "distroless/static"}
+	alpine := catalog.Base{Name: "alpine", Libc: catalog.LibcMusl, Shell: true, CABundle: true}
+
+	tests := []struct {
+		name  string
+		needs RuntimeNeeds
+		base  catalog.Base
+		want  []string
+	}{
+		{name: "no needs", base: distroless},
+		{name: "needs met", needs: RuntimeNeeds{Libc: catalog.LibcMusl, Shell: true, CABundle: true}, base: alpine},
+		{
+			name:  "wrong libc",
+			needs: RuntimeNeeds{Libc: catalog.LibcGlibc},
+			base:  alpine,
+			want:  []string{"image links against glibc but the base provides musl"},
+		},
+		{
+			name:  "no libc",
+			needs: RuntimeNeeds{Libc: catalog.LibcMusl},
+			base:  distroless,
+			want:  []string{"image links against musl but the base provides no libc"},
+		},
+		{
+			name:  "unknown libc",
+			needs: RuntimeNeeds{LibcUnknown: true},
+			base:  alpine,
+			want:  []string{"C library of the image is unknown: layers listed from eStargz TOCs record no ELF interpreters"},
+		},
+		{
+			name:  "shell and certificates",
+			needs: RuntimeNeeds{Shell: true, CABundle: true},
+			base:  distroless,
+			want: []string{
+				"image runs a shell but the base has none",
+				"image adds trusted certificates but the base has no CA bundle",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockers(tt.needs, tt.base); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("blockers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -62,8 +62,24 @@ func AssessImageRisk(img *analyzer.Image) ([]LayerRisk, error) {
 
 	risks := make([]LayerRisk, len(img.Layers))
 	for i, layer := range img.Layers {
-		risks[i] = AssessLayerRisk(layer)
+		risks[i] = assessImageLayer(img, layer)
 	}
 
 	return risks, nil
 }
+
+// assessImageLayer evaluates a layer in the context of its image
+// Base image layers are changed by switching or rebuilding the base rather
+// than by slimming the image, so they are always high risk
+func assessImageLayer(img *analyzer.Image, layer analyzer.Layer) LayerRisk {
+	if !img.IsBaseLayer(layer.Index) {
+		return AssessLayerRisk(layer)
+	}
+
+	return LayerRisk{
+		Index:  layer.Index,
+		Digest: layer.Digest,
+		Level:  RiskHigh,
+		Reason: fmt.Sprintf("base image layer from %s; change the base image instead", baseLabel(img.Base)),
+	}
+}
@@ -0,0 +1,277 @@
+package registries
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Hosts holds the connection settings of registry hosts: trusted CAs,
+// client certificates for mTLS, insecure and plain-HTTP hosts, and the proxy
+//
+// Hosts without settings use the system roots. A Hosts is safe for
+// concurrent use once configured
+type Hosts struct {
+	// Proxy is used for every registry request; nil means the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy *url.URL
+
+	hosts map[string]*Host
+
+	mu         sync.Mutex
+	transports map[*http.Transport]http.RoundTripper
+}
+
+// Host is the connection settings of a single registry host
+type Host struct {
+	// RootCAs are trusted in addition to the system roots; nil means
+	// the system roots only
+	RootCAs *x509.CertPool
+
+	// Certificates are presented to the registry for mTLS
+	Certificates []tls.Certificate
+
+	// Insecure skips certificate verification and allows plain HTTP
+	Insecure bool
+
+	// PlainHTTP allows falling back to plain HTTP; certificates are still
+	// verified when the registry speaks TLS
+	PlainHTTP bool
+}
+
+// NewHosts creates an empty set of host settings
+func NewHosts() *Hosts {
+	return &Hosts{hosts: make(map[string]*Host)}
+}
+
+// Host returns the settings of host, creating them if needed
+// host is a registry host with an optional port, such as "registry.internal:5000"
+func (h *Hosts) Host(host string) *Host {
+	key := hostKey(host)
+
+	s, ok := h.hosts[key]
+	if !ok {
+		s = &Host{}
+		h.hosts[key] = s
+	}
+	return s
+}
+
+// Lookup returns the settings of host, if any
+// A nil Hosts has no settings
+func (h *Hosts) Lookup(host string) (*Host, bool) {
+	if h == nil {
+		return nil, false
+	}
+	s, ok := h.hosts[hostKey(host)]
+	return s, ok
+}
+
+// AllowsHTTP reports whether host may be reached over plain HTTP
+func (h *Hosts) AllowsHTTP(host string) bool {
+	s, ok := h.Lookup(host)
+	return ok && (s.Insecure || s.PlainHTTP)
+}
+
+// hostKey normalizes host as references do, so "docker.io" and
+// "index.docker.io" share settings
+func hostKey(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if reg, err := name.NewRegistry(host); err == nil {
+		return reg.Name()
+	}
+	return host
+}
+
+// AddCA trusts the PEM encoded certificates of pemCerts for the host
+func (s *Host) AddCA(pemCerts []byte) error {
+	if s.RootCAs == nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		s.RootCAs = pool
+	}
+	if !s.RootCAs.AppendCertsFromPEM(pemCerts) {
+		return errors.New("no PEM certificate found")
+	}
+	return nil
+}
+
+// AddClientCert loads a PEM certificate and key pair presented for mTLS
+func (s *Host) AddClientCert(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	s.Certificates = append(s.Certificates, cert)
+	return nil
+}
+
+// LoadCertsDir reads a docker-style certs.d directory
+//
+// Each subdirectory is named after a registry host, optionally with a port,
+// and may hold:
+//
+//	*.crt            CA certificates trusted for the host
+//	*.cert + *.key   a client certificate and its key, for mTLS
+func LoadCertsDir(dir string) (*Hosts, error) {
+	h := NewHosts()
+	if err := h.LoadCertsDir(dir); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// LoadCertsDir adds the settings found in a certs.d directory to h
+func (h *Hosts) LoadCertsDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if err := h.loadHostDir(e.Name(), filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadHostDir loads the certificates of host from dir
+func (h *Hosts) loadHostDir(host, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+
+	present := make(map[string]bool, len(files))
+	for _, f := range files {
+		present[f] = true
+	}
+
+	s := h.Host(host)
+
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+
+		switch filepath.Ext(f) {
+		case ".crt":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := s.AddCA(data); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+		case ".cert":
+			key := strings.TrimSuffix(f, ".cert") + ".key"
+			if !present[key] {
+				return fmt.Errorf("%s: missing key %s", path, key)
+			}
+			if err := s.AddClientCert(path, filepath.Join(dir, key)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+		case ".key":
+			cert := strings.TrimSuffix(f, ".key") + ".cert"
+			if !present[cert] {
+				return fmt.Errorf("%s: missing certificate %s", filepath.Join(dir, f), cert)
+			}
+		}
+	}
+	return nil
+}
+
+// Transport returns a RoundTripper applying the host settings on top of base
+//
+// Requests to configured hosts use a copy of base with their TLS settings;
+// other requests, such as blob redirects to storage, use base with the proxy.
+// The result is memoized per base so connections are pooled across calls
+func (h *Hosts) Transport(base *http.Transport) http.RoundTripper {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if rt, ok := h.transports[base]; ok {
+		return rt
+	}
+
+	proxy := base.Proxy
+	if h.Proxy != nil {
+		proxy = http.ProxyURL(h.Proxy)
+	} else if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	def := base.Clone()
+	def.Proxy = proxy
+
+	rt := &hostsTransport{
+		def:   def,
+		hosts: make(map[string]*http.Transport, len(h.hosts)),
+	}
+
+	for key, s := range h.hosts {
+		t := base.Clone()
+		t.Proxy = proxy
+
+		cfg := &tls.Config{}
+		if t.TLSClientConfig != nil {
+			cfg = t.TLSClientConfig.Clone()
+		}
+		if s.RootCAs != nil {
+			cfg.RootCAs = s.RootCAs
+		}
+		if len(s.Certificates) > 0 {
+			cfg.Certificates = s.Certificates
+		}
+		if s.Insecure {
+			cfg.InsecureSkipVerify = true
+		}
+		t.TLSClientConfig = cfg
+
+		rt.hosts[key] = t
+	}
+
+	if h.transports == nil {
+		h.transports = make(map[*http.Transport]http.RoundTripper)
+	}
+	h.transports[base] = rt
+	return rt
+}
+
+// hostsTransport dispatches requests to the transport of their host
+type hostsTransport struct {
+	def   *http.Transport
+	hosts map[string]*http.Transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *hostsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ht, ok := t.hosts[hostKey(req.URL.Host)]; ok {
+		return ht.RoundTrip(req)
+	}
+	return t.def.RoundTrip(req)
+}
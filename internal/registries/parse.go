@@ -0,0 +1,119 @@
+package registries
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Load reads a configuration file
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse reads a configuration in the containers-registries.conf(5) format
+//
+// Only the [[registry]] and [[registry.mirror]] tables are interpreted;
+// mirrors may also be given inline as mirror = [{ location = "..." }]:
+//
+//	[[registry]]
+//	prefix = "docker.io"
+//	location = "registry-1.docker.io"
+//
+//	[[registry.mirror]]
+//	location = "mirror.internal/dockerhub"
+//	insecure = true
+//	pull-from-mirror = "digest-only"
+//
+// Other tables and top-level keys, such as unqualified-search-registries
+// or [aliases], are accepted and ignored so existing files can be reused.
+// Unknown keys inside registry and mirror tables are errors, since
+// ignoring them could pull from a place the file rules out
+func Parse(r io.Reader) (*Config, error) {
+	var f file
+	md, err := toml.NewDecoder(r).Decode(&f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range md.Undecoded() {
+		if len(k) > 1 && k[0] == "registry" {
+			return nil, fmt.Errorf("unsupported key %q", k.String())
+		}
+	}
+
+	cfg := &Config{Registries: make([]Registry, len(f.Registries))}
+	for i, t := range f.Registries {
+		reg, err := t.registry()
+		if err != nil {
+			return nil, fmt.Errorf("registry %d: %w", i+1, err)
+		}
+		cfg.Registries[i] = reg
+	}
+
+	return cfg, nil
+}
+
+// file is the part of a configuration file Parse interprets
+type file struct {
+	Registries []registryTable `toml:"registry"`
+}
+
+// registryTable is a [[registry]] table
+type registryTable struct {
+	Prefix             string        `toml:"prefix"`
+	Location           string        `toml:"location"`
+	Insecure           bool          `toml:"insecure"`
+	Blocked            bool          `toml:"blocked"`
+	MirrorByDigestOnly bool          `toml:"mirror-by-digest-only"`
+	Mirrors            []mirrorTable `toml:"mirror"`
+}
+
+// mirrorTable is a [[registry.mirror]] table or inline mirror entry
+type mirrorTable struct {
+	Location       string `toml:"location"`
+	Insecure       bool   `toml:"insecure"`
+	PullFromMirror string `toml:"pull-from-mirror"`
+}
+
+func (t registryTable) registry() (Registry, error) {
+	reg := Registry{
+		Prefix:             t.Prefix,
+		Location:           t.Location,
+		Insecure:           t.Insecure,
+		Blocked:            t.Blocked,
+		MirrorByDigestOnly: t.MirrorByDigestOnly,
+	}
+	if reg.Prefix == "" {
+		reg.Prefix = reg.Location
+	}
+	if reg.Prefix == "" {
+		return Registry{}, fmt.Errorf("prefix or location is required")
+	}
+
+	for i, m := range t.Mirrors {
+		switch m.PullFromMirror {
+		case "", PullAll, PullDigestOnly, PullTagOnly:
+		default:
+			return Registry{}, fmt.Errorf("mirror %d: pull-from-mirror: unknown policy %q", i+1, m.PullFromMirror)
+		}
+		if m.Location == "" {
+			return Registry{}, fmt.Errorf("mirror %d: location is required", i+1)
+		}
+		reg.Mirrors = append(reg.Mirrors, Mirror(m))
+	}
+
+	return reg, nil
+}
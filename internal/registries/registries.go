@@ -0,0 +1,200 @@
+// Package registries resolves where image references are pulled from
+//
+// A Config maps upstream registries to mirrors that are tried first, in the
+// spirit of containers-registries.conf(5). Endpoints always ends with the
+// upstream, so a reference never becomes unreachable because of a mirror
+package registries
+
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Mirror pull policies, as in containers-registries.conf(5)
+const (
+	// PullAll uses the mirror for tag and digest references
+	PullAll = "all"
+
+	// PullDigestOnly uses the mirror only for digest references, so tags
+	// always come from the upstream
+	PullDigestOnly = "digest-only"
+
+	// PullTagOnly uses the mirror only for tag references
+	PullTagOnly = "tag-only"
+)
+
+// Config lists the upstream registries that have mirrors
+type Config struct {
+	Registries []Registry
+}
+
+// Registry is an upstream registry or repository namespace
+type Registry struct {
+	// Prefix selects the references this entry applies to: a registry host
+	// or a repository prefix such as "docker.io/library"
+	Prefix string
+
+	// Location replaces Prefix when pulling from the upstream itself;
+	// empty means Prefix
+	Location string
+
+	// Insecure allows plain HTTP for Location
+	Insecure bool
+
+	// Blocked refuses to pull the references this entry applies to, from
+	// the upstream and the mirrors alike
+	Blocked bool
+
+	// MirrorByDigestOnly uses the mirrors for digest references only,
+	// whatever their PullFromMirror
+	MirrorByDigestOnly bool
+
+	// Mirrors are tried in order before the upstream
+	Mirrors []Mirror
+}
+
+// Mirror is an alternative location serving the same content
+type Mirror struct {
+	// Location is a host with an optional repository prefix, for example
+	// "mirror.internal:5000/dockerhub"
+	Location string
+
+	// Insecure allows plain HTTP for the mirror; certificate checks are
+	// relaxed per host with Hosts
+	Insecure bool
+
+	// PullFromMirror is PullAll, PullDigestOnly or PullTagOnly;
+	// empty means PullAll
+	PullFromMirror string
+}
+
+// Endpoint is a location a reference can be pulled from
+type Endpoint struct {
+	// Reference is the reference rewritten for this endpoint
+	Reference name.Reference
+
+	// Registry is the host serving the endpoint
+	Registry string
+
+	// Mirror reports that the endpoint is a mirror, not the upstream
+	Mirror bool
+}
+
+// Endpoints returns where ref should be pulled from: its mirrors in order,
+// then the upstream. The upstream is always last, so a nil or empty
+// Config yields ref itself. Mirrors whose location cannot form a valid
+// reference are skipped
+func (c *Config) Endpoints(ref name.Reference) []Endpoint {
+	upstream := Endpoint{Reference: ref, Registry: ref.Context().RegistryStr()}
+
+	reg, prefix := c.match(ref)
+	if reg == nil {
+		return []Endpoint{upstream}
+	}
+
+	_, byDigest := ref.(name.Digest)
+
+	var out []Endpoint
+	for _, m := range reg.Mirrors {
+		if reg.MirrorByDigestOnly && !byDigest {
+			break
+		}
+
+		switch m.PullFromMirror {
+		case PullDigestOnly:
+			if !byDigest {
+				continue
+			}
+		case PullTagOnly:
+			if byDigest {
+				continue
+			}
+		}
+
+		if r, err := rewrite(ref, prefix, m.Location, m.Insecure); err == nil {
+			out = append(out, Endpoint{Reference: r, Registry: r.Context().RegistryStr(), Mirror: true})
+		}
+	}
+
+	if reg.Location != "" {
+		if r, err := rewrite(ref, prefix, reg.Location, reg.Insecure); err == nil {
+			upstream = Endpoint{Reference: r, Registry: r.Context().RegistryStr()}
+		}
+	}
+
+	return append(out, upstream)
+}
+
+// Blocked reports whether the entry matching ref forbids pulling it
+func (c *Config) Blocked(ref name.Reference) bool {
+	reg, _ := c.match(ref)
+	return reg != nil && reg.Blocked
+}
+
+// match returns the entry with the longest prefix matching ref, and that
+// prefix in normalized form
+func (c *Config) match(ref name.Reference) (*Registry, string) {
+	if c == nil {
+		return nil, ""
+	}
+
+	repo := ref.Context().Name()
+
+	var (
+		best       *Registry
+		bestPrefix string
+	)
+	for i := range c.Registries {
+		p := normalizePrefix(c.Registries[i].Prefix)
+		if p == "" || (repo != p && !strings.HasPrefix(repo, p+"/")) {
+			continue
+		}
+		if len(p) > len(bestPrefix) {
+			best, bestPrefix = &c.Registries[i], p
+		}
+	}
+
+	return best, bestPrefix
+}
+
+// normalizePrefix expands a prefix as references are, so "docker.io"
+// matches "index.docker.io/library/alpine"
+func normalizePrefix(prefix string) string {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+
+	// Only the host is normalized: repositories would get the implicit
+	// "library/" of Docker Hub, which "docker.io/library" already names
+	host, path, _ := strings.Cut(prefix, "/")
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return ""
+	}
+	if path == "" {
+		return reg.Name()
+	}
+
+	if _, err := name.NewRepository(reg.Name() + "/" + path); err != nil {
+		return ""
+	}
+	return reg.Name() + "/" + path
+}
+
+// rewrite moves ref from under prefix to under location, keeping its tag or digest
+func rewrite(ref name.Reference, prefix, location string, insecure bool) (name.Reference, error) {
+	target := strings.TrimSuffix(location, "/") + strings.TrimPrefix(ref.Context().Name(), prefix)
+
+	sep := ":"
+	if _, ok := ref.(name.Digest); ok {
+		sep = "@"
+	}
+
+	opts := []name.Option{name.StrictValidation}
+	if insecure {
+		opts = append(opts, name.Insecure)
+	}
+	return name.ParseReference(target+sep+ref.Identifier(), opts...)
+}
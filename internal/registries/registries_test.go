@@ -0,0 +1,245 @@
+package registries
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const testDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    string
+		want    []Registry
+		wantErr string
+	}{
+		{
+			name: "mirror tables",
+			conf: `
+unqualified-search-registries = ["docker.io"]
+
+[[registry]]
+prefix = "docker.io"
+location = "registry-1.docker.io"
+mirror-by-digest-only = true
+
+[[registry.mirror]]
+location = "mirror.internal/dockerhub"
+insecure = true
+
+[[registry.mirror]]
+location = "backup.internal/dockerhub"
+pull-from-mirror = "digest-only"
+
+[aliases]
+"alpine" = "docker.io/library/alpine"
+`,
+			want: []Registry{{
+				Prefix:             "docker.io",
+				Location:           "registry-1.docker.io",
+				MirrorByDigestOnly: true,
+				Mirrors: []Mirror{
+					{Location: "mirror.internal/dockerhub", Insecure: true},
+					{Location: "backup.internal/dockerhub", PullFromMirror: PullDigestOnly},
+				},
+			}},
+		},
+		{
+			name: "inline mirrors and blocked registries",
+			conf: `
+[[registry]]
+location = "quay.io"
+mirror = [{ location = "mirror.internal/quay", pull-from-mirror = "tag-only" }]
+
+[[registry]]
+prefix = "evil.example.com"
+blocked = true
+`,
+			want: []Registry{
+				{Prefix: "quay.io", Location: "quay.io", Mirrors: []Mirror{{Location: "mirror.internal/quay", PullFromMirror: PullTagOnly}}},
+				{Prefix: "evil.example.com", Blocked: true},
+			},
+		},
+		{name: "no registries", conf: `unqualified-search-registries = ["docker.io"]`, want: []Registry{}},
+		{
+			name:    "unknown registry key",
+			conf:    "[[registry]]\nprefix = \"docker.io\"\nblock = true\n",
+			wantErr: `unsupported key "registry.block"`,
+		},
+		{
+			name:    "unknown mirror key",
+			conf:    "[[registry]]\nprefix = \"docker.io\"\n[[registry.mirror]]\nlocation = \"m.internal\"\npull-from = \"all\"\n",
+			wantErr: `unsupported key "registry.mirror.pull-from"`,
+		},
+		{
+			name:    "unknown pull policy",
+			conf:    "[[registry]]\nprefix = \"docker.io\"\n[[registry.mirror]]\nlocation = \"m.internal\"\npull-from-mirror = \"sometimes\"\n",
+			wantErr: `registry 1: mirror 1: pull-from-mirror: unknown policy "sometimes"`,
+		},
+		{
+			name:    "mirror without location",
+			conf:    "[[registry]]\nprefix = \"docker.io\"\n[[registry.mirror]]\ninsecure = true\n",
+			wantErr: "registry 1: mirror 1: location is required",
+		},
+		{
+			name:    "registry without prefix",
+			conf:    "[[registry]]\nblocked = true\n",
+			wantErr: "registry 1: prefix or location is required",
+		},
+		{name: "invalid TOML", conf: "[[registry]\n", wantErr: "toml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(strings.NewReader(tt.conf))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Registries, tt.want) {
+				t.Errorf("registries = %+v, want %+v", cfg.Registries, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	cfg := &Config{Registries: []Registry{
+		{
+			Prefix: "docker.io",
+			Mirrors: []Mirror{
+				{Location: "mirror.internal/dockerhub"},
+				{Location: "digests.internal/dockerhub", PullFromMirror: PullDigestOnly},
+				{Location: "tags.internal/dockerhub", PullFromMirror: PullTagOnly},
+			},
+		},
+		{
+			Prefix:   "docker.io/library",
+			Location: "library.internal/library",
+			Mirrors:  []Mirror{{Location: "mirror.internal/library"}},
+		},
+		{
+			Prefix:             "quay.io",
+			MirrorByDigestOnly: true,
+			Mirrors:            []Mirror{{Location: "mirror.internal/quay"}},
+		},
+		{Prefix: "evil.example.com", Blocked: true},
+	}}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    []string
+		blocked bool
+	}{
+		{
+			name: "tag skips digest-only mirrors",
+			ref:  "docker.io/acme/app:1",
+			want: []string{"mirror.internal/dockerhub/acme/app:1", "tags.internal/dockerhub/acme/app:1", "index.docker.io/acme/app:1"},
+		},
+		{
+			name: "digest skips tag-only mirrors",
+			ref:  "docker.io/acme/app@" + testDigest,
+			want: []string{"mirror.internal/dockerhub/acme/app@" + testDigest, "digests.internal/dockerhub/acme/app@" + testDigest, "index.docker.io/acme/app@" + testDigest},
+		},
+		{
+			name: "longest prefix with upstream location",
+			ref:  "alpine:3",
+			want: []string{"mirror.internal/library/alpine:3", "library.internal/library/alpine:3"},
+		},
+		{
+			name: "mirror by digest only skips tags",
+			ref:  "quay.io/acme/app:1",
+			want: []string{"quay.io/acme/app:1"},
+		},
+		{
+			name: "mirror by digest only serves digests",
+			ref:  "quay.io/acme/app@" + testDigest,
+			want: []string{"mirror.internal/quay/acme/app@" + testDigest, "quay.io/acme/app@" + testDigest},
+		},
+		{
+			name: "prefix matches whole path segments",
+			ref:  "quay.io.example.com/app:1",
+			want: []string{"quay.io.example.com/app:1"},
+		},
+		{
+			name:    "blocked",
+			ref:     "evil.example.com/app:1",
+			want:    []string{"evil.example.com/app:1"},
+			blocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			eps := cfg.Endpoints(ref)
+			for i, ep := range eps {
+				got = append(got, ep.Reference.Name())
+				if ep.Mirror != (i < len(eps)-1) {
+					t.Errorf("endpoint %s: Mirror = %t", ep.Reference, ep.Mirror)
+				}
+				if ep.Registry != ep.Reference.Context().RegistryStr() {
+					t.Errorf("endpoint %s: Registry = %s", ep.Reference, ep.Registry)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Endpoints = %v, want %v", got, tt.want)
+			}
+			if b := cfg.Blocked(ref); b != tt.blocked {
+				t.Errorf("Blocked = %t, want %t", b, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestNilConfig(t *testing.T) {
+	ref, err := name.ParseReference("example.com/app:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg *Config
+	if eps := cfg.Endpoints(ref); len(eps) != 1 || eps[0].Reference != ref || eps[0].Mirror {
+		t.Errorf("Endpoints = %+v, want the reference itself", eps)
+	}
+	if cfg.Blocked(ref) {
+		t.Error("nil Config blocks references")
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "docker.io", want: "index.docker.io"},
+		{prefix: "docker.io/library", want: "index.docker.io/library"},
+		{prefix: "docker.io/library/alpine/", want: "index.docker.io/library/alpine"},
+		{prefix: "registry.internal:5000/team", want: "registry.internal:5000/team"},
+		{prefix: " quay.io ", want: "quay.io"},
+		{prefix: "", want: ""},
+		{prefix: "quay.io/Upper", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizePrefix(tt.prefix); got != tt.want {
+			t.Errorf("normalizePrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
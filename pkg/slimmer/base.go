@@ -0,0 +1,103 @@
+package slimmer
+
+import (
+	"io"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/catalog"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+)
+
+type (
+	// BaseImage identifies the base image an image was built from
+	BaseImage = analyser.BaseImage
+
+	// BaseCatalog lists base images known locally
+	BaseCatalog = catalog.Catalog
+
+	// CatalogBase is a base image listed in a BaseCatalog
+	CatalogBase = catalog.Base
+
+	// RuntimeNeeds is what an image is expected to need from its base
+	RuntimeNeeds = digest.RuntimeNeeds
+
+	// BaseRecommendation is a smaller base an image could be rebuilt on
+	BaseRecommendation = digest.BaseRecommendation
+)
+
+// C libraries for CatalogBase.Libc
+const (
+	LibcGlibc = catalog.LibcGlibc
+	LibcMusl  = catalog.LibcMusl
+)
+
+// How a base image was detected, for BaseImage.Source
+const (
+	BaseFromAnnotation = analyser.BaseFromAnnotation
+	BaseFromCatalog    = analyser.BaseFromCatalog
+)
+
+// OCI annotations naming the base image of an image
+const (
+	AnnotationBaseName   = analyser.AnnotationBaseName
+	AnnotationBaseDigest = analyser.AnnotationBaseDigest
+)
+
+// LoadBaseCatalog reads a base image catalog file
+func LoadBaseCatalog(path string) (*BaseCatalog, error) {
+	return catalog.Load(path)
+}
+
+// ParseBaseCatalog parses a base image catalog in its JSON form
+func ParseBaseCatalog(r io.Reader) (*BaseCatalog, error) {
+	return catalog.Parse(r)
+}
+
+// DetectBase attributes the leading layers of img to a base image
+//
+// Images record the base named by their org.opencontainers.image.base.*
+// annotations when loaded; c completes it, or finds a base by matching
+// diff IDs when there are no annotations. It returns nil when no base is known
+func DetectBase(img *Image, c *BaseCatalog) *BaseImage {
+	return analyser.DetectBase(img, c)
+}
+
+// InferRuntimeNeeds derives what img needs from its base at runtime: its
+// C library, a shell and a CA bundle
+func InferRuntimeNeeds(img *Image) RuntimeNeeds {
+	return digest.InferRuntimeNeeds(img)
+}
+
+// RecommendBases lists the bases of c smaller than the current base of img,
+// with the estimated size change and the needs each one does not meet
+// The base of img must be known; see DetectBase
+func RecommendBases(img *Image, c *BaseCatalog) ([]BaseRecommendation, error) {
+	return digest.RecommendBases(img, c)
+}
+
+// WithBaseCatalog attributes leading layers to the bases of c when the image
+// annotations do not already do so. Plans and reports then separate base
+// layers from the layers of the image itself, and plans recommend the
+// smaller bases of c
+func WithBaseCatalog(c *BaseCatalog) Option {
+	return func(cfg *config) {
+		cfg.catalog = c
+	}
+}
+
+// withBase returns img with the base detected from c, copying it when the
+// base changes so cached and shared images are left untouched
+func withBase(img *Image, c *BaseCatalog) *Image {
+	if c == nil || img == nil || img.MetadataOnly {
+		return img
+	}
+
+	base := analyser.DetectBase(img, c)
+	if base == img.Base {
+		return img
+	}
+
+	out := *img
+	out.Base = base
+	return &out
+}
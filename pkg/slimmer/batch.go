@@ -0,0 +1,309 @@
+package slimmer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+// BatchItem is the outcome for a single reference of a batch
+// Exactly one of Result and Err is set
+type BatchItem struct {
+	Reference string
+
+	// Digest is the resolved digest used for deduplication, if known
+	Digest string
+
+	Result *Result
+	Err    *AnalyzerError
+
+	// Deduplicated reports that Result was shared with another reference
+	// resolving to the same digest instead of being analyzed again
+	Deduplicated bool
+}
+
+// BatchMetrics aggregates execution metrics over a batch
+type BatchMetrics struct {
+	References   int
+	Analyzed     int
+	Deduplicated int
+	Succeeded    int
+	Failed       int
+
+	FetchAttempts int
+	ErrorsByCode  map[ErrorCode]int
+	Duration      time.Duration
+}
+
+// BatchResult holds one item per input reference, in input order
+type BatchResult struct {
+	Items   []BatchItem
+	Metrics BatchMetrics
+}
+
+// SlimBatch analyzes many references with bounded concurrency
+//
+// References are first resolved to digests with cheap HEAD requests;
+// references sharing a digest are analyzed once and share the Result.
+// A failing reference never aborts the batch: its item carries the error
+//
+// Concurrency is controlled by WithConcurrency and WithRegistryConcurrency;
+// opts override the engine options for this call only. Unless one is
+// configured, the batch shares a CircuitBreaker across its references
+func (e *Engine) SlimBatch(ctx context.Context, refs []string, opts ...Option) *BatchResult {
+	start := time.Now()
+	cfg := e.config(opts...)
+
+	// One unhealthy registry must not spend the budget of the whole batch
+	if cfg.breaker == nil {
+		opts = append(opts[:len(opts):len(opts)], WithCircuitBreaker(NewCircuitBreaker()))
+		cfg = e.config(opts...)
+	}
+
+	pool := newBatchPool(cfg)
+
+	items := make([]BatchItem, len(refs))
+	for i, ref := range refs {
+		items[i].Reference = ref
+	}
+
+	// ---- RESOLVE PHASE ----
+
+	digests := make(map[string]string)
+	var mu sync.Mutex
+
+	pool.run(ctx, uniqueRefs(refs), analyser.Registry, func(ctx context.Context, ref string) {
+		d, err := analyser.Resolve(ctx, ref, cfg.load...)
+		if err != nil {
+			// Unresolved references are analyzed individually, which
+			// reports the error with full context
+			return
+		}
+		mu.Lock()
+		digests[ref] = d
+		mu.Unlock()
+	})
+
+	// ---- GROUP BY DIGEST ----
+
+	groups := make(map[string][]int)
+	var order []string
+	for i := range items {
+		key := "ref:" + items[i].Reference
+		if d, ok := digests[items[i].Reference]; ok {
+			items[i].Digest = d
+			key = d
+		}
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	// ---- ANALYZE PHASE ----
+
+	results := make(map[string]*Result)
+	errs := make(map[string]*AnalyzerError)
+
+	registryOf := func(key string) string {
+		return analyser.Registry(items[groups[key][0]].Reference)
+	}
+
+	pool.run(ctx, order, registryOf, func(ctx context.Context, key string) {
+		lead := items[groups[key][0]]
+
+		// Pin to the resolved digest so the analysis matches what was deduplicated
+		target := lead.Reference
+		if lead.Digest != "" {
+			if pinned, err := analyser.PinDigest(lead.Reference, lead.Digest); err == nil {
+				target = pinned
+			}
+		}
+
+		res, err := e.Slim(ctx, target, opts...)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[key] = batchError(lead.Reference, err)
+			return
+		}
+		results[key] = res
+	})
+
+	// ---- ASSEMBLE ----
+
+	m := BatchMetrics{
+		References:   len(refs),
+		Analyzed:     len(order),
+		ErrorsByCode: make(map[ErrorCode]int),
+	}
+
+	for _, key := range order {
+		for n, i := range groups[key] {
+			item := &items[i]
+			item.Deduplicated = n > 0
+
+			if err, failed := errs[key]; failed {
+				item.Err = err
+				m.Failed++
+				m.ErrorsByCode[err.Code()]++
+				continue
+			}
+
+			if res, ok := results[key]; ok {
+				item.Result = withReference(res, item.Reference)
+				m.Succeeded++
+				if item.Deduplicated {
+					m.Deduplicated++
+				} else {
+					m.FetchAttempts += res.Metrics.FetchAttempts
+				}
+				continue
+			}
+
+			// Never scheduled because the context ended first
+			item.Err = batchError(item.Reference, ctx.Err())
+			m.Failed++
+			m.ErrorsByCode[item.Err.Code()]++
+		}
+	}
+
+	m.Duration = time.Since(start)
+
+	return &BatchResult{
+		Items:   items,
+		Metrics: m,
+	}
+}
+
+// batchPool bounds global and per-registry concurrency
+type batchPool struct {
+	global      chan struct{}
+	perRegistry int
+
+	mu         sync.Mutex
+	registries map[string]chan struct{}
+}
+
+func newBatchPool(cfg *config) *batchPool {
+	return &batchPool{
+		global:      make(chan struct{}, cfg.concurrency),
+		perRegistry: cfg.registryConcurrency,
+		registries:  make(map[string]chan struct{}),
+	}
+}
+
+// run executes fn for every key and waits for completion
+// Keys not yet started when ctx ends are skipped
+func (p *batchPool) run(ctx context.Context, keys []string, registryOf func(string) string, fn func(context.Context, string)) {
+	var wg sync.WaitGroup
+
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			// Registry slot first, so waiting for a busy registry never
+			// holds a global slot that another registry could use
+			release, ok := p.acquire(ctx, p.registrySlots(registryOf(key)))
+			if !ok {
+				return
+			}
+			defer release()
+
+			releaseGlobal, ok := p.acquire(ctx, p.global)
+			if !ok {
+				return
+			}
+			defer releaseGlobal()
+
+			fn(ctx, key)
+		}(key)
+	}
+
+	wg.Wait()
+}
+
+// registrySlots returns the semaphore of registry, or nil if unlimited
+func (p *batchPool) registrySlots(registry string) chan struct{} {
+	if p.perRegistry <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, ok := p.registries[registry]
+	if !ok {
+		slots = make(chan struct{}, p.perRegistry)
+		p.registries[registry] = slots
+	}
+	return slots
+}
+
+// acquire takes a slot from sem; a nil sem is unlimited
+func (p *batchPool) acquire(ctx context.Context, sem chan struct{}) (func(), bool) {
+	if sem == nil {
+		return func() {}, true
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// uniqueRefs returns refs without duplicates, preserving order
+func uniqueRefs(refs []string) []string {
+	seen := make(map[string]struct{}, len(refs))
+	out := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		out = append(out, r)
+	}
+	return out
+}
+
+// batchError normalizes any failure into an AnalyzerError
+func batchError(ref string, err error) *AnalyzerError {
+	if ae, ok := analyser.AsAnalyzerError(err); ok {
+		return ae
+	}
+	if mapped, ok := analyser.AsAnalyzerError(analyser.MapRegistryError("batch", ref, err)); ok && mapped.Code() != CodeUnknown {
+		return mapped
+	}
+	return analyser.NewError(CodeBuildFailed, "batch", ref, "analysis failed", err)
+}
+
+// withReference returns a copy of r presented under ref
+// Layer data is shared; only the reference fields differ
+func withReference(r *Result, ref string) *Result {
+	out := *r
+
+	if r.Image != nil {
+		img := *r.Image
+		img.Reference = ref
+		out.Image = &img
+	}
+	if r.Deterministic != nil {
+		det := *r.Deterministic
+		det.Reference = ref
+		out.Deterministic = &det
+	}
+	if r.Plan != nil {
+		plan := *r.Plan
+		plan.Reference = ref
+		plan.Layers = append([]LayerPlan(nil), r.Plan.Layers...)
+		out.Plan = &plan
+	}
+
+	return &out
+}
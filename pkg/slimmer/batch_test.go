@@ -0,0 +1,151 @@
+package slimmer
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// testRegistry starts an in-memory registry and returns its host
+func testRegistry(t *testing.T) string {
+	t.Helper()
+
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// pushImage writes img to ref
+func pushImage(t *testing.T, ref string, img v1.Image) {
+	t.Helper()
+
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// randomImage returns an image with one random layer
+func randomImage(t *testing.T) v1.Image {
+	t.Helper()
+
+	img, err := random.Image(512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestSlimBatchDeduplicates(t *testing.T) {
+	host := testRegistry(t)
+
+	app := randomImage(t)
+	pushImage(t, host+"/app:1", app)
+	pushImage(t, host+"/app:latest", app)
+	pushImage(t, host+"/mirror/app:1", app)
+	pushImage(t, host+"/other:1", randomImage(t))
+
+	type want struct {
+		deduplicated bool
+		failed       bool
+
+		// sameAs is the index of an earlier item with the same digest, or -1
+		sameAs int
+	}
+
+	tests := []struct {
+		name    string
+		refs    []string
+		items   []want
+		metrics BatchMetrics
+	}{
+		{
+			name:    "distinct images",
+			refs:    []string{host + "/app:1", host + "/other:1"},
+			items:   []want{{sameAs: -1}, {sameAs: -1}},
+			metrics: BatchMetrics{References: 2, Analyzed: 2, Succeeded: 2},
+		},
+		{
+			name:    "tags of one image",
+			refs:    []string{host + "/app:1", host + "/app:latest", host + "/mirror/app:1"},
+			items:   []want{{sameAs: -1}, {deduplicated: true, sameAs: 0}, {deduplicated: true, sameAs: 0}},
+			metrics: BatchMetrics{References: 3, Analyzed: 1, Deduplicated: 2, Succeeded: 3},
+		},
+		{
+			name:    "repeated reference",
+			refs:    []string{host + "/app:1", host + "/other:1", host + "/app:1"},
+			items:   []want{{sameAs: -1}, {sameAs: -1}, {deduplicated: true, sameAs: 0}},
+			metrics: BatchMetrics{References: 3, Analyzed: 2, Deduplicated: 1, Succeeded: 3},
+		},
+		{
+			name:    "unresolved reference",
+			refs:    []string{host + "/missing:1", host + "/app:1", host + "/missing:1"},
+			items:   []want{{failed: true, sameAs: -1}, {sameAs: -1}, {deduplicated: true, failed: true, sameAs: -1}},
+			metrics: BatchMetrics{References: 3, Analyzed: 2, Succeeded: 1, Failed: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New(WithRetries(0), WithConcurrency(2)).SlimBatch(context.Background(), tt.refs)
+
+			for i, w := range tt.items {
+				item := res.Items[i]
+				if item.Reference != tt.refs[i] {
+					t.Fatalf("item %d is %s, want %s", i, item.Reference, tt.refs[i])
+				}
+				if item.Deduplicated != w.deduplicated {
+					t.Errorf("item %d: Deduplicated = %t, want %t", i, item.Deduplicated, w.deduplicated)
+				}
+				if w.failed {
+					if item.Err == nil || item.Result != nil {
+						t.Errorf("item %d: Err = %v, Result = %v, want an error only", i, item.Err, item.Result)
+					}
+					continue
+				}
+				if item.Err != nil || item.Result == nil {
+					t.Fatalf("item %d: Err = %v, want a result", i, item.Err)
+				}
+				if item.Result.Image.Reference != item.Reference {
+					t.Errorf("item %d: result presented as %s", i, item.Result.Image.Reference)
+				}
+				if w.sameAs >= 0 {
+					lead := res.Items[w.sameAs]
+					if item.Digest == "" || item.Digest != lead.Digest {
+						t.Errorf("item %d: digest %q, want %q of item %d", i, item.Digest, lead.Digest, w.sameAs)
+					}
+					if item.Result.Image.Digest != lead.Result.Image.Digest {
+						t.Errorf("item %d: analyzed %s, want %s", i, item.Result.Image.Digest, lead.Result.Image.Digest)
+					}
+				}
+			}
+
+			// Timing and attempts vary; errors are checked per item
+			m := res.Metrics
+			m.FetchAttempts, m.ErrorsByCode, m.Duration = 0, nil, 0
+			if !reflect.DeepEqual(m, tt.metrics) {
+				t.Errorf("metrics = %+v, want %+v", m, tt.metrics)
+			}
+		})
+	}
+}
+
+func TestUniqueRefs(t *testing.T) {
+	got := uniqueRefs([]string{"b", "a", "b", "c", "a"})
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueRefs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package slimmer
+
+import (
+	"time"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+type (
+	// CircuitBreaker tracks registry health and retry budgets across calls
+	// It is safe for concurrent use and meant to be shared
+	CircuitBreaker = analyser.CircuitBreaker
+
+	// BreakerOption configures a CircuitBreaker
+	BreakerOption = analyser.BreakerOption
+)
+
+// NewCircuitBreaker creates a CircuitBreaker with every circuit closed
+func NewCircuitBreaker(opts ...BreakerOption) *CircuitBreaker {
+	return analyser.NewCircuitBreaker(opts...)
+}
+
+// WithFailureThreshold sets the consecutive temporary failures that open
+// a circuit; defaults to 5
+func WithFailureThreshold(n int) BreakerOption {
+	return analyser.WithFailureThreshold(n)
+}
+
+// WithCooldown sets how long an open circuit fails fast before probing;
+// defaults to 30s
+func WithCooldown(d time.Duration) BreakerOption {
+	return analyser.WithCooldown(d)
+}
+
+// WithRetryBudget sets the retry tokens of each registry; defaults to 10
+func WithRetryBudget(tokens int) BreakerOption {
+	return analyser.WithRetryBudget(tokens)
+}
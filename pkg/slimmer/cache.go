@@ -0,0 +1,77 @@
+package slimmer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pnkcaht/image-slimmer-core/internal/cache"
+)
+
+type (
+	// Cache stores immutable, content-addressed entries across runs
+	// Implementations must be safe for concurrent use
+	Cache = cache.Cache
+
+	// FSCache is a size-bounded Cache backed by a directory, with LRU
+	// eviction. A directory may be shared by several processes
+	FSCache = cache.FS
+)
+
+// ErrCacheMiss is returned by Cache.Get when no entry exists for a key
+var ErrCacheMiss = cache.ErrMiss
+
+// NewFSCache creates a cache rooted at dir
+// maxBytes bounds the total size of entries; zero means unbounded
+func NewFSCache(dir string, maxBytes int64) (*FSCache, error) {
+	return cache.NewFS(dir, maxBytes)
+}
+
+// resultKey returns the cache key of the result for the resolved digest d
+//
+// The key is derived from the digest the registry currently reports for the
+// reference, so a moved tag misses. Unresolved references are not cached
+func resultKey(d string, cfg *config) (string, bool) {
+	if cfg.cache == nil || d == "" {
+		return "", false
+	}
+
+	key := SchemaVersion + "/" + d + "/" + requestedPlatform(cfg) + "/metadata-only=" + strconv.FormatBool(cfg.metadataOnly)
+	if cfg.compression {
+		key += "/compression=true"
+	}
+	return cache.Key(cache.KindResult, key), true
+}
+
+// loadResult returns the cached image for key, presented under ref
+// Any cache failure is treated as a miss
+func loadResult(store Cache, key, ref string) (*Image, bool) {
+	rc, err := store.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	defer rc.Close()
+
+	var doc ResultDocument
+	if err := json.NewDecoder(rc).Decode(&doc); err != nil {
+		return nil, false
+	}
+
+	r, err := doc.Result()
+	if err != nil || r.Image == nil {
+		return nil, false
+	}
+
+	r.Image.Reference = ref
+	return r.Image, true
+}
+
+// storeResult records the image of r under key
+// Planning stages are cheap and rerun on a hit, so only the image is kept
+func storeResult(store Cache, key string, r *Result) {
+	data, err := json.Marshal((&Result{Image: r.Image, Metrics: r.Metrics}).Document(WithFiles(), WithTimings()))
+	if err != nil {
+		return
+	}
+	_ = store.Put(key, bytes.NewReader(data))
+}
@@ -0,0 +1,33 @@
+package slimmer
+
+import (
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+)
+
+type (
+	// CompressionEstimate is the estimated compressed size of a layer in
+	// another format, recorded with WithCompressionEstimates
+	CompressionEstimate = analyser.CompressionEstimate
+
+	// CompressionRecommendation is a layer media type change with its
+	// estimated transfer savings
+	CompressionRecommendation = digest.CompressionRecommendation
+)
+
+// Compression formats, for CompressionEstimate.Format and Layer.Compression
+const (
+	CompressionGzip    = analyser.CompressionGzip
+	CompressionZstd    = analyser.CompressionZstd
+	CompressionEstargz = analyser.CompressionEstargz
+	CompressionNone    = analyser.CompressionNone
+)
+
+// RecommendCompression recommends recompressing the gzip layers of img with
+// zstd, skipping base layers and layers mostly made of compressed files such
+// as images, archives and jars. img must be loaded with
+// WithCompressionEstimates; it returns nil otherwise or when nothing would
+// be saved
+func RecommendCompression(img *Image) *CompressionRecommendation {
+	return digest.RecommendCompression(img)
+}
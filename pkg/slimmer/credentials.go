@@ -0,0 +1,59 @@
+package slimmer
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/credentials"
+)
+
+type (
+	// CredentialSource is a named source of registry credentials
+	CredentialSource = credentials.Source
+
+	// CredentialSourceError reports a credential source that failed; it
+	// names the source without carrying the secret
+	CredentialSourceError = credentials.SourceError
+)
+
+// DefaultCredentials is the default keychain: the docker config of the
+// user, or the podman auth file
+func DefaultCredentials() CredentialSource {
+	return credentials.Default()
+}
+
+// KeychainCredentials names an arbitrary keychain as a CredentialSource
+func KeychainCredentials(name string, kc authn.Keychain) CredentialSource {
+	return credentials.Keychain(name, kc)
+}
+
+// StaticCredentials returns a fixed username and password for the registry host
+func StaticCredentials(host, username, password string) CredentialSource {
+	return credentials.Static(host, username, password)
+}
+
+// DockerConfigCredentials reads the docker config.json at path, honoring
+// its credsStore and credHelpers entries
+func DockerConfigCredentials(path string) CredentialSource {
+	return credentials.DockerConfig(path)
+}
+
+// TokenFileCredentials sends the bearer token stored at path to the
+// registry host, or to every registry when host is empty. The file is
+// re-read on every resolution so rotated tokens are picked up
+func TokenFileCredentials(host, path string) CredentialSource {
+	return credentials.TokenFile(host, path)
+}
+
+// HelperCredentials runs the docker credential helper
+// docker-credential-<helper> for the registry host, or for every registry
+// when host is empty
+func HelperCredentials(host, helper string) CredentialSource {
+	return credentials.Helper(host, helper)
+}
+
+// WithCredentials resolves registry credentials from sources, tried in
+// order until one has credentials for the registry; it replaces the
+// keychain. Authentication errors name the source that was used
+func WithCredentials(sources ...CredentialSource) Option {
+	return loadOption(analyser.WithCredentials(sources...))
+}
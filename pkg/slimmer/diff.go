@@ -0,0 +1,578 @@
+package slimmer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+)
+
+const (
+	// diffDirDepth is the number of path components directories are grouped by
+	diffDirDepth = 2
+
+	// diffTopFiles bounds how many files the diff summary lists
+	diffTopFiles = 10
+)
+
+// Change classifies how an entry differs between two images
+type Change string
+
+const (
+	ChangeAdded    Change = "added"
+	ChangeRemoved  Change = "removed"
+	ChangeModified Change = "modified"
+)
+
+// Diff is the difference between two images, from the older to the newer
+//
+// Layers are matched by uncompressed content, so recompressed layers count
+// as shared. Files are compared on the merged filesystem of each image by
+// type, size, mode, link target and content digest; results recorded
+// before files had digests are compared without them
+type Diff struct {
+	From DiffImage `json:"from"`
+	To   DiffImage `json:"to"`
+
+	// CompressedDelta and SizeDelta are the changes in pull size and size on disk
+	CompressedDelta int64 `json:"compressedDelta"`
+	SizeDelta       int64 `json:"sizeDelta"`
+
+	SharedLayers  []DiffLayer `json:"sharedLayers"`
+	AddedLayers   []DiffLayer `json:"addedLayers"`
+	RemovedLayers []DiffLayer `json:"removedLayers"`
+
+	// Files lists changed entries sorted by path
+	Files []FileChange `json:"files"`
+
+	// Directories groups file changes by their leading directories, largest
+	// absolute size change first
+	Directories []DirectoryChange `json:"directories"`
+
+	// Owners groups file changes by the OS package that installed them,
+	// largest absolute size change first
+	Owners []OwnerChange `json:"owners"`
+
+	// Packages lists added, removed and re-versioned OS packages sorted by
+	// manager and name
+	Packages []PackageChange `json:"packages"`
+}
+
+// DiffImage identifies one side of a Diff
+type DiffImage struct {
+	Reference        string `json:"reference"`
+	Digest           string `json:"digest"`
+	CompressedSize   int64  `json:"compressedSize"`
+	UncompressedSize int64  `json:"uncompressedSize"`
+}
+
+// DiffLayer identifies a layer in a Diff; Index is its position in the
+// image it belongs to, the newer one for shared layers
+type DiffLayer struct {
+	Index            int    `json:"index"`
+	Digest           string `json:"digest"`
+	DiffID           string `json:"diffId"`
+	CompressedSize   int64  `json:"compressedSize"`
+	UncompressedSize int64  `json:"uncompressedSize"`
+}
+
+// FileChange is a filesystem entry that differs between two images
+type FileChange struct {
+	Path       string   `json:"path"`
+	Change     Change   `json:"change"`
+	Type       FileType `json:"type"`
+	SizeBefore int64    `json:"sizeBefore"`
+	SizeAfter  int64    `json:"sizeAfter"`
+	SizeDelta  int64    `json:"sizeDelta"`
+
+	// Package is the OS package that installed the file: in the newer
+	// image, or in the older one for removed files
+	Package string `json:"package,omitempty"`
+}
+
+// DirectoryChange aggregates the file changes below a directory
+type DirectoryChange struct {
+	Path      string `json:"path"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Modified  int    `json:"modified"`
+	SizeDelta int64  `json:"sizeDelta"`
+}
+
+// OwnerChange aggregates the file changes of one OS package; files no
+// package lists are grouped under an empty Package
+type OwnerChange struct {
+	Package   string `json:"package"`
+	Manager   string `json:"manager,omitempty"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Modified  int    `json:"modified"`
+	SizeDelta int64  `json:"sizeDelta"`
+}
+
+// PackageChange is an OS package that differs between two images
+type PackageChange struct {
+	Name          string `json:"name"`
+	Manager       string `json:"manager"`
+	Change        Change `json:"change"`
+	VersionBefore string `json:"versionBefore,omitempty"`
+	VersionAfter  string `json:"versionAfter,omitempty"`
+}
+
+// Diff analyzes both references and compares them
+// opts override the engine options for both calls
+func (e *Engine) Diff(ctx context.Context, from, to string, opts ...Option) (*Diff, error) {
+	a, err := e.Slim(ctx, from, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := e.Slim(ctx, to, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffResults(a, b)
+}
+
+// DiffResults compares two results, from the older to the newer
+//
+// Both need their file listings: results loaded in metadata-only mode, or
+// decoded from documents encoded without WithFiles, are rejected
+func DiffResults(from, to *Result) (*Diff, error) {
+	for _, r := range []*Result{from, to} {
+		if err := diffable(r); err != nil {
+			return nil, err
+		}
+	}
+
+	a, b := from.Image, to.Image
+
+	d := &Diff{
+		From:            newDiffImage(a),
+		To:              newDiffImage(b),
+		CompressedDelta: b.CompressedSize() - a.CompressedSize(),
+		SizeDelta:       b.UncompressedSize() - a.UncompressedSize(),
+	}
+
+	pa, pb := a.Packages(), b.Packages()
+
+	d.SharedLayers, d.AddedLayers, d.RemovedLayers = diffLayers(a.Layers, b.Layers)
+	d.Files = diffFiles(a.Files(), b.Files())
+	d.Owners = groupOwners(d.Files, fileOwners(pa), fileOwners(pb))
+	d.Directories = groupDirectories(d.Files)
+	d.Packages = diffPackages(pa, pb)
+
+	return d, nil
+}
+
+// diffable reports why r cannot be compared, if it cannot
+func diffable(r *Result) error {
+	if r == nil || r.Image == nil {
+		return fmt.Errorf("result has no image")
+	}
+
+	img := r.Image
+	if img.MetadataOnly {
+		return fmt.Errorf("%s: metadata-only result has no files to compare", img.Reference)
+	}
+	if img.ListingsOmitted {
+		return fmt.Errorf("%s: result has no file listing; encode it with files", img.Reference)
+	}
+
+	for _, l := range img.Layers {
+		if len(l.Files) > 0 {
+			return nil
+		}
+	}
+	if img.UncompressedSize() > 0 {
+		return fmt.Errorf("%s: result has no file listing; encode it with files", img.Reference)
+	}
+	return nil
+}
+
+func newDiffImage(img *analyser.Image) DiffImage {
+	return DiffImage{
+		Reference:        img.Reference,
+		Digest:           img.Digest,
+		CompressedSize:   img.CompressedSize(),
+		UncompressedSize: img.UncompressedSize(),
+	}
+}
+
+func newDiffLayer(l analyser.Layer) DiffLayer {
+	return DiffLayer{
+		Index:            l.Index,
+		Digest:           l.Digest,
+		DiffID:           l.DiffID,
+		CompressedSize:   l.CompressedSize,
+		UncompressedSize: l.UncompressedSize,
+	}
+}
+
+// layerKey identifies layer content, falling back to the blob digest when
+// the diff ID is unknown
+func layerKey(l analyser.Layer) string {
+	if l.DiffID != "" {
+		return l.DiffID
+	}
+	return l.Digest
+}
+
+// diffLayers matches layers by content, in index order
+func diffLayers(from, to []analyser.Layer) (shared, added, removed []DiffLayer) {
+	shared, added, removed = []DiffLayer{}, []DiffLayer{}, []DiffLayer{}
+
+	inFrom := make(map[string]bool, len(from))
+	for _, l := range from {
+		inFrom[layerKey(l)] = true
+	}
+	inTo := make(map[string]bool, len(to))
+	for _, l := range to {
+		inTo[layerKey(l)] = true
+	}
+
+	for _, l := range sortedLayers(to) {
+		if inFrom[layerKey(l)] {
+			shared = append(shared, newDiffLayer(l))
+		} else {
+			added = append(added, newDiffLayer(l))
+		}
+	}
+	for _, l := range sortedLayers(from) {
+		if !inTo[layerKey(l)] {
+			removed = append(removed, newDiffLayer(l))
+		}
+	}
+
+	return shared, added, removed
+}
+
+func sortedLayers(layers []analyser.Layer) []analyser.Layer {
+	out := append([]analyser.Layer(nil), layers...)
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Index < out[b].Index
+	})
+	return out
+}
+
+// diffFiles compares two merged filesystems sorted by path
+func diffFiles(from, to []analyser.FileEntry) []FileChange {
+	changes := []FileChange{}
+
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case j == len(to) || (i < len(from) && from[i].Path < to[j].Path):
+			f := from[i]
+			changes = append(changes, FileChange{
+				Path:       f.Path,
+				Change:     ChangeRemoved,
+				Type:       f.Type,
+				SizeBefore: f.Size,
+				SizeDelta:  -f.Size,
+			})
+			i++
+
+		case i == len(from) || to[j].Path < from[i].Path:
+			f := to[j]
+			changes = append(changes, FileChange{
+				Path:      f.Path,
+				Change:    ChangeAdded,
+				Type:      f.Type,
+				SizeAfter: f.Size,
+				SizeDelta: f.Size,
+			})
+			j++
+
+		default:
+			a, b := from[i], to[j]
+			if a.Type != b.Type || a.Size != b.Size || a.Mode != b.Mode || a.Linkname != b.Linkname || contentChanged(a, b) {
+				changes = append(changes, FileChange{
+					Path:       b.Path,
+					Change:     ChangeModified,
+					Type:       b.Type,
+					SizeBefore: a.Size,
+					SizeAfter:  b.Size,
+					SizeDelta:  b.Size - a.Size,
+				})
+			}
+			i++
+			j++
+		}
+	}
+
+	return changes
+}
+
+// contentChanged reports whether two regular files have different content
+// Entries without a digest cannot be compared and are assumed unchanged
+func contentChanged(a, b analyser.FileEntry) bool {
+	return a.Digest != "" && b.Digest != "" && a.Digest != b.Digest
+}
+
+// fileOwners maps the files listed by pkgs to their package
+func fileOwners(pkgs []analyser.Package) map[string]analyser.Package {
+	owners := make(map[string]analyser.Package)
+	for _, p := range pkgs {
+		for _, f := range p.Files {
+			if _, ok := owners[f]; !ok {
+				owners[f] = p
+			}
+		}
+	}
+	return owners
+}
+
+// groupOwners sets the Package of every file change and aggregates the
+// changes by it. Removed files are looked up in the older image, others in
+// the newer one. Directory entries are not counted
+func groupOwners(files []FileChange, before, after map[string]analyser.Package) []OwnerChange {
+	type key struct{ manager, name string }
+	byOwner := make(map[key]*OwnerChange)
+
+	for i, f := range files {
+		owners := after
+		if f.Change == ChangeRemoved {
+			owners = before
+		}
+		p := owners[f.Path]
+		files[i].Package = p.Name
+
+		if f.Type == FileTypeDir {
+			continue
+		}
+
+		k := key{p.Manager, p.Name}
+		g, ok := byOwner[k]
+		if !ok {
+			g = &OwnerChange{Package: p.Name, Manager: p.Manager}
+			byOwner[k] = g
+		}
+
+		switch f.Change {
+		case ChangeAdded:
+			g.Added++
+		case ChangeRemoved:
+			g.Removed++
+		case ChangeModified:
+			g.Modified++
+		}
+		g.SizeDelta += f.SizeDelta
+	}
+
+	groups := make([]OwnerChange, 0, len(byOwner))
+	for _, g := range byOwner {
+		groups = append(groups, *g)
+	}
+
+	sort.Slice(groups, func(a, b int) bool {
+		da, db := abs(groups[a].SizeDelta), abs(groups[b].SizeDelta)
+		if da != db {
+			return da > db
+		}
+		if groups[a].Manager != groups[b].Manager {
+			return groups[a].Manager < groups[b].Manager
+		}
+		return groups[a].Package < groups[b].Package
+	})
+
+	return groups
+}
+
+// groupDirectories aggregates file changes by their first diffDirDepth
+// directories. Directory entries themselves are not counted
+func groupDirectories(files []FileChange) []DirectoryChange {
+	byDir := make(map[string]*DirectoryChange)
+
+	for _, f := range files {
+		if f.Type == FileTypeDir {
+			continue
+		}
+
+		dir := leadingDir(f.Path)
+		g, ok := byDir[dir]
+		if !ok {
+			g = &DirectoryChange{Path: dir}
+			byDir[dir] = g
+		}
+
+		switch f.Change {
+		case ChangeAdded:
+			g.Added++
+		case ChangeRemoved:
+			g.Removed++
+		case ChangeModified:
+			g.Modified++
+		}
+		g.SizeDelta += f.SizeDelta
+	}
+
+	dirs := make([]DirectoryChange, 0, len(byDir))
+	for _, g := range byDir {
+		dirs = append(dirs, *g)
+	}
+
+	sort.Slice(dirs, func(a, b int) bool {
+		da, db := abs(dirs[a].SizeDelta), abs(dirs[b].SizeDelta)
+		if da != db {
+			return da > db
+		}
+		return dirs[a].Path < dirs[b].Path
+	})
+
+	return dirs
+}
+
+// leadingDir returns the directory of p truncated to diffDirDepth
+// components; files at the image root are grouped under ""
+func leadingDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+
+	parts := strings.SplitN(dir, "/", diffDirDepth+1)
+	if len(parts) > diffDirDepth {
+		parts = parts[:diffDirDepth]
+	}
+	return strings.Join(parts, "/")
+}
+
+// diffPackages compares two package lists by manager and name
+func diffPackages(from, to []analyser.Package) []PackageChange {
+	type key struct{ manager, name string }
+
+	before := make(map[key]string, len(from))
+	for _, p := range from {
+		before[key{p.Manager, p.Name}] = p.Version
+	}
+	after := make(map[key]string, len(to))
+	for _, p := range to {
+		after[key{p.Manager, p.Name}] = p.Version
+	}
+
+	changes := []PackageChange{}
+	for k, v := range after {
+		old, ok := before[k]
+		switch {
+		case !ok:
+			changes = append(changes, PackageChange{Name: k.name, Manager: k.manager, Change: ChangeAdded, VersionAfter: v})
+		case old != v:
+			changes = append(changes, PackageChange{Name: k.name, Manager: k.manager, Change: ChangeModified, VersionBefore: old, VersionAfter: v})
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, PackageChange{Name: k.name, Manager: k.manager, Change: ChangeRemoved, VersionBefore: v})
+		}
+	}
+
+	sort.Slice(changes, func(a, b int) bool {
+		if changes[a].Manager != changes[b].Manager {
+			return changes[a].Manager < changes[b].Manager
+		}
+		return changes[a].Name < changes[b].Name
+	})
+
+	return changes
+}
+
+// Summary renders the diff as human-readable text
+func (d *Diff) Summary() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Diff %s -> %s\n", d.From.Reference, d.To.Reference))
+	sb.WriteString(fmt.Sprintf("- Size delta: %+d bytes (compressed %+d bytes)\n", d.SizeDelta, d.CompressedDelta))
+	sb.WriteString(fmt.Sprintf("- Layers: %d shared, %d added, %d removed\n", len(d.SharedLayers), len(d.AddedLayers), len(d.RemovedLayers)))
+
+	var added, removed, modified int
+	for _, f := range d.Files {
+		switch f.Change {
+		case ChangeAdded:
+			added++
+		case ChangeRemoved:
+			removed++
+		case ChangeModified:
+			modified++
+		}
+	}
+	sb.WriteString(fmt.Sprintf("- Files: %d added, %d removed, %d modified\n", added, removed, modified))
+
+	for _, l := range d.AddedLayers {
+		sb.WriteString(fmt.Sprintf("+ layer %d %s (%d bytes)\n", l.Index, l.Digest, l.UncompressedSize))
+	}
+	for _, l := range d.RemovedLayers {
+		sb.WriteString(fmt.Sprintf("- layer %d %s (%d bytes)\n", l.Index, l.Digest, l.UncompressedSize))
+	}
+
+	if len(d.Directories) > 0 {
+		sb.WriteString("Directories:\n")
+		for _, g := range d.Directories {
+			sb.WriteString(fmt.Sprintf("- /%s: %+d bytes (+%d -%d ~%d)\n", g.Path, g.SizeDelta, g.Added, g.Removed, g.Modified))
+		}
+	}
+
+	if len(d.Owners) > 0 {
+		sb.WriteString("Files by package:\n")
+		for _, g := range d.Owners {
+			owner := "(no package)"
+			if g.Package != "" {
+				owner = fmt.Sprintf("%s (%s)", g.Package, g.Manager)
+			}
+			sb.WriteString(fmt.Sprintf("- %s: %+d bytes (+%d -%d ~%d)\n", owner, g.SizeDelta, g.Added, g.Removed, g.Modified))
+		}
+	}
+
+	if len(d.Packages) > 0 {
+		sb.WriteString("Packages:\n")
+		for _, p := range d.Packages {
+			switch p.Change {
+			case ChangeAdded:
+				sb.WriteString(fmt.Sprintf("+ %s %s (%s)\n", p.Name, p.VersionAfter, p.Manager))
+			case ChangeRemoved:
+				sb.WriteString(fmt.Sprintf("- %s %s (%s)\n", p.Name, p.VersionBefore, p.Manager))
+			default:
+				sb.WriteString(fmt.Sprintf("~ %s %s -> %s (%s)\n", p.Name, p.VersionBefore, p.VersionAfter, p.Manager))
+			}
+		}
+	}
+
+	if top := d.largestFileChanges(); len(top) > 0 {
+		sb.WriteString("Largest file changes:\n")
+		for _, f := range top {
+			sb.WriteString(fmt.Sprintf("- /%s: %s %+d bytes\n", f.Path, f.Change, f.SizeDelta))
+		}
+	}
+
+	return sb.String()
+}
+
+// largestFileChanges returns the diffTopFiles changes with the largest
+// absolute size delta
+func (d *Diff) largestFileChanges() []FileChange {
+	var files []FileChange
+	for _, f := range d.Files {
+		if f.SizeDelta != 0 {
+			files = append(files, f)
+		}
+	}
+
+	sort.SliceStable(files, func(a, b int) bool {
+		return abs(files[a].SizeDelta) > abs(files[b].SizeDelta)
+	})
+	if len(files) > diffTopFiles {
+		files = files[:diffTopFiles]
+	}
+	return files
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,208 @@
+package slimmer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	file := func(p string, size int64, digest string) FileEntry {
+		return FileEntry{Path: p, Type: FileTypeRegular, Size: size, Mode: 0o644, Digest: digest}
+	}
+
+	tests := []struct {
+		name     string
+		from, to []FileEntry
+		want     []FileChange
+	}{
+		{name: "identical", from: []FileEntry{file("a", 1, "sha256:a")}, to: []FileEntry{file("a", 1, "sha256:a")}, want: []FileChange{}},
+		{
+			name: "added and removed",
+			from: []FileEntry{file("a", 1, ""), file("c", 3, "")},
+			to:   []FileEntry{file("b", 2, ""), file("c", 3, "")},
+			want: []FileChange{
+				{Path: "a", Change: ChangeRemoved, Type: FileTypeRegular, SizeBefore: 1, SizeDelta: -1},
+				{Path: "b", Change: ChangeAdded, Type: FileTypeRegular, SizeAfter: 2, SizeDelta: 2},
+			},
+		},
+		{
+			name: "resized",
+			from: []FileEntry{file("a", 1, "")},
+			to:   []FileEntry{file("a", 5, "")},
+			want: []FileChange{{Path: "a", Change: ChangeModified, Type: FileTypeRegular, SizeBefore: 1, SizeAfter: 5, SizeDelta: 4}},
+		},
+		{
+			name: "same size, new content",
+			from: []FileEntry{file("a", 1, "sha256:a")},
+			to:   []FileEntry{file("a", 1, "sha256:b")},
+			want: []FileChange{{Path: "a", Change: ChangeModified, Type: FileTypeRegular, SizeBefore: 1, SizeAfter: 1}},
+		},
+		{
+			name: "content unknown on one side",
+			from: []FileEntry{file("a", 1, "")},
+			to:   []FileEntry{file("a", 1, "sha256:b")},
+			want: []FileChange{},
+		},
+		{
+			name: "mode change",
+			from: []FileEntry{file("a", 1, "sha256:a")},
+			to:   []FileEntry{{Path: "a", Type: FileTypeRegular, Size: 1, Mode: 0o755, Digest: "sha256:a"}},
+			want: []FileChange{{Path: "a", Change: ChangeModified, Type: FileTypeRegular, SizeBefore: 1, SizeAfter: 1}},
+		},
+		{
+			name: "file replaced by symlink",
+			from: []FileEntry{file("a", 1, "sha256:a")},
+			to:   []FileEntry{{Path: "a", Type: FileTypeSymlink, Linkname: "b"}},
+			want: []FileChange{{Path: "a", Change: ChangeModified, Type: FileTypeSymlink, SizeBefore: 1, SizeDelta: -1}},
+		},
+		{
+			name: "retargeted symlink",
+			from: []FileEntry{{Path: "a", Type: FileTypeSymlink, Linkname: "b"}},
+			to:   []FileEntry{{Path: "a", Type: FileTypeSymlink, Linkname: "c"}},
+			want: []FileChange{{Path: "a", Change: ChangeModified, Type: FileTypeSymlink}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffFiles(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffFiles = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	from := policyImage()
+	from.Layers[0].DiffID = "sha256:base"
+	from.Layers[1].DiffID = "sha256:app1"
+	from.Layers[0].Packages[1].Files = []string{"usr/bin/curl"}
+	from.Layers[0].Files = append(from.Layers[0].Files, FileEntry{Path: "usr/bin/curl", Type: FileTypeRegular, Size: 200})
+
+	to := policyImage()
+	to.Reference = "example.com/app:2"
+	to.Layers[0].DiffID = "sha256:base"
+	to.Layers[1] = Layer{
+		Index:            1,
+		DiffID:           "sha256:app2",
+		UncompressedSize: 5000,
+		Files: []FileEntry{
+			{Path: "etc/motd", Type: FileTypeRegular, Size: 30},
+			{Path: "usr/bin/curl", Type: FileTypeRegular, Whiteout: true},
+			{Path: "srv/app/bin/server", Type: FileTypeRegular, Size: 4000},
+		},
+		Packages: []Package{
+			{Name: "busybox", Version: "1.37", Manager: "apk", Source: "lib/apk/db/installed"},
+		},
+	}
+	to.Layers[1].Files = append(to.Layers[1].Files, FileEntry{Path: "lib/apk/db/installed", Type: FileTypeRegular, Size: 80})
+
+	d, err := DiffResults(&Result{Image: from}, &Result{Image: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.SizeDelta != 1000 {
+		t.Errorf("SizeDelta = %d, want 1000", d.SizeDelta)
+	}
+
+	layers := func(ls []DiffLayer) []string {
+		var ids []string
+		for _, l := range ls {
+			ids = append(ids, l.DiffID)
+		}
+		return ids
+	}
+	if got := layers(d.SharedLayers); !reflect.DeepEqual(got, []string{"sha256:base"}) {
+		t.Errorf("shared layers = %v", got)
+	}
+	if got := layers(d.AddedLayers); !reflect.DeepEqual(got, []string{"sha256:app2"}) {
+		t.Errorf("added layers = %v", got)
+	}
+	if got := layers(d.RemovedLayers); !reflect.DeepEqual(got, []string{"sha256:app1"}) {
+		t.Errorf("removed layers = %v", got)
+	}
+
+	wantFiles := []FileChange{
+		{Path: "etc/motd", Change: ChangeModified, Type: FileTypeRegular, SizeBefore: 10, SizeAfter: 30, SizeDelta: 20},
+		{Path: "lib/apk/db/installed", Change: ChangeModified, Type: FileTypeRegular, SizeBefore: 100, SizeAfter: 80, SizeDelta: -20},
+		{Path: "root/.ssh/id_rsa", Change: ChangeRemoved, Type: FileTypeRegular, SizeBefore: 3000, SizeDelta: -3000},
+		{Path: "srv/app/bin/server", Change: ChangeAdded, Type: FileTypeRegular, SizeAfter: 4000, SizeDelta: 4000},
+		{Path: "usr/bin/curl", Change: ChangeRemoved, Type: FileTypeRegular, SizeBefore: 200, SizeDelta: -200, Package: "curl"},
+	}
+	if !reflect.DeepEqual(d.Files, wantFiles) {
+		t.Errorf("files = %+v, want %+v", d.Files, wantFiles)
+	}
+
+	wantDirs := []DirectoryChange{
+		{Path: "srv/app", Added: 1, SizeDelta: 4000},
+		{Path: "root/.ssh", Removed: 1, SizeDelta: -3000},
+		{Path: "usr/bin", Removed: 1, SizeDelta: -200},
+		{Path: "etc", Modified: 1, SizeDelta: 20},
+		{Path: "lib/apk", Modified: 1, SizeDelta: -20},
+	}
+	if !reflect.DeepEqual(d.Directories, wantDirs) {
+		t.Errorf("directories = %+v, want %+v", d.Directories, wantDirs)
+	}
+
+	wantOwners := []OwnerChange{
+		{Added: 1, Removed: 1, Modified: 2, SizeDelta: 1000},
+		{Package: "curl", Manager: "apk", Removed: 1, SizeDelta: -200},
+	}
+	if !reflect.DeepEqual(d.Owners, wantOwners) {
+		t.Errorf("owners = %+v, want %+v", d.Owners, wantOwners)
+	}
+
+	wantPkgs := []PackageChange{
+		{Name: "busybox", Manager: "apk", Change: ChangeModified, VersionBefore: "1.36", VersionAfter: "1.37"},
+		{Name: "curl", Manager: "apk", Change: ChangeRemoved, VersionBefore: "8.5"},
+	}
+	if !reflect.DeepEqual(d.Packages, wantPkgs) {
+		t.Errorf("packages = %+v, want %+v", d.Packages, wantPkgs)
+	}
+}
+
+func TestDiffResultsWithoutFiles(t *testing.T) {
+	metadataOnly := policyImage()
+	metadataOnly.MetadataOnly = true
+
+	tests := []struct {
+		name string
+		r    *Result
+	}{
+		{name: "nil result"},
+		{name: "no image", r: &Result{}},
+		{name: "metadata-only", r: &Result{Image: metadataOnly}},
+		{name: "decoded without files", r: decodeResult(t, &Result{Image: policyImage()})},
+	}
+
+	ok := &Result{Image: policyImage()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DiffResults(tt.r, ok); err == nil {
+				t.Error("DiffResults accepted the older result")
+			}
+			if _, err := DiffResults(ok, tt.r); err == nil {
+				t.Error("DiffResults accepted the newer result")
+			}
+		})
+	}
+
+	if _, err := DiffResults(ok, decodeResult(t, ok, WithFiles())); err != nil {
+		t.Errorf("DiffResults of a result decoded with files = %v", err)
+	}
+}
+
+func TestLeadingDir(t *testing.T) {
+	tests := map[string]string{
+		"motd":                "",
+		"etc/motd":            "etc",
+		"usr/bin/curl":        "usr/bin",
+		"usr/share/doc/a/b/c": "usr/share",
+	}
+	for p, want := range tests {
+		if got := leadingDir(p); got != want {
+			t.Errorf("leadingDir(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
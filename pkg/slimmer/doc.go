@@ -0,0 +1,28 @@
+// Package slimmer is the public API of the image slimmer engine
+//
+// It is the only package external modules should import. The engine is
+// implemented in internal packages; every type a caller can receive from
+// this package is re-exported here as a type alias, so values can be named,
+// constructed and inspected without reaching into internal/.
+//
+// # Compatibility
+//
+// The identifiers exported by this package follow semantic versioning:
+//
+//   - Exported types, functions, constants and methods are not removed or
+//     changed incompatibly within a major version
+//   - New struct fields, constants (including ErrorCode values), options and
+//     functions may be added in minor versions; callers should not rely on
+//     exhaustive switches over ErrorCode or use unkeyed struct literals
+//   - ErrorCode string values are stable and safe to persist or match on
+//   - The JSON document layout is versioned separately by SchemaVersion
+//   - Packages under internal/ carry no guarantees and may change at any time
+//
+// # Overview
+//
+// Load and Open fetch images; Engine runs the full pipeline and returns a
+// Result holding the Image, its Metrics, a DeterministicImage and an
+// ImagePlan. Policy evaluates a Result for CI gating and Report condenses
+// it for humans. Failures are reported as *AnalyzerError values carrying a
+// machine-readable ErrorCode; use IsCode or AsAnalyzerError to inspect them.
+package slimmer
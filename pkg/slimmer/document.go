@@ -0,0 +1,741 @@
+package slimmer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+	planner "github.com/pnkcaht/image-slimmer-core/internal/planner"
+)
+
+// SchemaVersion identifies the layout of the JSON representation of a Result
+//
+// Field names and meanings are stable within a version. Fields may be added
+// in a compatible way; renames, removals and semantic changes bump the version
+const SchemaVersion = "slimmer.result/v1"
+
+// ResultDocument is the versioned JSON representation of a Result
+type ResultDocument struct {
+	SchemaVersion string                 `json:"schemaVersion" description:"Layout version of this document"`
+	Image         ImageDocument          `json:"image" description:"Resolved image metadata"`
+	Metrics       MetricsDocument        `json:"metrics" description:"Execution metrics of the analysis"`
+	Deterministic *DeterministicDocument `json:"deterministic,omitempty" description:"Normalized, index-ordered view of the image; absent in metadata-only mode"`
+	Plan          *PlanDocument          `json:"plan,omitempty" description:"Slimming plan; absent in metadata-only mode"`
+	Status        string                 `json:"status,omitempty" description:"How the result was obtained: analyzed or unchanged"`
+}
+
+// ImageDocument is the JSON representation of an analyzed image
+type ImageDocument struct {
+	Reference        string             `json:"reference" description:"Image reference as requested"`
+	Digest           string             `json:"digest" description:"Content digest of the resolved manifest"`
+	ResolvedDigest   string             `json:"resolvedDigest,omitempty" description:"Digest the reference resolved to; names the index for multi-platform images"`
+	Platform         string             `json:"platform,omitempty" description:"Platform of the image as os/arch[/variant]: the one selected from a multi-platform index, or the one of its config"`
+	MediaType        string             `json:"mediaType" description:"Manifest media type"`
+	ManifestSize     int64              `json:"manifestSize" description:"Size of the manifest in bytes"`
+	CompressedSize   int64              `json:"compressedSize" description:"Sum of compressed layer sizes in bytes"`
+	UncompressedSize int64              `json:"uncompressedSize" description:"Sum of uncompressed layer sizes in bytes"`
+	WastedBytes      int64              `json:"wastedBytes" description:"Bytes of files overwritten or deleted by later layers"`
+	Layers           []LayerDocument    `json:"layers" description:"Layers in index order"`
+	Packages         []PackageDocument  `json:"packages" description:"OS packages installed in the final filesystem"`
+	LoadedAt         string             `json:"loadedAt,omitempty" description:"RFC 3339 time the image was loaded; only present when timings are requested"`
+	MetadataOnly     bool               `json:"metadataOnly,omitempty" description:"Layers were intentionally not extracted"`
+	Base             *BaseImageDocument `json:"base,omitempty" description:"Base image the leading layers come from, if known"`
+	Entrypoint       []string           `json:"entrypoint,omitempty" description:"Entrypoint from the image config"`
+	Cmd              []string           `json:"cmd,omitempty" description:"Default arguments from the image config"`
+}
+
+// BaseImageDocument is the JSON representation of a BaseImage
+type BaseImageDocument struct {
+	Name   string `json:"name,omitempty" description:"Base image reference"`
+	Digest string `json:"digest,omitempty" description:"Base image manifest digest"`
+	Layers int    `json:"layers" description:"Number of leading image layers that come from the base"`
+	Source string `json:"source" description:"How the base was detected: annotation or catalog"`
+}
+
+// LayerDocument is the JSON representation of an image layer
+type LayerDocument struct {
+	Index            int                           `json:"index" description:"Zero-based position of the layer"`
+	Digest           string                        `json:"digest" description:"Compressed blob digest"`
+	DiffID           string                        `json:"diffId" description:"Uncompressed content digest"`
+	MediaType        string                        `json:"mediaType" description:"Layer media type"`
+	CompressedSize   int64                         `json:"compressedSize" description:"Compressed size in bytes"`
+	UncompressedSize int64                         `json:"uncompressedSize" description:"Uncompressed size in bytes"`
+	FileCount        int                           `json:"fileCount" description:"Number of entries in the layer, including whiteouts"`
+	Files            []FileDocument                `json:"files,omitempty" description:"Layer entries; only present when files are requested"`
+	Packages         []PackageDocument             `json:"packages,omitempty" description:"Packages read from databases in this layer; only present when files are requested"`
+	Compression      string                        `json:"compression,omitempty" description:"Compression of the layer blob: gzip, zstd, estargz or none"`
+	Estimates        []CompressionEstimateDocument `json:"estimates,omitempty" description:"Estimated compressed sizes in other formats; only present when compression estimates are requested"`
+
+	InterpretersUnknown bool `json:"interpretersUnknown,omitempty" description:"Layer was listed from its eStargz TOC, so its files record no ELF interpreter"`
+}
+
+// CompressionEstimateDocument is the JSON representation of a CompressionEstimate
+type CompressionEstimateDocument struct {
+	Format string `json:"format" description:"Compression format: gzip, zstd or estargz"`
+	Level  int    `json:"level" description:"Compression level"`
+	Size   int64  `json:"size" description:"Estimated compressed size in bytes"`
+}
+
+// FileDocument is the JSON representation of a layer entry
+type FileDocument struct {
+	Path     string `json:"path" description:"Path relative to the image root"`
+	Type     string `json:"type" description:"Entry type: file, dir, symlink, hardlink or other"`
+	Size     int64  `json:"size" description:"Size in bytes"`
+	Mode     int64  `json:"mode" description:"Permission and mode bits"`
+	Linkname string `json:"linkname,omitempty" description:"Link target for symlinks and hardlinks"`
+	Whiteout bool   `json:"whiteout,omitempty" description:"Entry deletes path from lower layers"`
+	Opaque   bool   `json:"opaque,omitempty" description:"Whiteout deletes everything below path from lower layers"`
+	Digest   string `json:"digest,omitempty" description:"Content digest of regular files"`
+
+	Interpreter string `json:"interpreter,omitempty" description:"Program interpreter of ELF executables"`
+}
+
+// PackageDocument is the JSON representation of an OS package
+type PackageDocument struct {
+	Name    string   `json:"name" description:"Package name"`
+	Version string   `json:"version" description:"Package version"`
+	Manager string   `json:"manager" description:"Package manager: dpkg or apk"`
+	Source  string   `json:"source" description:"Database path the package was read from"`
+	Files   []string `json:"files,omitempty" description:"Paths the package installed, when its database lists them; only present when files are requested"`
+}
+
+// MetricsDocument is the JSON representation of execution metrics
+type MetricsDocument struct {
+	FetchAttempts int  `json:"fetchAttempts" description:"Number of fetch executions, including retries"`
+	DigestPinned  bool `json:"digestPinned" description:"Whether the reference was pinned to a digest"`
+	Success       bool `json:"success" description:"Whether the analysis succeeded"`
+	Cached        bool `json:"cached,omitempty" description:"Whether the result was served from a cache"`
+	FetchSkipped  bool `json:"fetchSkipped,omitempty" description:"Whether the fetch was skipped because the image was unchanged"`
+
+	Endpoint string `json:"endpoint,omitempty" description:"Registry that served the image, a mirror or the registry of the reference"`
+
+	CompressedBytes   int64                  `json:"compressedBytes,omitempty" description:"Compressed layer bytes downloaded"`
+	UncompressedBytes int64                  `json:"uncompressedBytes,omitempty" description:"Uncompressed layer bytes read while indexing"`
+	Layers            []LayerMetricsDocument `json:"layers,omitempty" description:"Per-layer metrics in index order"`
+
+	FetchDuration *int64 `json:"fetchDurationNanos,omitempty" description:"Fetch duration in nanoseconds; only present when timings are requested"`
+	BuildDuration *int64 `json:"buildDurationNanos,omitempty" description:"Build duration in nanoseconds; only present when timings are requested"`
+	TotalDuration *int64 `json:"totalDurationNanos,omitempty" description:"Total duration in nanoseconds; only present when timings are requested"`
+}
+
+// LayerMetricsDocument is the JSON representation of per-layer metrics
+type LayerMetricsDocument struct {
+	Index             int    `json:"index" description:"Layer index"`
+	Digest            string `json:"digest" description:"Layer digest"`
+	CompressedBytes   int64  `json:"compressedBytes" description:"Compressed bytes downloaded; zero when served from cache"`
+	UncompressedBytes int64  `json:"uncompressedBytes" description:"Uncompressed bytes read while indexing"`
+	Cached            bool   `json:"cached,omitempty" description:"Whether the layer analysis came from the cache"`
+	Duration          *int64 `json:"durationNanos,omitempty" description:"Download and indexing time in nanoseconds; only present when timings are requested"`
+}
+
+// DeterministicDocument is the JSON representation of a DeterministicImage
+type DeterministicDocument struct {
+	Reference string          `json:"reference" description:"Image reference"`
+	Digest    string          `json:"digest" description:"Image digest"`
+	Layers    []LayerDocument `json:"layers" description:"Layers sorted by index"`
+}
+
+// PlanDocument is the JSON representation of an ImagePlan
+type PlanDocument struct {
+	Reference string              `json:"reference" description:"Image reference the plan was built for"`
+	Digest    string              `json:"digest" description:"Image digest the plan was built for"`
+	Layers    []LayerPlanDocument `json:"layers" description:"Planned action per layer"`
+
+	EstimatedSavings int64                              `json:"estimatedSavings" description:"Uncompressed bytes saved by removing the layers marked for removal"`
+	Base             *BaseImageDocument                 `json:"base,omitempty" description:"Base image the leading layers come from, if known"`
+	Recommendations  []BaseRecommendationDocument       `json:"recommendations,omitempty" description:"Smaller bases the image could be rebuilt on, drop-in candidates first"`
+	Layout           []LayoutRecommendationDocument     `json:"layout,omitempty" description:"Layer merges and reorders that improve registry dedup and pull caching"`
+	Compression      *CompressionRecommendationDocument `json:"compression,omitempty" description:"Layer media type change that saves transfer size, when compression estimates were requested"`
+}
+
+// CompressionRecommendationDocument is the JSON representation of a CompressionRecommendation
+type CompressionRecommendationDocument struct {
+	MediaType      string `json:"mediaType" description:"Recommended layer media type"`
+	Level          int    `json:"level" description:"Recommended compression level"`
+	Layers         []int  `json:"layers" description:"Indexes of the layers to recompress"`
+	CurrentSize    int64  `json:"currentSize" description:"Compressed size of those layers in bytes"`
+	EstimatedSize  int64  `json:"estimatedSize" description:"Estimated compressed size of those layers after the change in bytes"`
+	Savings        int64  `json:"savings" description:"Estimated transfer savings in bytes"`
+	Incompressible []int  `json:"incompressible,omitempty" description:"Indexes of layers left as they are because most of their content is already compressed"`
+	Description    string `json:"description" description:"Human-readable explanation"`
+}
+
+// LayoutRecommendationDocument is the JSON representation of a LayoutRecommendation
+type LayoutRecommendationDocument struct {
+	Kind        string `json:"kind" description:"squash to merge layers, reorder to move the first layer above the others"`
+	Layers      []int  `json:"layers" description:"Layer indexes concerned"`
+	Bytes       int64  `json:"bytes" description:"Estimated bytes saved: shadowed bytes for a squash, bytes pulled again per release for a reorder"`
+	Description string `json:"description" description:"Human-readable explanation"`
+}
+
+// BaseRecommendationDocument is the JSON representation of a BaseRecommendation
+type BaseRecommendationDocument struct {
+	Name          string   `json:"name" description:"Recommended base image reference"`
+	Digest        string   `json:"digest,omitempty" description:"Recommended base image digest"`
+	SizeDelta     int64    `json:"sizeDelta" description:"Estimated change in uncompressed image size in bytes"`
+	EstimatedSize int64    `json:"estimatedSize" description:"Estimated uncompressed image size on the new base in bytes"`
+	Blockers      []string `json:"blockers,omitempty" description:"Runtime needs of the image the base does not meet"`
+}
+
+// LayerPlanDocument is the JSON representation of a LayerPlan
+type LayerPlanDocument struct {
+	Index       int    `json:"index" description:"Layer index"`
+	Digest      string `json:"digest" description:"Layer digest"`
+	Action      string `json:"action" description:"Planned action: keep, remove or rebuild"`
+	Description string `json:"description" description:"Human-readable rationale"`
+	Size        int64  `json:"size" description:"Uncompressed layer size in bytes"`
+	Risk        string `json:"risk,omitempty" description:"Risk of modifying or removing the layer: low, medium or high"`
+	RiskReason  string `json:"riskReason,omitempty" description:"Why the layer has its risk level"`
+	Base        bool   `json:"base,omitempty" description:"Layer comes from the base image"`
+}
+
+// EncodeOption configures how a Result is converted to a document
+type EncodeOption func(*encodeConfig)
+
+type encodeConfig struct {
+	timings bool
+	files   bool
+}
+
+// WithTimings includes wall-clock fields (load time and durations)
+// Documents with timings are not reproducible byte for byte
+func WithTimings() EncodeOption {
+	return func(c *encodeConfig) {
+		c.timings = true
+	}
+}
+
+// WithFiles includes per-layer file and package listings
+func WithFiles() EncodeOption {
+	return func(c *encodeConfig) {
+		c.files = true
+	}
+}
+
+// Document converts the Result into its versioned JSON representation
+func (r *Result) Document(opts ...EncodeOption) *ResultDocument {
+	cfg := &encodeConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	doc := &ResultDocument{
+		SchemaVersion: SchemaVersion,
+		Metrics:       newMetricsDocument(r.Metrics, cfg),
+		Status:        string(r.Status),
+	}
+
+	if r.Image != nil {
+		doc.Image = newImageDocument(r.Image, cfg)
+	}
+
+	if r.Deterministic != nil {
+		doc.Deterministic = &DeterministicDocument{
+			Reference: r.Deterministic.Reference,
+			Digest:    r.Deterministic.Digest,
+			Layers:    newLayerDocuments(r.Deterministic.Layers, &encodeConfig{}),
+		}
+	}
+
+	if r.Plan != nil {
+		doc.Plan = NewPlanDocument(r.Plan)
+	}
+
+	return doc
+}
+
+// MarshalJSON encodes the Result as a canonical document without
+// wall-clock fields or file listings
+func (r *Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Document())
+}
+
+// UnmarshalJSON decodes a document produced by MarshalJSON or EncodeJSON
+// File listings and package databases are only restored when the document
+// was encoded WithFiles; otherwise Image.ListingsOmitted is set
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var doc ResultDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	decoded, err := doc.Result()
+	if err != nil {
+		return err
+	}
+
+	*r = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the document canonically
+func (d *ResultDocument) MarshalJSON() ([]byte, error) {
+	type plain ResultDocument
+	return CanonicalJSON((*plain)(d))
+}
+
+// EncodeJSON writes the canonical document of r followed by a newline
+func EncodeJSON(w io.Writer, r *Result, opts ...EncodeOption) error {
+	data, err := json.Marshal(r.Document(opts...))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// CanonicalJSON encodes v with object keys sorted at every level, no
+// insignificant whitespace and no HTML escaping, so equal values always
+// produce identical bytes
+func CanonicalJSON(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Result converts a document back into a Result
+func (d *ResultDocument) Result() (*Result, error) {
+	if d.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %q, expected %q", d.SchemaVersion, SchemaVersion)
+	}
+
+	img, err := d.Image.image()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{
+		Image:   img,
+		Metrics: d.Metrics.metrics(),
+		Status:  Status(d.Status),
+	}
+
+	if d.Deterministic != nil {
+		layers := make([]analyser.Layer, len(d.Deterministic.Layers))
+		for i, l := range d.Deterministic.Layers {
+			layers[i] = l.layer()
+		}
+		r.Deterministic = &planner.DeterministicImage{
+			Reference: d.Deterministic.Reference,
+			Digest:    d.Deterministic.Digest,
+			Layers:    layers,
+		}
+	}
+
+	if d.Plan != nil {
+		r.Plan = d.Plan.Plan()
+	}
+
+	return r, nil
+}
+
+// NewPlanDocument converts an ImagePlan into its JSON representation
+func NewPlanDocument(p *digest.ImagePlan) *PlanDocument {
+	doc := &PlanDocument{
+		Reference: p.Reference,
+		Digest:    p.Digest,
+		Layers:    make([]LayerPlanDocument, len(p.Layers)),
+
+		EstimatedSavings: p.EstimatedSavings(),
+		Base:             newBaseImageDocument(p.Base),
+	}
+	if c := p.Compression; c != nil {
+		doc.Compression = &CompressionRecommendationDocument{
+			MediaType:      c.MediaType,
+			Level:          c.Level,
+			Layers:         c.Layers,
+			CurrentSize:    c.CurrentSize,
+			EstimatedSize:  c.EstimatedSize,
+			Savings:        c.Savings(),
+			Incompressible: c.Incompressible,
+			Description:    c.Description,
+		}
+	}
+	for _, r := range p.Recommendations {
+		doc.Recommendations = append(doc.Recommendations, BaseRecommendationDocument{
+			Name:          r.Name,
+			Digest:        r.Digest,
+			SizeDelta:     r.SizeDelta,
+			EstimatedSize: r.EstimatedSize,
+			Blockers:      r.Blockers,
+		})
+	}
+	for _, r := range p.Layout {
+		doc.Layout = append(doc.Layout, LayoutRecommendationDocument{
+			Kind:        r.Kind,
+			Layers:      r.Layers,
+			Bytes:       r.Bytes,
+			Description: r.Description,
+		})
+	}
+	for i, l := range p.Layers {
+		doc.Layers[i] = LayerPlanDocument{
+			Index:       l.Index,
+			Digest:      l.Digest,
+			Action:      l.Action,
+			Description: l.Description,
+			Size:        l.Size,
+			Risk:        string(l.Risk),
+			RiskReason:  l.RiskReason,
+			Base:        l.Base,
+		}
+	}
+	return doc
+}
+
+// MarshalJSON encodes the plan document canonically
+func (d *PlanDocument) MarshalJSON() ([]byte, error) {
+	type plain PlanDocument
+	return CanonicalJSON((*plain)(d))
+}
+
+// Plan converts the document back into an ImagePlan
+func (d *PlanDocument) Plan() *digest.ImagePlan {
+	p := &digest.ImagePlan{
+		Reference: d.Reference,
+		Digest:    d.Digest,
+		Layers:    make([]digest.LayerPlan, len(d.Layers)),
+		Base:      d.Base.base(),
+	}
+	if c := d.Compression; c != nil {
+		p.Compression = &digest.CompressionRecommendation{
+			MediaType:      c.MediaType,
+			Level:          c.Level,
+			Layers:         c.Layers,
+			CurrentSize:    c.CurrentSize,
+			EstimatedSize:  c.EstimatedSize,
+			Incompressible: c.Incompressible,
+			Description:    c.Description,
+		}
+	}
+	for _, r := range d.Recommendations {
+		p.Recommendations = append(p.Recommendations, digest.BaseRecommendation{
+			Name:          r.Name,
+			Digest:        r.Digest,
+			SizeDelta:     r.SizeDelta,
+			EstimatedSize: r.EstimatedSize,
+			Blockers:      r.Blockers,
+		})
+	}
+	for _, r := range d.Layout {
+		p.Layout = append(p.Layout, digest.LayoutRecommendation{
+			Kind:        r.Kind,
+			Layers:      r.Layers,
+			Bytes:       r.Bytes,
+			Description: r.Description,
+		})
+	}
+	for i, l := range d.Layers {
+		p.Layers[i] = digest.LayerPlan{
+			Index:       l.Index,
+			Digest:      l.Digest,
+			Action:      l.Action,
+			Description: l.Description,
+			Size:        l.Size,
+			Risk:        digest.RiskLevel(l.Risk),
+			RiskReason:  l.RiskReason,
+			Base:        l.Base,
+		}
+	}
+	return p
+}
+
+// DecodePlan reads a plan document, as written by "slimmer plan -o json"
+func DecodePlan(r io.Reader) (*digest.ImagePlan, error) {
+	var doc PlanDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Plan(), nil
+}
+
+func newImageDocument(img *analyser.Image, cfg *encodeConfig) ImageDocument {
+	doc := ImageDocument{
+		Reference:        img.Reference,
+		Digest:           img.Digest,
+		ResolvedDigest:   img.ResolvedDigest,
+		Platform:         img.Platform,
+		MediaType:        img.MediaType,
+		ManifestSize:     img.Size,
+		CompressedSize:   img.CompressedSize(),
+		UncompressedSize: img.UncompressedSize(),
+		WastedBytes:      img.WastedBytes(),
+		Layers:           newLayerDocuments(img.Layers, cfg),
+		Packages:         newPackageDocuments(img.Packages(), cfg.files),
+		MetadataOnly:     img.MetadataOnly,
+		Base:             newBaseImageDocument(img.Base),
+		Entrypoint:       img.Entrypoint,
+		Cmd:              img.Cmd,
+	}
+
+	if cfg.timings && !img.LoadedAt.IsZero() {
+		doc.LoadedAt = img.LoadedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return doc
+}
+
+func newBaseImageDocument(b *analyser.BaseImage) *BaseImageDocument {
+	if b == nil {
+		return nil
+	}
+	return &BaseImageDocument{
+		Name:   b.Name,
+		Digest: b.Digest,
+		Layers: b.Layers,
+		Source: b.Source,
+	}
+}
+
+func (d *BaseImageDocument) base() *analyser.BaseImage {
+	if d == nil {
+		return nil
+	}
+	return &analyser.BaseImage{
+		Name:   d.Name,
+		Digest: d.Digest,
+		Layers: d.Layers,
+		Source: d.Source,
+	}
+}
+
+func newLayerDocuments(layers []analyser.Layer, cfg *encodeConfig) []LayerDocument {
+	docs := make([]LayerDocument, len(layers))
+	for i, l := range layers {
+		docs[i] = LayerDocument{
+			Index:            l.Index,
+			Digest:           l.Digest,
+			DiffID:           l.DiffID,
+			MediaType:        l.MediaType,
+			CompressedSize:   l.CompressedSize,
+			UncompressedSize: l.UncompressedSize,
+			FileCount:        len(l.Files),
+			Compression:      l.Compression,
+
+			InterpretersUnknown: l.InterpretersUnknown,
+		}
+		for _, e := range l.Estimates {
+			docs[i].Estimates = append(docs[i].Estimates, CompressionEstimateDocument{
+				Format: e.Format,
+				Level:  e.Level,
+				Size:   e.Size,
+			})
+		}
+
+		if cfg.files {
+			docs[i].Files = make([]FileDocument, len(l.Files))
+			for j, f := range l.Files {
+				docs[i].Files[j] = FileDocument{
+					Path:     f.Path,
+					Type:     string(f.Type),
+					Size:     f.Size,
+					Mode:     f.Mode,
+					Linkname: f.Linkname,
+					Whiteout: f.Whiteout,
+					Opaque:   f.Opaque,
+					Digest:   f.Digest,
+
+					Interpreter: f.Interpreter,
+				}
+			}
+			docs[i].Packages = newPackageDocuments(l.Packages, true)
+		}
+	}
+	return docs
+}
+
+// newPackageDocuments converts pkgs, with their file lists when files is set
+func newPackageDocuments(pkgs []analyser.Package, files bool) []PackageDocument {
+	docs := make([]PackageDocument, len(pkgs))
+	for i, p := range pkgs {
+		docs[i] = PackageDocument{
+			Name:    p.Name,
+			Version: p.Version,
+			Manager: p.Manager,
+			Source:  p.Source,
+		}
+		if files {
+			docs[i].Files = p.Files
+		}
+	}
+	return docs
+}
+
+func newMetricsDocument(m analyser.Metrics, cfg *encodeConfig) MetricsDocument {
+	doc := MetricsDocument{
+		FetchAttempts: m.FetchAttempts,
+		DigestPinned:  m.DigestPinned,
+		Success:       m.Success,
+		Cached:        m.Cached,
+		FetchSkipped:  m.FetchSkipped,
+		Endpoint:      m.Endpoint,
+
+		CompressedBytes:   m.CompressedBytes,
+		UncompressedBytes: m.UncompressedBytes,
+	}
+
+	for _, l := range m.Layers {
+		ld := LayerMetricsDocument{
+			Index:             l.Index,
+			Digest:            l.Digest,
+			CompressedBytes:   l.CompressedBytes,
+			UncompressedBytes: l.UncompressedBytes,
+			Cached:            l.Cached,
+		}
+		if cfg.timings {
+			d := int64(l.Duration)
+			ld.Duration = &d
+		}
+		doc.Layers = append(doc.Layers, ld)
+	}
+
+	if cfg.timings {
+		fetch, build, total := int64(m.FetchDuration), int64(m.BuildDuration), int64(m.TotalDuration)
+		doc.FetchDuration, doc.BuildDuration, doc.TotalDuration = &fetch, &build, &total
+	}
+
+	return doc
+}
+
+func (d ImageDocument) image() (*analyser.Image, error) {
+	img := &analyser.Image{
+		Reference: d.Reference,
+		Digest:    d.Digest,
+		MediaType: d.MediaType,
+		Size:      d.ManifestSize,
+
+		ResolvedDigest: d.ResolvedDigest,
+		Platform:       d.Platform,
+		Layers:         make([]analyser.Layer, len(d.Layers)),
+
+		MetadataOnly: d.MetadataOnly,
+		Base:         d.Base.base(),
+		Entrypoint:   d.Entrypoint,
+		Cmd:          d.Cmd,
+	}
+
+	for i, l := range d.Layers {
+		img.Layers[i] = l.layer()
+		if l.FileCount > len(l.Files) {
+			img.ListingsOmitted = true
+		}
+	}
+
+	if d.LoadedAt != "" {
+		t, err := time.Parse(time.RFC3339Nano, d.LoadedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loadedAt: %w", err)
+		}
+		img.LoadedAt = t
+	}
+
+	return img, nil
+}
+
+func (d LayerDocument) layer() analyser.Layer {
+	l := analyser.Layer{
+		Index:            d.Index,
+		Digest:           d.Digest,
+		DiffID:           d.DiffID,
+		MediaType:        d.MediaType,
+		CompressedSize:   d.CompressedSize,
+		UncompressedSize: d.UncompressedSize,
+		Compression:      d.Compression,
+
+		InterpretersUnknown: d.InterpretersUnknown,
+	}
+
+	for _, f := range d.Files {
+		l.Files = append(l.Files, analyser.FileEntry{
+			Path:     f.Path,
+			Type:     analyser.FileType(f.Type),
+			Size:     f.Size,
+			Mode:     f.Mode,
+			Linkname: f.Linkname,
+			Whiteout: f.Whiteout,
+			Opaque:   f.Opaque,
+			Digest:   f.Digest,
+
+			Interpreter: f.Interpreter,
+		})
+	}
+
+	for _, p := range d.Packages {
+		l.Packages = append(l.Packages, analyser.Package{
+			Name:    p.Name,
+			Version: p.Version,
+			Manager: p.Manager,
+			Source:  p.Source,
+			Files:   p.Files,
+		})
+	}
+
+	for _, e := range d.Estimates {
+		l.Estimates = append(l.Estimates, analyser.CompressionEstimate{
+			Format: e.Format,
+			Level:  e.Level,
+			Size:   e.Size,
+		})
+	}
+
+	return l
+}
+
+func (d MetricsDocument) metrics() analyser.Metrics {
+	m := analyser.Metrics{
+		FetchAttempts: d.FetchAttempts,
+		DigestPinned:  d.DigestPinned,
+		Success:       d.Success,
+		Cached:        d.Cached,
+		FetchSkipped:  d.FetchSkipped,
+		Endpoint:      d.Endpoint,
+
+		CompressedBytes:   d.CompressedBytes,
+		UncompressedBytes: d.UncompressedBytes,
+	}
+	for _, l := range d.Layers {
+		lm := analyser.LayerMetrics{
+			Index:             l.Index,
+			Digest:            l.Digest,
+			CompressedBytes:   l.CompressedBytes,
+			UncompressedBytes: l.UncompressedBytes,
+			Cached:            l.Cached,
+		}
+		if l.Duration != nil {
+			lm.Duration = time.Duration(*l.Duration)
+		}
+		m.Layers = append(m.Layers, lm)
+	}
+	if d.FetchDuration != nil {
+		m.FetchDuration = time.Duration(*d.FetchDuration)
+	}
+	if d.BuildDuration != nil {
+		m.BuildDuration = time.Duration(*d.BuildDuration)
+	}
+	if d.TotalDuration != nil {
+		m.TotalDuration = time.Duration(*d.TotalDuration)
+	}
+	return m
+}
@@ -3,49 +3,228 @@ package slimmer
 import (
 	"context"
 	"fmt"
+	"time"
 
 	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
 	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
 	planner "github.com/pnkcaht/image-slimmer-core/internal/planner"
 )
 
-type Engine struct{}
+// Engine runs the load, normalization and planning pipeline
+// An Engine is safe for concurrent use
+type Engine struct {
+	opts []Option
+}
 
-func New() *Engine {
-	return &Engine{}
+// New creates an Engine whose options apply to every call
+func New(opts ...Option) *Engine {
+	return &Engine{
+		opts: append([]Option(nil), opts...),
+	}
 }
 
+// Result is the outcome of running the pipeline on a single image
+//
+// In metadata-only mode Deterministic and Plan are nil
 type Result struct {
-	Image         *analyser.Image
-	Metrics       analyser.Metrics
-	Deterministic *planner.DeterministicImage
-	Plan          *digest.ImagePlan
+	Image         *Image
+	Metrics       Metrics
+	Deterministic *DeterministicImage
+	Plan          *ImagePlan
+
+	// Status tells whether the image was analyzed or found unchanged
+	Status Status
 }
 
-func (e *Engine) Slim(ctx context.Context, ref string) (*Result, error) {
+// Status describes how a Result was obtained
+type Status string
+
+const (
+	// StatusAnalyzed means the image was loaded and analyzed (possibly from cache)
+	StatusAnalyzed Status = "analyzed"
+
+	// StatusUnchanged means the reference still resolves to the digest of the
+	// previous result, which is returned without fetching the image
+	StatusUnchanged Status = "unchanged"
+)
+
+// config resolves engine options followed by per-call overrides
+func (e *Engine) config(overrides ...Option) *config {
+	all := make([]Option, 0, len(e.opts)+len(overrides))
+	all = append(all, e.opts...)
+	all = append(all, overrides...)
+	return newConfig(all...)
+}
+
+// Slim loads ref and runs the normalization and planning stages on it
+// opts override the engine options for this call only
+func (e *Engine) Slim(ctx context.Context, ref string, opts ...Option) (*Result, error) {
+	cfg := e.config(opts...)
+
+	start := time.Now()
+
+	// ---- RESOLVE PHASE ----
+
+	// A HEAD request is enough to detect unchanged images; it is only
+	// worth its round trip when there is something to compare against
+	var resolved string
+	if cfg.previous != nil || cfg.cache != nil {
+		// Failures fall through to the full load, which reports them
+		resolved, _ = analyser.Resolve(ctx, ref, cfg.load...)
+	}
+
+	if unchanged(cfg.previous, resolved, cfg) {
+		out := withReference(cfg.previous, ref)
+		out.Status = StatusUnchanged
+		out.Metrics = Metrics{
+			FetchDuration: time.Since(start),
+			TotalDuration: time.Since(start),
+			DigestPinned:  analyser.IsDigestPinned(ref),
+			Success:       true,
+			FetchSkipped:  true,
+		}
+		return out, nil
+	}
+
+	// Serve images analyzed before from the result cache
+	key, cacheable := resultKey(resolved, cfg)
+	if cacheable {
+		if img, ok := loadResult(cfg.cache, key, ref); ok {
+			return analyze(img, cfg, Metrics{
+				FetchDuration: time.Since(start),
+				TotalDuration: time.Since(start),
+				DigestPinned:  analyser.IsDigestPinned(ref),
+				Success:       true,
+				Cached:        true,
+			})
+		}
+	}
 
-	// Load & analyze image
-	img, metrics, err := analyser.Load(ctx, ref)
+	// ---- LOAD & ANALYZE ----
+
+	img, metrics, err := analyser.Load(ctx, ref, cfg.load...)
 	if err != nil {
 		return nil, fmt.Errorf("load failed: %w", err)
 	}
 
+	res, err := analyze(img, cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tag may have moved since it was resolved; store the result
+	// under the digest that was actually loaded
+	if key, ok := resultKey(img.ResolvedDigest, cfg); ok {
+		storeResult(cfg.cache, key, res)
+	}
+
+	return res, nil
+}
+
+// unchanged reports whether prev was produced for the image ref now resolves to
+// prev must have been produced with the same platform as the current call
+func unchanged(prev *Result, resolved string, cfg *config) bool {
+	if prev == nil || prev.Image == nil || resolved == "" {
+		return false
+	}
+	if prev.Image.MetadataOnly != cfg.metadataOnly {
+		return false
+	}
+	// Its files, packages and wasted bytes would be reported as empty
+	if prev.Image.ListingsOmitted {
+		return false
+	}
+	if cfg.compression && !hasEstimates(prev.Image) {
+		return false
+	}
+
+	// Results without a resolved digest predate it; for single-platform
+	// images the manifest digest is the same value
+	d := prev.Image.ResolvedDigest
+	if d == "" {
+		d = prev.Image.Digest
+	}
+	if d != resolved {
+		return false
+	}
+
+	// An index resolves to a different manifest per platform
+	return resolved == prev.Image.Digest || prev.Image.Platform == requestedPlatform(cfg)
+}
+
+// requestedPlatform is the platform the call resolves from indexes
+func requestedPlatform(cfg *config) string {
+	if cfg.platform == "" {
+		return analyser.DefaultPlatform
+	}
+	return cfg.platform
+}
+
+// hasEstimates reports whether img was loaded with compression estimates
+func hasEstimates(img *Image) bool {
+	for _, l := range img.Layers {
+		if len(l.Estimates) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze runs the normalization and planning stages on an already loaded image
+// Images loaded in metadata-only mode have no layers to plan and are
+// returned without Deterministic and Plan
+func Analyze(img *Image, metrics Metrics) (*Result, error) {
+	return analyze(img, newConfig(), metrics)
+}
+
+// analyze implements Analyze, reporting stages to the configured observer
+func analyze(img *Image, cfg *config, metrics Metrics) (*Result, error) {
+	if img != nil && img.MetadataOnly {
+		return &Result{
+			Image:   img,
+			Metrics: metrics,
+			Status:  StatusAnalyzed,
+		}, nil
+	}
+
+	var ref string
+	if img != nil {
+		ref = img.Reference
+	}
+
+	img = withBase(img, cfg.catalog)
+
 	// Normalize deterministically
+	endStage := cfg.stage(ref, StageDeterministic)
 	det, err := planner.NewDeterministicImage(img)
+	endStage(err)
 	if err != nil {
 		return nil, fmt.Errorf("deterministic normalization failed: %w", err)
 	}
 
 	// Create slimming plan
+	endStage = cfg.stage(ref, StagePlan)
 	plan, err := digest.NewImagePlan(img)
+	endStage(err)
 	if err != nil {
 		return nil, fmt.Errorf("plan creation failed: %w", err)
 	}
 
+	// Recommendations need the current base; without one there is
+	// nothing to compare the catalog against
+	if cfg.catalog != nil && img.Base != nil && img.Base.Layers > 0 {
+		plan.Recommendations, _ = digest.RecommendBases(img, cfg.catalog)
+	}
+	plan.Layout = digest.RecommendLayout(img)
+	plan.Compression = digest.RecommendCompression(img)
+
+	cfg.emit(Event{Type: EventPlanBuilt, Reference: ref, Digest: plan.Digest, Layer: -1})
+
 	return &Result{
 		Image:         img,
 		Metrics:       metrics,
 		Deterministic: det,
 		Plan:          plan,
+		Status:        StatusAnalyzed,
 	}, nil
 }
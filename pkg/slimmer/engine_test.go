@@ -0,0 +1,60 @@
+package slimmer
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// decodeResult round-trips r through its document
+func decodeResult(t *testing.T, r *Result, opts ...EncodeOption) *Result {
+	t.Helper()
+
+	data, err := json.Marshal(r.Document(opts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Result
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestUnchanged(t *testing.T) {
+	img := policyImage()
+	img.ResolvedDigest = "sha256:index"
+	img.Platform = "linux/amd64"
+	prev := &Result{Image: img}
+
+	metadataOnly := &Image{Digest: "sha256:app", MetadataOnly: true}
+
+	tests := []struct {
+		name     string
+		prev     *Result
+		resolved string
+		opts     []Option
+		want     bool
+	}{
+		{name: "no previous", resolved: "sha256:index"},
+		{name: "unresolved", prev: prev},
+		{name: "same index and platform", prev: prev, resolved: "sha256:index", want: true},
+		{name: "moved tag", prev: prev, resolved: "sha256:other"},
+		{name: "other platform", prev: prev, resolved: "sha256:index", opts: []Option{WithPlatform(v1.Platform{OS: "linux", Architecture: "arm64"})}},
+		{name: "metadata-only call", prev: prev, resolved: "sha256:index", opts: []Option{WithMetadataOnly(true)}},
+		{name: "estimates requested", prev: prev, resolved: "sha256:index", opts: []Option{WithCompressionEstimates(true)}},
+		{name: "decoded with files", prev: decodeResult(t, prev, WithFiles()), resolved: "sha256:index", want: true},
+		{name: "decoded without files", prev: decodeResult(t, prev), resolved: "sha256:index"},
+		{name: "metadata-only previous", prev: &Result{Image: metadataOnly}, resolved: "sha256:app", opts: []Option{WithMetadataOnly(true)}, want: true},
+		{name: "decoded metadata-only previous", prev: decodeResult(t, &Result{Image: metadataOnly}), resolved: "sha256:app", opts: []Option{WithMetadataOnly(true)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unchanged(tt.prev, tt.resolved, newConfig(tt.opts...)); got != tt.want {
+				t.Errorf("unchanged = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
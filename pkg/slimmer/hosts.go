@@ -0,0 +1,38 @@
+package slimmer
+
+import (
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/registries"
+)
+
+type (
+	// RegistryHosts holds per-registry CA bundles, client certificates,
+	// insecure and plain-HTTP hosts and the proxy
+	RegistryHosts = registries.Hosts
+
+	// RegistryHost is the connection settings of a single registry host
+	RegistryHost = registries.Host
+)
+
+// NewRegistryHosts creates an empty set of host settings
+func NewRegistryHosts() *RegistryHosts {
+	return registries.NewHosts()
+}
+
+// LoadCertsDir reads a docker-style certs.d directory: one subdirectory
+// per registry host holding CA certificates (*.crt) and client certificate
+// pairs (*.cert and *.key). Failures are reported with CodeTLSConfig
+func LoadCertsDir(dir string) (*RegistryHosts, error) {
+	h, err := registries.LoadCertsDir(dir)
+	if err != nil {
+		return nil, analyser.NewError(CodeTLSConfig, "tls", "", "invalid certs.d directory", err)
+	}
+	return h, nil
+}
+
+// WithRegistryHosts applies per-registry TLS, plain-HTTP and proxy settings
+// Certificate problems are reported with the TLS error codes; a transport
+// set with WithTransport must be an *http.Transport
+func WithRegistryHosts(h *RegistryHosts) Option {
+	return loadOption(analyser.WithHosts(h))
+}
@@ -0,0 +1,49 @@
+package slimmer
+
+import (
+	"context"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+)
+
+// LayoutRecommendation is a layer merge or reorder that improves registry
+// dedup and pull caching
+type LayoutRecommendation = digest.LayoutRecommendation
+
+// Layout recommendation kinds, for LayoutRecommendation.Kind
+const (
+	LayoutSquash  = digest.LayoutSquash
+	LayoutReorder = digest.LayoutReorder
+)
+
+// RecommendLayout recommends layer merges and reorders for the last of
+// results, using the others as its history, oldest first
+//
+// Plans already list merges for the image alone; reorders need history,
+// such as the results of previous tags, to tell which layers change from
+// release to release. Every result needs its file listing
+func RecommendLayout(results ...*Result) ([]LayoutRecommendation, error) {
+	history := make([]*analyser.Image, len(results))
+	for i, r := range results {
+		if err := diffable(r); err != nil {
+			return nil, err
+		}
+		history[i] = r.Image
+	}
+	return digest.RecommendLayout(history...), nil
+}
+
+// Layout analyzes refs, oldest first, and recommends layer merges and
+// reorders for the last one
+func (e *Engine) Layout(ctx context.Context, refs []string, opts ...Option) ([]LayoutRecommendation, error) {
+	results := make([]*Result, len(refs))
+	for i, ref := range refs {
+		r, err := e.Slim(ctx, ref, opts...)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = r
+	}
+	return RecommendLayout(results...)
+}
@@ -0,0 +1,45 @@
+package slimmer
+
+import (
+	"io"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/registries"
+)
+
+type (
+	// RegistriesConfig lists the upstream registries that have mirrors
+	RegistriesConfig = registries.Config
+
+	// RegistryConfig is an upstream registry or repository namespace
+	RegistryConfig = registries.Registry
+
+	// MirrorConfig is an alternative location serving the same content
+	MirrorConfig = registries.Mirror
+)
+
+// Mirror pull policies for MirrorConfig.PullFromMirror
+const (
+	MirrorPullAll        = registries.PullAll
+	MirrorPullDigestOnly = registries.PullDigestOnly
+	MirrorPullTagOnly    = registries.PullTagOnly
+)
+
+// LoadRegistriesConfig reads a mirror configuration file in the
+// containers-registries.conf(5) format
+func LoadRegistriesConfig(path string) (*RegistriesConfig, error) {
+	return registries.Load(path)
+}
+
+// ParseRegistriesConfig parses a mirror configuration in the
+// containers-registries.conf(5) format
+func ParseRegistriesConfig(r io.Reader) (*RegistriesConfig, error) {
+	return registries.Parse(r)
+}
+
+// WithMirrors pulls through the mirrors of c before falling back to the
+// registry of the reference. Metrics.Endpoint records which one served the
+// image; digest-pinned references are verified whichever endpoint answered
+func WithMirrors(c *RegistriesConfig) Option {
+	return loadOption(analyser.WithMirrors(c))
+}
@@ -0,0 +1,240 @@
+package slimmer
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	"github.com/pnkcaht/image-slimmer-core/internal/cache"
+	"github.com/pnkcaht/image-slimmer-core/internal/catalog"
+)
+
+// Option defines a functional configuration modifier for loading and analysis
+type Option func(*config)
+
+// config holds the resolved configuration
+// It is intentionally unexported to enforce controlled construction
+type config struct {
+	load []analyser.Option
+
+	// result cache settings; platform, metadataOnly and compression are
+	// part of the key
+	cache        cache.Cache
+	platform     string
+	metadataOnly bool
+	compression  bool
+
+	// previous result used to detect unchanged images
+	previous *Result
+
+	// observer receives planning stage events
+	observer Observer
+
+	// breaker shares registry health across calls; SlimBatch installs
+	// one for the batch when unset
+	breaker *CircuitBreaker
+
+	// catalog attributes layers to known base images
+	catalog *catalog.Catalog
+
+	// batch settings
+	concurrency         int
+	registryConcurrency int
+}
+
+// newConfig applies opts in order; later options win
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		concurrency: 4,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+// loadOption adapts an analyser option
+func loadOption(o analyser.Option) Option {
+	return func(c *config) {
+		c.load = append(c.load, o)
+	}
+}
+
+// WithTimeout configures the maximum allowed duration
+// for registry communication operations
+func WithTimeout(d time.Duration) Option {
+	return loadOption(analyser.WithTimeout(d))
+}
+
+// WithRetries configures how many retry attempts
+// are allowed for transient registry failures
+func WithRetries(n int) Option {
+	return loadOption(analyser.WithRetries(n))
+}
+
+// WithBackoff configures the base delay between retry attempts
+func WithBackoff(d time.Duration) Option {
+	return loadOption(analyser.WithBackoff(d))
+}
+
+// WithKeychain configures the credential resolution chain used for registry authentication
+func WithKeychain(k authn.Keychain) Option {
+	return loadOption(analyser.WithKeychain(k))
+}
+
+// WithTransport configures a custom HTTP transport
+func WithTransport(t http.RoundTripper) Option {
+	return loadOption(analyser.WithTransport(t))
+}
+
+// WithMetricsHook registers a callback invoked after every fetch attempt,
+// including failed ones
+func WithMetricsHook(h func(FetchMetrics)) Option {
+	return loadOption(analyser.WithMetricsHook(h))
+}
+
+// WithMetadataOnly skips layer extraction and returns only
+// high-level image metadata (digest, size, media type)
+func WithMetadataOnly(enabled bool) Option {
+	load := loadOption(analyser.WithMetadataOnly(enabled))
+	return func(c *config) {
+		load(c)
+		c.metadataOnly = enabled
+	}
+}
+
+// WithCompressionEstimates estimates the size of every layer under gzip,
+// zstd and eStargz while it is analyzed, so plans can recommend a layer
+// media type change. It costs CPU time proportional to the image size
+func WithCompressionEstimates(enabled bool) Option {
+	load := loadOption(analyser.WithCompressionEstimates(enabled))
+	return func(c *config) {
+		load(c)
+		c.compression = enabled
+	}
+}
+
+// WithPlatform selects the platform to resolve from multi-platform images
+func WithPlatform(p v1.Platform) Option {
+	load := loadOption(analyser.WithPlatform(p))
+	return func(c *config) {
+		load(c)
+		if p.OS != "" && p.Architecture != "" {
+			c.platform = p.String()
+		}
+	}
+}
+
+// WithCache stores layer blobs and per-layer analysis by layer digest, and
+// whole results by image digest, so unchanged images and shared layers are
+// not downloaded or analyzed again
+func WithCache(c Cache) Option {
+	load := loadOption(analyser.WithCache(c))
+	return func(cfg *config) {
+		load(cfg)
+		cfg.cache = c
+	}
+}
+
+// WithParallelism configures how many layers of an image are downloaded
+// and analyzed concurrently
+func WithParallelism(n int) Option {
+	return loadOption(analyser.WithParallelism(n))
+}
+
+// WithMemoryBudget bounds the combined compressed size of layers being
+// analyzed at once. Zero means no budget
+func WithMemoryBudget(bytes int64) Option {
+	return loadOption(analyser.WithMemoryBudget(bytes))
+}
+
+// WithObserver registers an observer receiving progress events while an
+// image is resolved, downloaded, analyzed and planned
+// Resolve events come from the fetch, so results returned unchanged or
+// from the result cache have none
+// The observer must be safe for concurrent use
+func WithObserver(o Observer) Option {
+	load := loadOption(analyser.WithObserver(o))
+	return func(c *config) {
+		load(c)
+		c.observer = o
+	}
+}
+
+// WithCircuitBreaker shares registry health and retry budgets across calls
+// Calls to a registry whose circuit is open fail fast with CodeCircuitOpen.
+// Pass the same breaker to every engine or call that should share it
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	load := loadOption(analyser.WithCircuitBreaker(b))
+	return func(c *config) {
+		load(c)
+		c.breaker = b
+	}
+}
+
+// WithPrevious supplies the result of an earlier run for the same reference
+// If the reference still resolves to the same digest, Slim returns prev with
+// StatusUnchanged after a single HEAD request instead of fetching the image
+// Results decoded from documents encoded without WithFiles cannot stand in
+// for a new analysis and are ignored
+func WithPrevious(prev *Result) Option {
+	return func(c *config) {
+		c.previous = prev
+	}
+}
+
+// WithConcurrency configures how many references SlimBatch
+// analyzes in parallel
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithRegistryConcurrency limits how many references of the same registry
+// SlimBatch analyzes in parallel. Zero means no per-registry limit
+func WithRegistryConcurrency(n int) Option {
+	return func(c *config) {
+		if n >= 0 {
+			c.registryConcurrency = n
+		}
+	}
+}
+
+// Load resolves and builds a container image from a remote reference
+// without running the planning stages
+func Load(ctx context.Context, ref string, opts ...Option) (*Image, Metrics, error) {
+	return analyser.Load(ctx, ref, newConfig(opts...).load...)
+}
+
+// Open resolves a remote image for streaming its layers
+// The returned LayerSource must be closed
+func Open(ctx context.Context, ref string, opts ...Option) (*LayerSource, error) {
+	return analyser.Open(ctx, ref, newConfig(opts...).load...)
+}
+
+// emit delivers e to the configured observer, if any
+func (c *config) emit(e Event) {
+	if c.observer == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	c.observer.Observe(e)
+}
+
+// stage emits the start of stage and returns a func emitting its end
+func (c *config) stage(ref, stage string) func(error) {
+	c.emit(Event{Type: EventStageStarted, Reference: ref, Layer: -1, Stage: stage})
+	return func(err error) {
+		c.emit(Event{Type: EventStageFinished, Reference: ref, Layer: -1, Stage: stage, Err: err})
+	}
+}
@@ -0,0 +1,159 @@
+package slimmer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PlanDiff is the difference between two plans, typically built for the
+// same tag at different times, from the older to the newer
+//
+// Layers are matched by index, so a rebuilt layer shows as a digest change
+type PlanDiff struct {
+	From PlanDiffSide `json:"from"`
+	To   PlanDiffSide `json:"to"`
+
+	// DigestChanged reports that the plans were built for different images
+	DigestChanged bool `json:"digestChanged"`
+
+	SavingsBefore int64 `json:"savingsBefore"`
+	SavingsAfter  int64 `json:"savingsAfter"`
+	SavingsDelta  int64 `json:"savingsDelta"`
+
+	// Layers lists the layers whose digest, action, risk or size changed,
+	// sorted by index
+	Layers []LayerPlanChange `json:"layers"`
+}
+
+// PlanDiffSide identifies one side of a PlanDiff
+type PlanDiffSide struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+}
+
+// LayerPlanChange is a layer whose plan differs between two plans
+// Before fields are empty for added layers and after fields for removed ones
+type LayerPlanChange struct {
+	Index        int       `json:"index"`
+	Change       Change    `json:"change"`
+	DigestBefore string    `json:"digestBefore,omitempty"`
+	DigestAfter  string    `json:"digestAfter,omitempty"`
+	ActionBefore string    `json:"actionBefore,omitempty"`
+	ActionAfter  string    `json:"actionAfter,omitempty"`
+	RiskBefore   RiskLevel `json:"riskBefore,omitempty"`
+	RiskAfter    RiskLevel `json:"riskAfter,omitempty"`
+	SizeBefore   int64     `json:"sizeBefore"`
+	SizeAfter    int64     `json:"sizeAfter"`
+}
+
+// DiffPlans compares two plans, from the older to the newer
+func DiffPlans(from, to *ImagePlan) (*PlanDiff, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("plan is nil")
+	}
+
+	d := &PlanDiff{
+		From:          PlanDiffSide{Reference: from.Reference, Digest: from.Digest},
+		To:            PlanDiffSide{Reference: to.Reference, Digest: to.Digest},
+		DigestChanged: from.Digest != to.Digest,
+		SavingsBefore: from.EstimatedSavings(),
+		SavingsAfter:  to.EstimatedSavings(),
+		Layers:        []LayerPlanChange{},
+	}
+	d.SavingsDelta = d.SavingsAfter - d.SavingsBefore
+
+	before := make(map[int]LayerPlan, len(from.Layers))
+	for _, l := range from.Layers {
+		before[l.Index] = l
+	}
+	after := make(map[int]LayerPlan, len(to.Layers))
+	for _, l := range to.Layers {
+		after[l.Index] = l
+	}
+
+	for _, b := range to.Layers {
+		a, ok := before[b.Index]
+		switch {
+		case !ok:
+			d.Layers = append(d.Layers, LayerPlanChange{
+				Index:       b.Index,
+				Change:      ChangeAdded,
+				DigestAfter: b.Digest,
+				ActionAfter: b.Action,
+				RiskAfter:   b.Risk,
+				SizeAfter:   b.Size,
+			})
+		case a.Digest != b.Digest || a.Action != b.Action || a.Risk != b.Risk || a.Size != b.Size:
+			d.Layers = append(d.Layers, LayerPlanChange{
+				Index:        b.Index,
+				Change:       ChangeModified,
+				DigestBefore: a.Digest,
+				DigestAfter:  b.Digest,
+				ActionBefore: a.Action,
+				ActionAfter:  b.Action,
+				RiskBefore:   a.Risk,
+				RiskAfter:    b.Risk,
+				SizeBefore:   a.Size,
+				SizeAfter:    b.Size,
+			})
+		}
+	}
+	for _, a := range from.Layers {
+		if _, ok := after[a.Index]; !ok {
+			d.Layers = append(d.Layers, LayerPlanChange{
+				Index:        a.Index,
+				Change:       ChangeRemoved,
+				DigestBefore: a.Digest,
+				ActionBefore: a.Action,
+				RiskBefore:   a.Risk,
+				SizeBefore:   a.Size,
+			})
+		}
+	}
+
+	sort.Slice(d.Layers, func(a, b int) bool {
+		return d.Layers[a].Index < d.Layers[b].Index
+	})
+
+	return d, nil
+}
+
+// Summary renders the plan diff as human-readable text
+func (d *PlanDiff) Summary() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Plan diff %s -> %s\n", d.From.Reference, d.To.Reference))
+	if d.DigestChanged {
+		sb.WriteString(fmt.Sprintf("- Digest: %s -> %s\n", d.From.Digest, d.To.Digest))
+	} else {
+		sb.WriteString(fmt.Sprintf("- Digest: %s (unchanged)\n", d.To.Digest))
+	}
+	sb.WriteString(fmt.Sprintf("- Estimated savings: %d -> %d bytes (%+d)\n", d.SavingsBefore, d.SavingsAfter, d.SavingsDelta))
+
+	for _, l := range d.Layers {
+		switch l.Change {
+		case ChangeAdded:
+			sb.WriteString(fmt.Sprintf("+ layer %d %s: %s, %s risk, %d bytes\n", l.Index, l.DigestAfter, l.ActionAfter, l.RiskAfter, l.SizeAfter))
+		case ChangeRemoved:
+			sb.WriteString(fmt.Sprintf("- layer %d %s: %s, %s risk, %d bytes\n", l.Index, l.DigestBefore, l.ActionBefore, l.RiskBefore, l.SizeBefore))
+		default:
+			var parts []string
+			if l.DigestBefore != l.DigestAfter {
+				parts = append(parts, fmt.Sprintf("digest %s -> %s", l.DigestBefore, l.DigestAfter))
+			}
+			if l.ActionBefore != l.ActionAfter {
+				parts = append(parts, fmt.Sprintf("action %s -> %s", l.ActionBefore, l.ActionAfter))
+			}
+			if l.RiskBefore != l.RiskAfter {
+				parts = append(parts, fmt.Sprintf("risk %s -> %s", l.RiskBefore, l.RiskAfter))
+			}
+			if l.SizeBefore != l.SizeAfter {
+				parts = append(parts, fmt.Sprintf("size %d -> %d bytes", l.SizeBefore, l.SizeAfter))
+			}
+			sb.WriteString(fmt.Sprintf("~ layer %d: %s\n", l.Index, strings.Join(parts, ", ")))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,147 @@
+package slimmer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffPlans(t *testing.T) {
+	keep := LayerPlan{Index: 0, Digest: "sha256:base", Action: ActionKeep, Risk: RiskHigh, Size: 6000}
+	docs := LayerPlan{Index: 1, Digest: "sha256:docs", Action: ActionRemove, Risk: RiskLow, Size: 500}
+	app := LayerPlan{Index: 2, Digest: "sha256:app", Action: ActionKeep, Risk: RiskMedium, Size: 4000}
+
+	plan := func(digest string, layers ...LayerPlan) *ImagePlan {
+		return &ImagePlan{Reference: "example.com/app:1", Digest: digest, Layers: layers}
+	}
+
+	tests := []struct {
+		name          string
+		from, to      *ImagePlan
+		digestChanged bool
+		savings       [2]int64
+		want          []LayerPlanChange
+	}{
+		{
+			name:    "same plan",
+			from:    plan("sha256:a", keep, docs, app),
+			to:      plan("sha256:a", keep, docs, app),
+			savings: [2]int64{500, 500},
+			want:    []LayerPlanChange{},
+		},
+		{
+			name:          "added layer",
+			from:          plan("sha256:a", keep, docs),
+			to:            plan("sha256:b", keep, docs, app),
+			digestChanged: true,
+			savings:       [2]int64{500, 500},
+			want: []LayerPlanChange{
+				{Index: 2, Change: ChangeAdded, DigestAfter: "sha256:app", ActionAfter: ActionKeep, RiskAfter: RiskMedium, SizeAfter: 4000},
+			},
+		},
+		{
+			name:          "removed layer",
+			from:          plan("sha256:a", keep, docs, app),
+			to:            plan("sha256:b", keep, docs),
+			digestChanged: true,
+			savings:       [2]int64{500, 500},
+			want: []LayerPlanChange{
+				{Index: 2, Change: ChangeRemoved, DigestBefore: "sha256:app", ActionBefore: ActionKeep, RiskBefore: RiskMedium, SizeBefore: 4000},
+			},
+		},
+		{
+			name:          "rebuilt layer",
+			from:          plan("sha256:a", keep, docs, app),
+			to:            plan("sha256:b", keep, docs, LayerPlan{Index: 2, Digest: "sha256:app2", Action: ActionKeep, Risk: RiskMedium, Size: 4200}),
+			digestChanged: true,
+			savings:       [2]int64{500, 500},
+			want: []LayerPlanChange{{
+				Index: 2, Change: ChangeModified,
+				DigestBefore: "sha256:app", DigestAfter: "sha256:app2",
+				ActionBefore: ActionKeep, ActionAfter: ActionKeep,
+				RiskBefore: RiskMedium, RiskAfter: RiskMedium,
+				SizeBefore: 4000, SizeAfter: 4200,
+			}},
+		},
+		{
+			name:    "action and risk change",
+			from:    plan("sha256:a", keep, docs),
+			to:      plan("sha256:a", keep, LayerPlan{Index: 1, Digest: "sha256:docs", Action: ActionKeep, Risk: RiskMedium, Size: 500}),
+			savings: [2]int64{500, 0},
+			want: []LayerPlanChange{{
+				Index: 1, Change: ChangeModified,
+				DigestBefore: "sha256:docs", DigestAfter: "sha256:docs",
+				ActionBefore: ActionRemove, ActionAfter: ActionKeep,
+				RiskBefore: RiskLow, RiskAfter: RiskMedium,
+				SizeBefore: 500, SizeAfter: 500,
+			}},
+		},
+		{
+			name:          "shrunk image",
+			from:          plan("sha256:a", keep, docs, app),
+			to:            plan("sha256:b", keep),
+			digestChanged: true,
+			savings:       [2]int64{500, 0},
+			want: []LayerPlanChange{
+				{Index: 1, Change: ChangeRemoved, DigestBefore: "sha256:docs", ActionBefore: ActionRemove, RiskBefore: RiskLow, SizeBefore: 500},
+				{Index: 2, Change: ChangeRemoved, DigestBefore: "sha256:app", ActionBefore: ActionKeep, RiskBefore: RiskMedium, SizeBefore: 4000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := DiffPlans(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.DigestChanged != tt.digestChanged {
+				t.Errorf("DigestChanged = %t, want %t", d.DigestChanged, tt.digestChanged)
+			}
+			if d.SavingsBefore != tt.savings[0] || d.SavingsAfter != tt.savings[1] || d.SavingsDelta != tt.savings[1]-tt.savings[0] {
+				t.Errorf("savings = %d -> %d (%+d), want %d -> %d", d.SavingsBefore, d.SavingsAfter, d.SavingsDelta, tt.savings[0], tt.savings[1])
+			}
+			if !reflect.DeepEqual(d.Layers, tt.want) {
+				t.Errorf("layers = %+v, want %+v", d.Layers, tt.want)
+			}
+
+			// Every change is rendered
+			if lines := strings.Count(d.Summary(), "\n"); lines != 3+len(tt.want) {
+				t.Errorf("summary has %d lines, want %d:\n%s", lines, 3+len(tt.want), d.Summary())
+			}
+		})
+	}
+
+	if _, err := DiffPlans(nil, plan("sha256:a")); err == nil {
+		t.Error("DiffPlans accepted a nil plan")
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	tests := []struct {
+		name   string
+		digest string
+		image  string
+		drift  bool
+	}{
+		{name: "same image", digest: "sha256:a", image: "sha256:a"},
+		{name: "image changed", digest: "sha256:a", image: "sha256:b", drift: true},
+		{name: "plan without digest", image: "sha256:a", drift: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&ImagePlan{Reference: "example.com/app:1", Digest: tt.digest}).CheckDrift(tt.image)
+			if !tt.drift {
+				if err != nil {
+					t.Fatalf("CheckDrift = %v", err)
+				}
+				return
+			}
+			ae, ok := AsAnalyzerError(err)
+			if !ok || ae.Code() != CodePlanDrift {
+				t.Errorf("CheckDrift = %v, want %s", err, CodePlanDrift)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package slimmer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Policy describes the budgets and restrictions a Result must satisfy
+// Zero values disable the corresponding check
+type Policy struct {
+	// MaxImageSize is the maximum uncompressed image size in bytes
+	MaxImageSize int64
+
+	// MaxLayers is the maximum number of layers
+	MaxLayers int
+
+	// MaxWastedBytes is the maximum number of bytes stored for files
+	// that are overwritten or deleted by later layers
+	MaxWastedBytes int64
+
+	// ForbiddenPaths are path.Match patterns; a pattern matching a directory
+	// forbids everything below it. Leading "/" is optional
+	ForbiddenPaths []string
+
+	// ForbiddenPackages are path.Match patterns matched against package names
+	ForbiddenPackages []string
+}
+
+// PolicyRule identifies which budget a Violation breached
+type PolicyRule string
+
+const (
+	RuleMaxImageSize     PolicyRule = "max-image-size"
+	RuleMaxLayers        PolicyRule = "max-layers"
+	RuleMaxWastedBytes   PolicyRule = "max-wasted-bytes"
+	RuleForbiddenPath    PolicyRule = "forbidden-path"
+	RuleForbiddenPackage PolicyRule = "forbidden-package"
+)
+
+// Violation describes a single failed policy check
+type Violation struct {
+	Rule    PolicyRule
+	Limit   string
+	Actual  string
+	Message string
+}
+
+// Verdict is the structured pass/fail outcome of evaluating a Policy
+type Verdict struct {
+	Reference  string
+	Digest     string
+	Passed     bool
+	Violations []Violation
+}
+
+// Evaluate checks a Result against the policy
+// Violations are reported in a stable order: budgets first, then forbidden
+// paths and packages sorted by pattern and match
+//
+// Results without file listings, from metadata-only loads or documents
+// encoded without WithFiles, are refused: their budgets would pass unchecked
+func (p *Policy) Evaluate(r *Result) (*Verdict, error) {
+	if p == nil {
+		return nil, fmt.Errorf("policy is nil")
+	}
+	if r == nil || r.Image == nil {
+		return nil, fmt.Errorf("result has no image")
+	}
+
+	img := r.Image
+	if img.MetadataOnly {
+		return nil, fmt.Errorf("result of %s is metadata-only and cannot be checked", img.Reference)
+	}
+	if img.ListingsOmitted {
+		return nil, fmt.Errorf("result of %s has no file listings and cannot be checked", img.Reference)
+	}
+
+	v := &Verdict{
+		Reference: img.Reference,
+		Digest:    img.Digest,
+	}
+
+	if p.MaxImageSize > 0 {
+		if size := img.UncompressedSize(); size > p.MaxImageSize {
+			v.add(RuleMaxImageSize, p.MaxImageSize, size,
+				fmt.Sprintf("image size %d bytes exceeds budget of %d bytes", size, p.MaxImageSize))
+		}
+	}
+
+	if p.MaxLayers > 0 {
+		if n := len(img.Layers); n > p.MaxLayers {
+			v.add(RuleMaxLayers, p.MaxLayers, n,
+				fmt.Sprintf("image has %d layers, budget is %d", n, p.MaxLayers))
+		}
+	}
+
+	if p.MaxWastedBytes > 0 {
+		if wasted := img.WastedBytes(); wasted > p.MaxWastedBytes {
+			v.add(RuleMaxWastedBytes, p.MaxWastedBytes, wasted,
+				fmt.Sprintf("image wastes %d bytes on shadowed files, budget is %d bytes", wasted, p.MaxWastedBytes))
+		}
+	}
+
+	if len(p.ForbiddenPaths) > 0 {
+		files := img.Files()
+		for _, pattern := range sortedPatterns(p.ForbiddenPaths) {
+			for _, f := range files {
+				if matchPathPattern(pattern, f.Path) {
+					v.add(RuleForbiddenPath, pattern, "/"+f.Path,
+						fmt.Sprintf("forbidden path /%s matches %q", f.Path, pattern))
+				}
+			}
+		}
+	}
+
+	if len(p.ForbiddenPackages) > 0 {
+		pkgs := img.Packages()
+		for _, pattern := range sortedPatterns(p.ForbiddenPackages) {
+			for _, pkg := range pkgs {
+				if ok, _ := path.Match(pattern, pkg.Name); ok {
+					v.add(RuleForbiddenPackage, pattern, pkg.Name+"="+pkg.Version,
+						fmt.Sprintf("forbidden %s package %s %s matches %q", pkg.Manager, pkg.Name, pkg.Version, pattern))
+				}
+			}
+		}
+	}
+
+	v.Passed = len(v.Violations) == 0
+	return v, nil
+}
+
+// add appends a violation using string renderings of limit and actual values
+func (v *Verdict) add(rule PolicyRule, limit, actual any, message string) {
+	v.Violations = append(v.Violations, Violation{
+		Rule:    rule,
+		Limit:   fmt.Sprint(limit),
+		Actual:  fmt.Sprint(actual),
+		Message: message,
+	})
+}
+
+// Summary renders a human-readable verdict
+func (v *Verdict) Summary() string {
+	var sb strings.Builder
+
+	status := "PASS"
+	if !v.Passed {
+		status = "FAIL"
+	}
+
+	sb.WriteString(fmt.Sprintf("Policy %s for %s (digest=%s)\n", status, v.Reference, v.Digest))
+	for _, vi := range v.Violations {
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", vi.Rule, vi.Message))
+	}
+	return sb.String()
+}
+
+// matchPathPattern reports whether p or any of its parent directories
+// matches pattern. Both are compared without a leading "/"
+func matchPathPattern(pattern, p string) bool {
+	pattern = strings.TrimPrefix(path.Clean("/"+pattern), "/")
+
+	for cur := p; cur != "" && cur != "."; cur = path.Dir(cur) {
+		if ok, _ := path.Match(pattern, cur); ok {
+			return true
+		}
+		if !strings.Contains(cur, "/") {
+			break
+		}
+	}
+	return false
+}
+
+// sortedPatterns returns a sorted, de-duplicated copy of patterns
+func sortedPatterns(patterns []string) []string {
+	seen := make(map[string]struct{}, len(patterns))
+	out := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if _, ok := seen[p]; ok || p == "" {
+			continue
+		}
+		seen[p] = struct{}{}
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
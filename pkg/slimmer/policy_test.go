@@ -0,0 +1,166 @@
+package slimmer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// policyImage has a base layer with busybox and an apk database, and an
+// application layer that overwrites a file and adds a secret
+func policyImage() *Image {
+	return &Image{
+		Reference: "example.com/app:1",
+		Digest:    "sha256:app",
+		Layers: []Layer{
+			{
+				Index:            0,
+				UncompressedSize: 6000,
+				Files: []FileEntry{
+					{Path: "bin/busybox", Type: FileTypeRegular, Size: 1000},
+					{Path: "etc/motd", Type: FileTypeRegular, Size: 400},
+					{Path: "lib/apk/db/installed", Type: FileTypeRegular, Size: 100},
+				},
+				Packages: []Package{
+					{Name: "busybox", Version: "1.36", Manager: "apk", Source: "lib/apk/db/installed"},
+					{Name: "curl", Version: "8.5", Manager: "apk", Source: "lib/apk/db/installed"},
+				},
+			},
+			{
+				Index:            1,
+				UncompressedSize: 4000,
+				Files: []FileEntry{
+					{Path: "etc/motd", Type: FileTypeRegular, Size: 10},
+					{Path: "root/.ssh/id_rsa", Type: FileTypeRegular, Size: 3000},
+				},
+			},
+		},
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []Violation
+	}{
+		{name: "no budgets", policy: Policy{}},
+		{name: "size within budget", policy: Policy{MaxImageSize: 10000}},
+		{
+			name:   "size over budget",
+			policy: Policy{MaxImageSize: 9999},
+			want:   []Violation{{Rule: RuleMaxImageSize, Limit: "9999", Actual: "10000"}},
+		},
+		{name: "layers within budget", policy: Policy{MaxLayers: 2}},
+		{
+			name:   "layers over budget",
+			policy: Policy{MaxLayers: 1},
+			want:   []Violation{{Rule: RuleMaxLayers, Limit: "1", Actual: "2"}},
+		},
+		{name: "waste within budget", policy: Policy{MaxWastedBytes: 400}},
+		{
+			name:   "waste over budget",
+			policy: Policy{MaxWastedBytes: 399},
+			want:   []Violation{{Rule: RuleMaxWastedBytes, Limit: "399", Actual: "400"}},
+		},
+		{
+			name:   "forbidden file",
+			policy: Policy{ForbiddenPaths: []string{"/root/.ssh/id_*"}},
+			want:   []Violation{{Rule: RuleForbiddenPath, Limit: "/root/.ssh/id_*", Actual: "/root/.ssh/id_rsa"}},
+		},
+		{
+			name:   "forbidden directory",
+			policy: Policy{ForbiddenPaths: []string{"root"}},
+			want:   []Violation{{Rule: RuleForbiddenPath, Limit: "root", Actual: "/root/.ssh/id_rsa"}},
+		},
+		{name: "allowed path", policy: Policy{ForbiddenPaths: []string{"usr/share/doc"}}},
+		{
+			name:   "forbidden package",
+			policy: Policy{ForbiddenPackages: []string{"cur*"}},
+			want:   []Violation{{Rule: RuleForbiddenPackage, Limit: "cur*", Actual: "curl=8.5"}},
+		},
+		{name: "allowed package", policy: Policy{ForbiddenPackages: []string{"wget"}}},
+		{
+			name: "stable order",
+			policy: Policy{
+				MaxImageSize:      1,
+				MaxLayers:         1,
+				MaxWastedBytes:    1,
+				ForbiddenPaths:    []string{"root", "etc/motd", "root"},
+				ForbiddenPackages: []string{"curl", "busybox"},
+			},
+			want: []Violation{
+				{Rule: RuleMaxImageSize, Limit: "1", Actual: "10000"},
+				{Rule: RuleMaxLayers, Limit: "1", Actual: "2"},
+				{Rule: RuleMaxWastedBytes, Limit: "1", Actual: "400"},
+				{Rule: RuleForbiddenPath, Limit: "etc/motd", Actual: "/etc/motd"},
+				{Rule: RuleForbiddenPath, Limit: "root", Actual: "/root/.ssh/id_rsa"},
+				{Rule: RuleForbiddenPackage, Limit: "busybox", Actual: "busybox=1.36"},
+				{Rule: RuleForbiddenPackage, Limit: "curl", Actual: "curl=8.5"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.policy.Evaluate(&Result{Image: policyImage()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []Violation
+			for _, vi := range v.Violations {
+				if vi.Message == "" {
+					t.Errorf("violation %s has no message", vi.Rule)
+				}
+				vi.Message = ""
+				got = append(got, vi)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %+v, want %+v", got, tt.want)
+			}
+			if v.Passed != (len(tt.want) == 0) {
+				t.Errorf("Passed = %t with %d violations", v.Passed, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestPolicyEvaluateWithoutFiles(t *testing.T) {
+	metadataOnly := policyImage()
+	metadataOnly.MetadataOnly = true
+	for i := range metadataOnly.Layers {
+		metadataOnly.Layers[i].Files = nil
+		metadataOnly.Layers[i].Packages = nil
+	}
+
+	// Documents are written without listings unless files are requested
+	decoded := decodeResult(t, &Result{Image: policyImage()})
+
+	tests := []struct {
+		name string
+		r    *Result
+	}{
+		{name: "nil result"},
+		{name: "no image", r: &Result{}},
+		{name: "metadata-only", r: &Result{Image: metadataOnly}},
+		{name: "decoded without files", r: decoded},
+	}
+
+	policy := Policy{ForbiddenPaths: []string{"root"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, err := policy.Evaluate(tt.r); err == nil {
+				t.Fatalf("Evaluate = %+v, want an error", v)
+			}
+		})
+	}
+
+	// With files the document restores everything the policy needs
+	v, err := policy.Evaluate(decodeResult(t, &Result{Image: policyImage()}, WithFiles()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Passed {
+		t.Error("policy passed on a result decoded with files")
+	}
+}
@@ -0,0 +1,151 @@
+package slimmer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+)
+
+// reportTopFiles bounds how many of the largest files a Report lists
+const reportTopFiles = 10
+
+// Report is a condensed, human-oriented overview of a Result
+type Report struct {
+	Reference        string
+	Digest           string
+	MediaType        string
+	LayerCount       int
+	CompressedSize   int64
+	UncompressedSize int64
+	WastedBytes      int64
+	FileCount        int
+	PackageCount     int
+	LargestFiles     []analyser.FileEntry
+	Actions          map[string]int
+
+	// Base is the detected base image; BaseSize and OwnSize split the
+	// uncompressed size between its layers and the layers of the image
+	Base      *analyser.BaseImage
+	BaseSize  int64
+	OwnLayers int
+	OwnSize   int64
+
+	// Recommendations lists smaller bases, as planned
+	Recommendations []digest.BaseRecommendation
+}
+
+// NewReport derives a Report from a Result
+func NewReport(r *Result) (*Report, error) {
+	if r == nil || r.Image == nil {
+		return nil, fmt.Errorf("result has no image")
+	}
+
+	img := r.Image
+	files := img.Files()
+
+	rep := &Report{
+		Reference:        img.Reference,
+		Digest:           img.Digest,
+		MediaType:        img.MediaType,
+		LayerCount:       len(img.Layers),
+		CompressedSize:   img.CompressedSize(),
+		UncompressedSize: img.UncompressedSize(),
+		WastedBytes:      img.WastedBytes(),
+		PackageCount:     len(img.Packages()),
+		Actions:          make(map[string]int),
+		Base:             img.Base,
+	}
+
+	for _, l := range img.Layers {
+		if img.IsBaseLayer(l.Index) {
+			rep.BaseSize += l.UncompressedSize
+		} else {
+			rep.OwnLayers++
+			rep.OwnSize += l.UncompressedSize
+		}
+	}
+
+	regular := make([]analyser.FileEntry, 0, len(files))
+	for _, f := range files {
+		if f.Type == analyser.FileTypeRegular {
+			regular = append(regular, f)
+		}
+	}
+	rep.FileCount = len(regular)
+
+	sort.SliceStable(regular, func(a, b int) bool {
+		return regular[a].Size > regular[b].Size
+	})
+	if len(regular) > reportTopFiles {
+		regular = regular[:reportTopFiles]
+	}
+	rep.LargestFiles = regular
+
+	if r.Plan != nil {
+		for _, l := range r.Plan.Layers {
+			rep.Actions[l.Action]++
+		}
+		rep.Recommendations = r.Plan.Recommendations
+	}
+
+	return rep, nil
+}
+
+// Summary renders the report as human-readable text
+func (r *Report) Summary() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Report for %s (digest=%s)\n", r.Reference, r.Digest))
+	sb.WriteString(fmt.Sprintf("- Media type: %s\n", r.MediaType))
+	sb.WriteString(fmt.Sprintf("- Layers: %d\n", r.LayerCount))
+	if r.Base != nil {
+		name := r.Base.Name
+		if name == "" {
+			name = r.Base.Digest
+		}
+		sb.WriteString(fmt.Sprintf("- Base image: %s (from %s)\n", name, r.Base.Source))
+		sb.WriteString(fmt.Sprintf("- Base layers: %d (%d bytes)\n", r.Base.Layers, r.BaseSize))
+		sb.WriteString(fmt.Sprintf("- Own layers: %d (%d bytes)\n", r.OwnLayers, r.OwnSize))
+	}
+	sb.WriteString(fmt.Sprintf("- Compressed size: %d bytes\n", r.CompressedSize))
+	sb.WriteString(fmt.Sprintf("- Uncompressed size: %d bytes\n", r.UncompressedSize))
+	sb.WriteString(fmt.Sprintf("- Wasted bytes: %d\n", r.WastedBytes))
+	sb.WriteString(fmt.Sprintf("- Files: %d\n", r.FileCount))
+	sb.WriteString(fmt.Sprintf("- Packages: %d\n", r.PackageCount))
+
+	if len(r.LargestFiles) > 0 {
+		sb.WriteString("Largest files:\n")
+		for _, f := range r.LargestFiles {
+			sb.WriteString(fmt.Sprintf("- /%s: %d bytes\n", f.Path, f.Size))
+		}
+	}
+
+	if len(r.Actions) > 0 {
+		actions := make([]string, 0, len(r.Actions))
+		for a := range r.Actions {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+
+		sb.WriteString("Planned actions:\n")
+		for _, a := range actions {
+			sb.WriteString(fmt.Sprintf("- %s: %d layers\n", a, r.Actions[a]))
+		}
+	}
+
+	if len(r.Recommendations) > 0 {
+		sb.WriteString("Smaller bases:\n")
+		for _, rec := range r.Recommendations {
+			status := "drop-in"
+			if len(rec.Blockers) > 0 {
+				status = strings.Join(rec.Blockers, "; ")
+			}
+			sb.WriteString(fmt.Sprintf("- %s: %+d bytes (%s)\n", rec.Name, rec.SizeDelta, status))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package slimmer
+
+import (
+	"reflect"
+	"strings"
+)
+
+//go:generate go run ../../cmd/slimmer schema -out ../../schema/result.v1.schema.json
+
+// JSONSchema returns the canonical JSON Schema (draft 2020-12) describing
+// ResultDocument for the current SchemaVersion
+//
+// The schema is generated from the document types, so it cannot drift from
+// the encoder. Field descriptions come from the "description" struct tags
+func JSONSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(ResultDocument{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Image Slimmer Result"
+	schema["description"] = "Analysis result document, schema version " + SchemaVersion
+
+	props := schema["properties"].(map[string]any)
+	props["schemaVersion"] = map[string]any{
+		"const":       SchemaVersion,
+		"description": "Layout version of this document",
+	}
+
+	return CanonicalJSON(schema)
+}
+
+// schemaFor derives a JSON Schema fragment from a Go type
+func schemaFor(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema describes a struct using its json and description tags
+// Fields without omitempty are required. Unknown properties are allowed,
+// since fields are added to a SchemaVersion without bumping it
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	required := make([]string, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaFor(f.Type)
+		if desc := f.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		props[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
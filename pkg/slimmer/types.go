@@ -0,0 +1,215 @@
+package slimmer
+
+import (
+	"io"
+
+	analyser "github.com/pnkcaht/image-slimmer-core/internal/analyser"
+	digest "github.com/pnkcaht/image-slimmer-core/internal/digest"
+	planner "github.com/pnkcaht/image-slimmer-core/internal/planner"
+)
+
+/*
+	Image model
+*/
+
+type (
+	// Image is a fully resolved container image ready for analysis
+	Image = analyser.Image
+
+	// Layer is the extracted metadata of an image layer
+	Layer = analyser.Layer
+
+	// FileEntry is a filesystem entry recorded from a layer
+	FileEntry = analyser.FileEntry
+
+	// FileType classifies a FileEntry
+	FileType = analyser.FileType
+
+	// Package is an OS package recorded in a package manager database
+	Package = analyser.Package
+
+	// Metrics is the immutable execution metrics snapshot of a load
+	Metrics = analyser.Metrics
+
+	// LayerMetrics records the cost of analyzing a single layer
+	LayerMetrics = analyser.LayerMetrics
+
+	// FetchMetrics is the telemetry passed to a metrics hook
+	FetchMetrics = analyser.FetchMetrics
+
+	// LayerSource gives lazy access to layer contents of a remote image
+	LayerSource = analyser.LayerSource
+)
+
+const (
+	FileTypeRegular  = analyser.FileTypeRegular
+	FileTypeDir      = analyser.FileTypeDir
+	FileTypeSymlink  = analyser.FileTypeSymlink
+	FileTypeHardlink = analyser.FileTypeHardlink
+	FileTypeOther    = analyser.FileTypeOther
+)
+
+// ExtractLayerToFS writes the contents of a single layer to targetDir
+func ExtractLayerToFS(layer Layer, layerReader io.ReadCloser, targetDir string) error {
+	return analyser.ExtractLayerToFS(layer, layerReader, targetDir)
+}
+
+/*
+	Errors
+*/
+
+type (
+	// ErrorCode is a machine-readable classification of an AnalyzerError
+	ErrorCode = analyser.ErrorCode
+
+	// AnalyzerError is the structured error returned by the engine
+	AnalyzerError = analyser.AnalyzerError
+)
+
+const (
+	CodeInvalidReference   = analyser.CodeInvalidReference
+	CodeImageNotFound      = analyser.CodeImageNotFound
+	CodeUnauthorized       = analyser.CodeUnauthorized
+	CodeTimeout            = analyser.CodeTimeout
+	CodeFetchFailed        = analyser.CodeFetchFailed
+	CodeRateLimited        = analyser.CodeRateLimited
+	CodeRepositoryNotFound = analyser.CodeRepositoryNotFound
+	CodeBlobNotFound       = analyser.CodeBlobNotFound
+	CodeAccessDenied       = analyser.CodeAccessDenied
+	CodeCircuitOpen        = analyser.CodeCircuitOpen
+
+	CodeTLSUnknownAuthority   = analyser.CodeTLSUnknownAuthority
+	CodeTLSHostnameMismatch   = analyser.CodeTLSHostnameMismatch
+	CodeTLSCertificateInvalid = analyser.CodeTLSCertificateInvalid
+	CodeTLSHandshakeRejected  = analyser.CodeTLSHandshakeRejected
+	CodeTLSNotSupported       = analyser.CodeTLSNotSupported
+	CodeTLSConfig             = analyser.CodeTLSConfig
+	CodeNoLayers              = analyser.CodeNoLayers
+	CodeBuildFailed           = analyser.CodeBuildFailed
+	CodeDigestFailed          = analyser.CodeDigestFailed
+	CodeMediaTypeFailed       = analyser.CodeMediaTypeFailed
+	CodeSizeFailed            = analyser.CodeSizeFailed
+	CodeLayerExtract          = analyser.CodeLayerExtract
+	CodeValidationFailed      = analyser.CodeValidationFailed
+	CodePlanDrift             = analyser.CodePlanDrift
+	CodeUnknown               = analyser.CodeUnknown
+)
+
+// Sentinel errors for use with errors.Is
+var (
+	ErrInvalidReference   = analyser.ErrInvalidReference
+	ErrImageNotFound      = analyser.ErrImageNotFound
+	ErrUnauthorized       = analyser.ErrUnauthorized
+	ErrTimeout            = analyser.ErrTimeout
+	ErrNoLayers           = analyser.ErrNoLayers
+	ErrFetchFailed        = analyser.ErrFetchFailed
+	ErrRateLimited        = analyser.ErrRateLimited
+	ErrRepositoryNotFound = analyser.ErrRepositoryNotFound
+	ErrBlobNotFound       = analyser.ErrBlobNotFound
+	ErrAccessDenied       = analyser.ErrAccessDenied
+	ErrCircuitOpen        = analyser.ErrCircuitOpen
+	ErrTLSConfig          = analyser.ErrTLSConfig
+	ErrBuildFailed        = analyser.ErrBuildFailed
+	ErrPlanDrift          = analyser.ErrPlanDrift
+)
+
+// NewError creates a new structured AnalyzerError
+func NewError(code ErrorCode, op, ref, message string, err error) *AnalyzerError {
+	return analyser.NewError(code, op, ref, message, err)
+}
+
+// IsCode checks whether an error matches a specific ErrorCode
+func IsCode(err error, code ErrorCode) bool {
+	return analyser.IsCode(err, code)
+}
+
+// AsAnalyzerError attempts to extract an AnalyzerError from a generic error
+func AsAnalyzerError(err error) (*AnalyzerError, bool) {
+	return analyser.AsAnalyzerError(err)
+}
+
+/*
+	Plans and risk
+*/
+
+type (
+	// ImagePlan is the slimming plan for an image
+	ImagePlan = digest.ImagePlan
+
+	// LayerPlan is the planned action for a single layer
+	LayerPlan = digest.LayerPlan
+
+	// RiskLevel is the severity of modifying or removing a layer
+	RiskLevel = digest.RiskLevel
+
+	// LayerRisk is the risk assessment of a single layer
+	LayerRisk = digest.LayerRisk
+
+	// DeterministicImage is a normalized, index-ordered view of an image
+	DeterministicImage = planner.DeterministicImage
+)
+
+const (
+	ActionKeep    = digest.ActionKeep
+	ActionRemove  = digest.ActionRemove
+	ActionRebuild = digest.ActionRebuild
+
+	RiskLow    = digest.RiskLow
+	RiskMedium = digest.RiskMedium
+	RiskHigh   = digest.RiskHigh
+)
+
+// NewImagePlan creates a plan that keeps every layer of img
+func NewImagePlan(img *Image) (*ImagePlan, error) {
+	return digest.NewImagePlan(img)
+}
+
+// NewDeterministicImage creates an index-ordered view of img
+func NewDeterministicImage(img *Image) (*DeterministicImage, error) {
+	return planner.NewDeterministicImage(img)
+}
+
+// AssessLayerRisk evaluates the risk of modifying or removing a layer
+func AssessLayerRisk(layer Layer) LayerRisk {
+	return digest.AssessLayerRisk(layer)
+}
+
+// AssessImageRisk evaluates the risk of every layer of img
+func AssessImageRisk(img *Image) ([]LayerRisk, error) {
+	return digest.AssessImageRisk(img)
+}
+
+/*
+	Progress events
+*/
+
+type (
+	// Event is a single progress notification
+	Event = analyser.Event
+
+	// EventType identifies the kind of an Event
+	EventType = analyser.EventType
+
+	// Observer receives progress events
+	Observer = analyser.Observer
+
+	// ObserverFunc adapts a function to the Observer interface
+	ObserverFunc = analyser.ObserverFunc
+)
+
+const (
+	EventResolveStarted  = analyser.EventResolveStarted
+	EventResolveFinished = analyser.EventResolveFinished
+	EventLayerDownload   = analyser.EventLayerDownload
+	EventLayerDecompress = analyser.EventLayerDecompress
+	EventStageStarted    = analyser.EventStageStarted
+	EventStageFinished   = analyser.EventStageFinished
+	EventPlanBuilt       = analyser.EventPlanBuilt
+)
+
+const (
+	StageFetch         = analyser.StageFetch
+	StageBuild         = analyser.StageBuild
+	StageDeterministic = analyser.StageDeterministic
+	StagePlan          = analyser.StagePlan
+)
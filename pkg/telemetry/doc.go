@@ -0,0 +1,19 @@
+// Package telemetry adapts engine metrics to common observability formats
+//
+// A Recorder aggregates FetchMetrics and Result metrics into Prometheus
+// histograms and counters, served over HTTP or written for the node_exporter
+// textfile collector. ResultSpans turns a single analysis into
+// OpenTelemetry-style spans for fetch, build and plan.
+//
+// The package has no dependencies beyond the standard library:
+//
+//	rec := telemetry.NewRecorder()
+//	engine := slimmer.New(slimmer.WithMetricsHook(rec.ObserveFetch))
+//
+//	start := time.Now()
+//	res, err := engine.Slim(ctx, ref)
+//	rec.ObserveResult(res, err)
+//	spans := telemetry.ResultSpans(start, ref, res, err)
+//
+//	http.Handle("/metrics", rec.Handler())
+package telemetry
@@ -0,0 +1,333 @@
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of duration histograms
+var DefaultDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// attemptBuckets are the upper bounds of the fetch attempts histogram
+var attemptBuckets = []float64{1, 2, 3, 4, 5, 8}
+
+// Analysis outcomes recorded by the analyses counter
+const (
+	outcomeAnalyzed  = "analyzed"
+	outcomeCached    = "cached"
+	outcomeUnchanged = "unchanged"
+	outcomeFailed    = "failed"
+)
+
+// Recorder aggregates engine metrics and exposes them in the Prometheus
+// text exposition format
+//
+// Feed it with ObserveFetch (as a metrics hook) and ObserveResult.
+// A Recorder is safe for concurrent use
+type Recorder struct {
+	namespace string
+
+	mu sync.Mutex
+
+	fetchDuration *histogram
+	buildDuration *histogram
+	totalDuration *histogram
+	fetchAttempts *histogram
+
+	attempts      *counter // by result
+	attemptErrors *counter // by error code
+	analyses      *counter // by outcome
+	errors        *counter // by error code
+	layerBytes    *counter // by kind
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption func(*recorderConfig)
+
+type recorderConfig struct {
+	namespace string
+	buckets   []float64
+}
+
+// WithNamespace prefixes every metric name; defaults to "slimmer"
+func WithNamespace(ns string) RecorderOption {
+	return func(c *recorderConfig) {
+		if ns != "" {
+			c.namespace = ns
+		}
+	}
+}
+
+// WithDurationBuckets overrides the bucket upper bounds, in seconds,
+// of duration histograms
+func WithDurationBuckets(buckets ...float64) RecorderOption {
+	return func(c *recorderConfig) {
+		if len(buckets) > 0 {
+			c.buckets = append([]float64(nil), buckets...)
+			sort.Float64s(c.buckets)
+		}
+	}
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder(opts ...RecorderOption) *Recorder {
+	cfg := &recorderConfig{
+		namespace: "slimmer",
+		buckets:   DefaultDurationBuckets,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Recorder{
+		namespace: cfg.namespace,
+
+		fetchDuration: newHistogram("fetch_duration_seconds", "Time spent fetching image manifests, including retries", cfg.buckets),
+		buildDuration: newHistogram("build_duration_seconds", "Time spent downloading and indexing layers", cfg.buckets),
+		totalDuration: newHistogram("analysis_duration_seconds", "Total time of an analysis", cfg.buckets),
+		fetchAttempts: newHistogram("analysis_fetch_attempts", "Fetch attempts per analysis", attemptBuckets),
+
+		attempts:      newCounter("fetch_attempts_total", "Fetch attempts by result", "result"),
+		attemptErrors: newCounter("fetch_attempt_errors_total", "Failed fetch attempts by error code", "code"),
+		analyses:      newCounter("analyses_total", "Analyses by outcome", "outcome"),
+		errors:        newCounter("errors_total", "Failed analyses by error code", "code"),
+		layerBytes:    newCounter("layer_bytes_total", "Layer bytes transferred by kind", "kind"),
+	}
+}
+
+// ObserveFetch records a single fetch attempt
+// It has the signature of a metrics hook: slimmer.WithMetricsHook(r.ObserveFetch)
+func (r *Recorder) ObserveFetch(m slimmer.FetchMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m.Success {
+		r.attempts.add("success", 1)
+		return
+	}
+
+	r.attempts.add("failure", 1)
+	r.attemptErrors.add(string(m.ErrorCode), 1)
+}
+
+// ObserveResult records the outcome of Engine.Slim
+func (r *Recorder) ObserveResult(res *slimmer.Result, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil || res == nil {
+		r.analyses.add(outcomeFailed, 1)
+		r.errors.add(string(errorCode(err)), 1)
+		return
+	}
+
+	m := res.Metrics
+
+	switch {
+	case res.Status == slimmer.StatusUnchanged:
+		r.analyses.add(outcomeUnchanged, 1)
+	case m.Cached:
+		r.analyses.add(outcomeCached, 1)
+	default:
+		r.analyses.add(outcomeAnalyzed, 1)
+	}
+
+	r.fetchDuration.observe(m.FetchDuration.Seconds())
+	r.totalDuration.observe(m.TotalDuration.Seconds())
+
+	// Unchanged and cached results neither fetched nor built anything
+	if !m.FetchSkipped && !m.Cached {
+		r.buildDuration.observe(m.BuildDuration.Seconds())
+		r.fetchAttempts.observe(float64(m.FetchAttempts))
+	}
+
+	r.layerBytes.add("compressed", float64(m.CompressedBytes))
+	r.layerBytes.add("uncompressed", float64(m.UncompressedBytes))
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+
+	for _, h := range []*histogram{r.fetchDuration, r.buildDuration, r.totalDuration, r.fetchAttempts} {
+		h.write(cw, r.namespace)
+	}
+	for _, c := range []*counter{r.attempts, r.attemptErrors, r.analyses, r.errors, r.layerBytes} {
+		c.write(cw, r.namespace)
+	}
+
+	if err := bw.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the metrics for scraping, typically on /metrics
+func (r *Recorder) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// WriteTextfile writes the metrics to path for the node_exporter textfile
+// collector. The file is replaced atomically so scrapes never see partial output
+func (r *Recorder) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// errorCode extracts the classification of err
+func errorCode(err error) slimmer.ErrorCode {
+	if ae, ok := slimmer.AsAnalyzerError(err); ok {
+		return ae.Code()
+	}
+	return slimmer.CodeUnknown
+}
+
+// histogram is a cumulative Prometheus histogram without labels
+type histogram struct {
+	name, help string
+	bounds     []float64
+	counts     []uint64 // per bucket, not cumulative
+	sum        float64
+	count      uint64
+}
+
+func newHistogram(name, help string, bounds []float64) *histogram {
+	return &histogram{
+		name:   name,
+		help:   help,
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.sum += v
+	h.count++
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+func (h *histogram) write(w *countingWriter, ns string) {
+	name := ns + "_" + h.name
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", name, h.help, name)
+
+	var cumulative uint64
+	for i, b := range h.bounds {
+		cumulative += h.counts[i]
+		w.printf("%s_bucket{le=\"%s\"} %d\n", name, formatFloat(b), cumulative)
+	}
+	w.printf("%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	w.printf("%s_sum %s\n", name, formatFloat(h.sum))
+	w.printf("%s_count %d\n", name, h.count)
+}
+
+// counter is a Prometheus counter with a single label
+type counter struct {
+	name, help, label string
+	values            map[string]float64
+}
+
+func newCounter(name, help, label string) *counter {
+	return &counter{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+}
+
+func (c *counter) add(labelValue string, v float64) {
+	c.values[labelValue] += v
+}
+
+func (c *counter) write(w *countingWriter, ns string) {
+	name := ns + "_" + c.name
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", name, c.help, name)
+
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		w.printf("%s{%s=\"%s\"} %s\n", name, c.label, escapeLabel(k), formatFloat(c.values[k]))
+	}
+}
+
+// formatFloat renders v as the exposition format expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values per the exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// countingWriter tracks bytes written and keeps the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...any) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
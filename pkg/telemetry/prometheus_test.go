@@ -0,0 +1,200 @@
+package telemetry_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+	"github.com/pnkcaht/image-slimmer-core/pkg/telemetry"
+)
+
+// scrape fetches the metrics of rec over HTTP and returns the sample lines
+// by series, with the HELP and TYPE lines by metric name
+func scrape(t *testing.T, rec *telemetry.Recorder) (samples map[string]string, order []string, meta map[string][]string) {
+	t.Helper()
+
+	srv := httptest.NewServer(rec.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scrape status = %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != telemetry.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, telemetry.ContentType)
+	}
+
+	samples = make(map[string]string)
+	meta = make(map[string][]string)
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 4 {
+				t.Fatalf("malformed comment line %q", line)
+			}
+			meta[fields[2]] = append(meta[fields[2]], fields[1]+" "+fields[3])
+			continue
+		}
+
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		series, value := line[:i], line[i+1:]
+		if _, dup := samples[series]; dup {
+			t.Errorf("duplicate series %s", series)
+		}
+		samples[series] = value
+		order = append(order, series)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("read scrape: %v", err)
+	}
+	return samples, order, meta
+}
+
+func TestRecorderHistograms(t *testing.T) {
+	rec := telemetry.NewRecorder(telemetry.WithDurationBuckets(1, 0.1))
+
+	for _, d := range []time.Duration{50 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second} {
+		rec.ObserveResult(&slimmer.Result{
+			Status: slimmer.StatusAnalyzed,
+			Metrics: slimmer.Metrics{
+				FetchDuration: d,
+				BuildDuration: d,
+				TotalDuration: d,
+				FetchAttempts: 1,
+			},
+		}, nil)
+	}
+
+	samples, order, meta := scrape(t, rec)
+
+	const name = "slimmer_analysis_duration_seconds"
+	if got := meta[name]; len(got) != 2 || got[1] != "TYPE histogram" {
+		t.Errorf("metadata of %s = %q", name, got)
+	}
+
+	// Buckets are cumulative, sorted by bound and end with +Inf
+	want := []struct{ series, value string }{
+		{name + `_bucket{le="0.1"}`, "1"},
+		{name + `_bucket{le="1"}`, "2"},
+		{name + `_bucket{le="+Inf"}`, "3"},
+		{name + `_sum`, "2.55"},
+		{name + `_count`, "3"},
+	}
+	var got []string
+	for _, s := range order {
+		if strings.HasPrefix(s, name+"_") {
+			got = append(got, s)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("series of %s = %q", name, got)
+	}
+	for i, w := range want {
+		if got[i] != w.series {
+			t.Errorf("series %d = %s, want %s", i, got[i], w.series)
+		}
+		if samples[w.series] != w.value {
+			t.Errorf("%s = %s, want %s", w.series, samples[w.series], w.value)
+		}
+	}
+
+	// Every histogram ends with a +Inf bucket equal to its count
+	for _, h := range []string{"fetch_duration_seconds", "build_duration_seconds", "analysis_duration_seconds", "analysis_fetch_attempts"} {
+		h = "slimmer_" + h
+		inf, ok := samples[h+`_bucket{le="+Inf"}`]
+		if !ok {
+			t.Errorf("%s has no +Inf bucket", h)
+			continue
+		}
+		if inf != samples[h+"_count"] {
+			t.Errorf("%s +Inf bucket = %s, count = %s", h, inf, samples[h+"_count"])
+		}
+
+		var prev int
+		for _, s := range order {
+			if !strings.HasPrefix(s, h+"_bucket{") {
+				continue
+			}
+			n, err := strconv.Atoi(samples[s])
+			if err != nil {
+				t.Fatalf("%s = %q: %v", s, samples[s], err)
+			}
+			if n < prev {
+				t.Errorf("%s = %d is below the previous bucket %d", s, n, prev)
+			}
+			prev = n
+		}
+	}
+}
+
+func TestRecorderCounters(t *testing.T) {
+	rec := telemetry.NewRecorder(telemetry.WithNamespace("test"))
+
+	rec.ObserveFetch(slimmer.FetchMetrics{Success: true})
+	rec.ObserveFetch(slimmer.FetchMetrics{ErrorCode: slimmer.CodeRateLimited})
+	rec.ObserveFetch(slimmer.FetchMetrics{ErrorCode: slimmer.ErrorCode("odd \"code\"\\\nhere")})
+
+	rec.ObserveResult(nil, slimmer.NewError(slimmer.CodeImageNotFound, "fetch", "example.com/app:1", "not found", nil))
+	rec.ObserveResult(&slimmer.Result{Status: slimmer.StatusUnchanged, Metrics: slimmer.Metrics{FetchSkipped: true}}, nil)
+	rec.ObserveResult(&slimmer.Result{Status: slimmer.StatusAnalyzed, Metrics: slimmer.Metrics{Cached: true, CompressedBytes: 10, UncompressedBytes: 30}}, nil)
+
+	samples, _, meta := scrape(t, rec)
+
+	want := map[string]string{
+		`test_fetch_attempts_total{result="success"}`:                  "1",
+		`test_fetch_attempts_total{result="failure"}`:                  "2",
+		`test_fetch_attempt_errors_total{code="RATE_LIMITED"}`:         "1",
+		`test_fetch_attempt_errors_total{code="odd \"code\"\\\nhere"}`: "1",
+		`test_analyses_total{outcome="failed"}`:                        "1",
+		`test_analyses_total{outcome="unchanged"}`:                     "1",
+		`test_analyses_total{outcome="cached"}`:                        "1",
+		`test_errors_total{code="IMAGE_NOT_FOUND"}`:                    "1",
+		`test_layer_bytes_total{kind="compressed"}`:                    "10",
+		`test_layer_bytes_total{kind="uncompressed"}`:                  "30",
+	}
+	for series, value := range want {
+		if got, ok := samples[series]; !ok {
+			t.Errorf("missing series %s", series)
+		} else if got != value {
+			t.Errorf("%s = %s, want %s", series, got, value)
+		}
+	}
+
+	if got := meta["test_analyses_total"]; len(got) != 2 || got[1] != "TYPE counter" {
+		t.Errorf("metadata of test_analyses_total = %q", got)
+	}
+
+	// Neither unchanged nor cached results fetched anything
+	if got := samples["test_analysis_fetch_attempts_count"]; got != "0" {
+		t.Errorf("fetch attempts observed = %s, want 0", got)
+	}
+}
+
+func TestRecorderWriteTo(t *testing.T) {
+	rec := telemetry.NewRecorder()
+
+	var b strings.Builder
+	n, err := rec.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, b.Len())
+	}
+}
@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pnkcaht/image-slimmer-core/pkg/slimmer"
+)
+
+// Span names produced by ResultSpans
+const (
+	SpanSlim  = "slimmer.slim"
+	SpanFetch = "slimmer.fetch"
+	SpanBuild = "slimmer.build"
+	SpanPlan  = "slimmer.plan"
+)
+
+// Span status codes, as defined by OpenTelemetry
+const (
+	StatusUnset = "UNSET"
+	StatusOK    = "OK"
+	StatusError = "ERROR"
+)
+
+// Span is an OpenTelemetry-style span
+// It is not encoded as OTLP: times are RFC 3339, attributes a plain map and
+// status codes the names above, so spans need converting before export
+type Span struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Start        time.Time      `json:"startTime"`
+	End          time.Time      `json:"endTime"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Status       SpanStatus     `json:"status"`
+}
+
+// SpanStatus is the outcome of a Span
+type SpanStatus struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// ResultSpans builds the spans of a single Engine.Slim call that started at start
+//
+// The root span covers the whole call; fetch, build and plan are sequential
+// children reconstructed from the result metrics. A failed call only has
+// the root span, carrying the error code
+func ResultSpans(start time.Time, ref string, res *slimmer.Result, err error) []Span {
+	traceID := randomID(16)
+
+	root := Span{
+		TraceID: traceID,
+		SpanID:  randomID(8),
+		Name:    SpanSlim,
+		Start:   start,
+		Attributes: map[string]any{
+			"image.reference": ref,
+		},
+		Status: SpanStatus{Code: StatusOK},
+	}
+
+	if err != nil || res == nil {
+		root.End = time.Now()
+		root.Status = SpanStatus{Code: StatusError}
+		if err != nil {
+			root.Status.Message = err.Error()
+		}
+		root.Attributes["error.code"] = string(errorCode(err))
+		return []Span{root}
+	}
+
+	m := res.Metrics
+	root.End = start.Add(m.TotalDuration)
+	root.Attributes["slimmer.status"] = string(res.Status)
+	root.Attributes["slimmer.cached"] = m.Cached
+	if res.Image != nil {
+		root.Attributes["image.digest"] = res.Image.Digest
+	}
+
+	child := func(name string, from time.Time, d time.Duration, attrs map[string]any) Span {
+		return Span{
+			TraceID:      traceID,
+			SpanID:       randomID(8),
+			ParentSpanID: root.SpanID,
+			Name:         name,
+			Start:        from,
+			End:          from.Add(d),
+			Attributes:   attrs,
+			Status:       SpanStatus{Code: StatusOK},
+		}
+	}
+
+	spans := []Span{root}
+
+	cursor := start
+	spans = append(spans, child(SpanFetch, cursor, m.FetchDuration, map[string]any{
+		"fetch.attempts":      m.FetchAttempts,
+		"fetch.digest_pinned": m.DigestPinned,
+		"fetch.skipped":       m.FetchSkipped,
+	}))
+	cursor = cursor.Add(m.FetchDuration)
+
+	if m.BuildDuration > 0 {
+		spans = append(spans, child(SpanBuild, cursor, m.BuildDuration, map[string]any{
+			"build.layers":             len(m.Layers),
+			"build.compressed_bytes":   m.CompressedBytes,
+			"build.uncompressed_bytes": m.UncompressedBytes,
+		}))
+		cursor = cursor.Add(m.BuildDuration)
+	}
+
+	if res.Plan != nil {
+		// Planning is not timed separately; it takes what remains of the total
+		remaining := max(root.End.Sub(cursor), 0)
+		spans = append(spans, child(SpanPlan, cursor, remaining, map[string]any{
+			"plan.layers": len(res.Plan.Layers),
+		}))
+	}
+
+	return spans
+}
+
+// WriteSpans writes spans as JSON lines, one span per line
+func WriteSpans(w io.Writer, spans []Span) error {
+	enc := json.NewEncoder(w)
+	for _, s := range spans {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// randomID returns n random bytes hex encoded, as used for trace and span IDs
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}